@@ -7,13 +7,22 @@ import (
 	"path/filepath"
 
 	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
 )
 
-// Store repository root for directory walk
-// Not sure if this is the best way to do this...
-var currentRepoRoot string
+// Collects entries for every changed file in a directory walk
+// so the index is read and written once no matter how many files are added
+type directoryAdder struct {
+	repository *common.Repository
+	index      *common.Index
+	entries    []*common.IndexEntry
+}
 
 func Add(flags []string) {
+	if len(flags) >= 2 && (flags[0] == "-N" || flags[0] == "--intent-to-add") {
+		addIntentToAdd(flags[1:])
+		return
+	}
 	if len(flags) != 1 {
 		printAddUsage()
 		return
@@ -30,32 +39,41 @@ func Add(flags []string) {
 		fmt.Printf("%v\n", err)
 		return
 	}
+	if !flagIsDirectory {
+		UpdateIndex([]string{"-add", flags[0]})
+		return
+	}
 
-	// Walk the entire work tree and all sub directories, add to the index
-	if flags[0] == "." {
-		// Set the global repository root before walking
-		currentRepoRoot = repository.WorkTree
-
-		err := filepath.WalkDir(currentRepoRoot, processEntry)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-		}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
 		return
 	}
+	adder := &directoryAdder{
+		repository: repository,
+		index:      index,
+	}
 
-	// Only walk the specified directory
-	if flagIsDirectory {
-		err := filepath.WalkDir(flags[0], processEntry)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-		}
+	// Walk the entire work tree and all sub directories for "." otherwise only walk the specified directory
+	walkRoot := flags[0]
+	if flags[0] == "." {
+		walkRoot = repository.WorkTree
+	}
+	err = filepath.WalkDir(walkRoot, adder.processEntry)
+	if err != nil {
+		fmt.Printf("%v\n", err)
 		return
 	}
 
-	UpdateIndex([]string{"-add", flags[0]})
+	index.AddEntries(adder.entries)
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
 }
 
-func processEntry(path string, entry fs.DirEntry, err error) error {
+func (adder *directoryAdder) processEntry(path string, entry fs.DirEntry, err error) error {
 	if err != nil {
 		return err
 	}
@@ -65,18 +83,102 @@ func processEntry(path string, entry fs.DirEntry, err error) error {
 	}
 
 	if !entry.IsDir() && entry.Type().IsRegular() {
+		absolutePath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("error getting absolute path %s: %v", path, err)
+		}
 		// Calculate relative path from the repository root
-		relativePath, err := filepath.Rel(currentRepoRoot, path)
+		relativePath, err := filepath.Rel(adder.repository.WorkTree, absolutePath)
 		if err != nil {
 			return fmt.Errorf("error getting relative path %s: %v", path, err)
 		}
 
-		UpdateIndex([]string{"-add", relativePath})
+		// Entries flagged assume unchanged or skip worktree are left alone when adding whole directories
+		existingEntry := adder.index.FindEntry(relativePath)
+		if existingEntry != nil && (existingEntry.AssumeUnchanged() || existingEntry.SkipWorktree()) {
+			return nil
+		}
+
+		indexEntry, err := createIndexEntry(adder.repository, adder.index, absolutePath)
+		if err != nil {
+			return err
+		}
+		if indexEntry != nil {
+			adder.entries = append(adder.entries, indexEntry)
+		}
 	}
 
 	return nil
 }
 
+// Record each path in the index with the empty blob hash and the intent to add flag so it shows up
+// as a new file in diffs without its content being staged
+func addIntentToAdd(files []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	emptyBlob := &objects.Blob{}
+	emptyBlobHash, err := common.HashObject(emptyBlob.Serialize())
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	// Git expects the empty blob to exist even though nothing in a tree points at it yet
+	err = repository.WriteObject(emptyBlobHash.String(), emptyBlob.Serialize())
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	for _, file := range files {
+		absolutePath, err := filepath.Abs(file)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fileInfo, err := os.Stat(absolutePath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fileMode := common.IndexFileMode(fileInfo.Mode())
+		if fileMode == 0 {
+			fmt.Printf("Unsupported file mode for intent to add: %s\n", file)
+			return
+		}
+		indexEntryRelativePath, err := filepath.Rel(repository.WorkTree, absolutePath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		// Already tracked paths keep their staged content
+		if index.FindEntry(indexEntryRelativePath) != nil {
+			continue
+		}
+		entry := &common.IndexEntry{
+			Hash:      emptyBlobHash,
+			FileMode:  fileMode,
+			EntryPath: indexEntryRelativePath,
+		}
+		entry.SetIntentToAdd(true)
+		index.AddEntry(entry)
+	}
+
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
 func isDir(path string) (bool, error) {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
@@ -88,4 +190,5 @@ func isDir(path string) (bool, error) {
 func printAddUsage() {
 	fmt.Println("Usage: gitgood add <filename>         Stage a file by adding it to the index")
 	fmt.Println("Usage: gitgood add .                  Stage all files in the working directory")
+	fmt.Println("Usage: gitgood add -N <filename>...   Record that the files will be added later")
 }
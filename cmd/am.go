@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+// What git mailinfo pulls out of one email
+type mailPatch struct {
+	author  string
+	date    time.Time
+	subject string
+	// The commit message after the subject without the blank lines around it
+	body  string
+	patch []byte
+}
+
+// Ref https://github.com/git/git/blob/master/builtin/am.c
+func Am(flags []string) {
+	options := &applyOptions{index: true}
+	var files []string
+	for _, flag := range flags {
+		switch {
+		case flag == "-3" || flag == "--3way":
+			options.threeWay = true
+		case strings.HasPrefix(flag, "-") && flag != "-":
+			fmt.Println("Unsupported flag...")
+			printAmUsage()
+			return
+		default:
+			files = append(files, flag)
+		}
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var mails []*mailPatch
+	for _, file := range files {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Printf("fatal: could not open '%s' for reading: %v\n", file, err)
+			return
+		}
+		for _, message := range splitMailbox(data) {
+			mail, err := parseMail(message)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			mails = append(mails, mail)
+		}
+	}
+	if len(mails) == 0 {
+		fmt.Println("Patch format detection failed.")
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	dirty, err := dirtyIndexPaths(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if len(dirty) > 0 {
+		fmt.Printf("error: Dirty index: cannot apply patches (dirty: %s)\n", strings.Join(dirty, " "))
+		return
+	}
+
+	// Patches always apply from the top of the work tree whichever directory am runs in
+	applier := &applier{options: options, repository: repository, index: index, workTree: repository.WorkTree}
+	for i, mail := range mails {
+		fmt.Printf("Applying: %s\n", mail.subject)
+		if !bytes.Contains(mail.patch, []byte("\n@@ -")) && !bytes.Contains(mail.patch, []byte("diff --git ")) {
+			fmt.Println("Patch is empty.")
+			printAmStopped(i+1, mail, len(mails))
+			return
+		}
+		err = applier.applyPatches(mail.patch)
+		if err != nil && !errors.Is(err, errPatchFailed) {
+			fmt.Printf("%v\n", err)
+		}
+		if err != nil || index.HasConflicts() {
+			printAmStopped(i+1, mail, len(mails))
+			return
+		}
+		err = commitMail(repository, index, mail)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+}
+
+// There's no state kept to continue from so whatever is left has to be applied again by hand
+func printAmStopped(number int, mail *mailPatch, total int) {
+	fmt.Printf("Patch failed at %04d %s\n", number, mail.subject)
+	if number < total {
+		fmt.Printf("The remaining %d %s not applied.\n", total-number, plural(total-number, "patch was", "patches were"))
+	}
+}
+
+// Staged changes would end up in the commits so am only runs when the index matches HEAD
+func dirtyIndexPaths(repository *common.Repository, index *common.Index) ([]string, error) {
+	headEntries := map[string]*common.IndexEntry{}
+	headHash, err := repository.ResolveHead()
+	if err != nil {
+		return nil, err
+	}
+	if !headHash.Empty() {
+		headEntries, err = readTreeEntries(repository, headHash.String(), "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var dirty []string
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 || !sameEntry(entry, headEntries[entry.EntryPath]) {
+			if len(dirty) == 0 || dirty[len(dirty)-1] != entry.EntryPath {
+				dirty = append(dirty, entry.EntryPath)
+			}
+		}
+		delete(headEntries, entry.EntryPath)
+	}
+	// Whatever is left was removed from the index
+	removed := map[string]bool{}
+	for entryPath := range headEntries {
+		removed[entryPath] = true
+	}
+	return append(dirty, sortedPaths(removed)...), nil
+}
+
+// Messages in an mbox start with a "From " separator line - anything else is taken as a single message
+// Ref https://github.com/git/git/blob/master/builtin/mailsplit.c
+func splitMailbox(data []byte) []string {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.SplitAfter(text, "\n")
+	if !isFromLine(lines[0]) {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []string{text}
+	}
+
+	var messages []string
+	start := 0
+	for i := 1; i < len(lines); i++ {
+		if isFromLine(lines[i]) {
+			messages = append(messages, strings.Join(lines[start:i], ""))
+			start = i
+		}
+	}
+	return append(messages, strings.Join(lines[start:], ""))
+}
+
+// "From <sender> <date>" where the date has a time and a year, so text that just starts with
+// "From " doesn't start a new message
+// Ref https://github.com/git/git/blob/master/builtin/mailsplit.c (is_from_line)
+func isFromLine(line string) bool {
+	line = strings.TrimSuffix(line, "\n")
+	if len(line) < 19 || !strings.HasPrefix(line, "From ") {
+		return false
+	}
+	colon := strings.LastIndex(line[5:len(line)-1], ":") + 5
+	if colon < 9 || colon+3 > len(line) {
+		return false
+	}
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	if !isDigit(line[colon-4]) || !isDigit(line[colon-2]) || !isDigit(line[colon-1]) || !isDigit(line[colon+1]) || !isDigit(line[colon+2]) {
+		return false
+	}
+	fields := strings.Fields(line[colon+3:])
+	if len(fields) == 0 {
+		return false
+	}
+	year, err := strconv.Atoi(fields[0])
+	return err == nil && year > 90
+}
+
+// Split a message into its author, date, subject, commit message and patch
+// Ref https://github.com/git/git/blob/master/mailinfo.c
+func parseMail(message string) (*mailPatch, error) {
+	if isFromLine(message) {
+		_, message, _ = strings.Cut(message, "\n")
+	}
+	headerText, body, _ := strings.Cut(message, "\n\n")
+	headers := map[string]string{}
+	var name string
+	for _, line := range strings.Split(headerText, "\n") {
+		// Folded lines carry on the header before them
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && name != "" {
+			headers[name] += line
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(key))
+		headers[name] = strings.TrimSpace(value)
+	}
+	if headers["from"] == "" {
+		return nil, fmt.Errorf("fatal: patch does not have a valid e-mail address")
+	}
+
+	switch strings.ToLower(headers["content-transfer-encoding"]) {
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+		if err != nil {
+			return nil, fmt.Errorf("fatal: could not decode the message: %v", err)
+		}
+		body = string(decoded)
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+		if err != nil {
+			return nil, fmt.Errorf("fatal: could not decode the message: %v", err)
+		}
+		body = string(decoded)
+	}
+
+	mail := &mailPatch{
+		author:  mailAuthor(decodeRFC2047(headers["from"])),
+		date:    mailDate(headers["date"]),
+		subject: cleanupSubject(decodeRFC2047(headers["subject"])),
+	}
+	lines := strings.SplitAfter(body, "\n")
+	end := len(lines)
+	for i, line := range lines {
+		if isPatchBreak(line) {
+			end = i
+			break
+		}
+	}
+	mail.body = strings.TrimSuffix(stripSpace(strings.Join(lines[:end], "")), "\n")
+	mail.patch = []byte(strings.Join(lines[end:], ""))
+	return mail, nil
+}
+
+// "Name <email>" with the name unquoted, or just the address when there's no name
+func mailAuthor(from string) string {
+	name, email := from, from
+	if start := strings.LastIndex(from, "<"); start != -1 {
+		name = strings.TrimSpace(from[:start])
+		email, _, _ = strings.Cut(from[start+1:], ">")
+	} else if address, comment, found := strings.Cut(from, "("); found {
+		// The old "email (Name)" form
+		name, email = strings.TrimSuffix(strings.TrimSpace(comment), ")"), strings.TrimSpace(address)
+	}
+	name = rfc822Unquote(name)
+	if name == "" {
+		name = email
+	}
+	return fmt.Sprintf("%s <%s>", name, strings.TrimSpace(email))
+}
+
+// Dates keep the zone the mail gives them - without one that can be read the patch is dated now
+func mailDate(value string) time.Time {
+	for _, layout := range []string{"Mon, 2 Jan 2006 15:04:05 -0700", "2 Jan 2006 15:04:05 -0700", time.RFC1123Z} {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date
+		}
+	}
+	return time.Now()
+}
+
+// Drop "Re:", "[PATCH n/m]" and the like from the front of the subject
+// Ref https://github.com/git/git/blob/master/mailinfo.c (cleanup_subject)
+func cleanupSubject(subject string) string {
+	for {
+		lower := strings.ToLower(subject)
+		switch {
+		case strings.HasPrefix(lower, "re:"):
+			subject = subject[3:]
+		case strings.HasPrefix(subject, " ") || strings.HasPrefix(subject, "\t") || strings.HasPrefix(subject, ":"):
+			subject = subject[1:]
+		case strings.HasPrefix(subject, "["):
+			end := strings.Index(subject, "]")
+			if end == -1 {
+				return strings.Join(strings.Fields(subject), " ")
+			}
+			subject = subject[end+1:]
+		default:
+			return strings.Join(strings.Fields(subject), " ")
+		}
+	}
+}
+
+// Where the message stops and the patch starts: the "---" line, or the diff itself when there isn't one
+// Ref https://github.com/git/git/blob/master/mailinfo.c (patchbreak)
+func isPatchBreak(line string) bool {
+	if rest, found := strings.CutPrefix(line, "---"); found {
+		if strings.HasPrefix(rest, " ") && len(rest) > 1 && !isMailSpace(rest[1]) {
+			return true
+		}
+		return strings.TrimSpace(rest) == ""
+	}
+	return strings.HasPrefix(line, "diff -") || strings.HasPrefix(line, "Index: ")
+}
+
+// Trailing whitespace and blank lines at either end go and runs of blank lines become one
+// Ref https://github.com/git/git/blob/master/strbuf.c (strbuf_stripspace)
+func stripSpace(text string) string {
+	var builder strings.Builder
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blank = builder.Len() > 0
+			continue
+		}
+		if blank {
+			builder.WriteByte('\n')
+			blank = false
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
+
+// Record the index as a commit by the mail's author at its date - whoever runs am is the committer
+func commitMail(repository *common.Repository, index *common.Index, mail *mailPatch) error {
+	rootTree, trees, err := objects.BuildTreeFromIndex(index)
+	if err != nil {
+		return err
+	}
+	for _, tree := range trees {
+		err = repository.WriteObject(tree.Hash.String(), tree.Serialize())
+		if err != nil {
+			return err
+		}
+	}
+	committer, err := repository.Identity()
+	if err != nil {
+		return err
+	}
+	headHash, err := repository.ResolveHead()
+	if err != nil {
+		return err
+	}
+
+	message := mail.subject + "\n"
+	if mail.body != "" {
+		message += "\n" + mail.body + "\n"
+	}
+	commit := &objects.Commit{
+		Tree:          rootTree,
+		Author:        mail.author,
+		Timestamp:     mail.date,
+		Committer:     committer,
+		CommitterTime: time.Now(),
+		Message:       message,
+	}
+	if !headHash.Empty() {
+		commit.Parents = []common.Hash{headHash}
+	}
+	serializedCommitData := commit.Serialize()
+	commitHash, err := common.HashObject(serializedCommitData)
+	if err != nil {
+		return err
+	}
+	err = repository.WriteObject(commitHash.String(), serializedCommitData)
+	if err != nil {
+		return err
+	}
+
+	branch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	reflogMessage := "am: " + mail.subject
+	if branch == "" {
+		err = repository.SetHeadDetached(commitHash)
+		if err != nil {
+			return err
+		}
+		return repository.AppendReflog("HEAD", headHash, commitHash, reflogMessage)
+	}
+	return repository.UpdateBranch(branch, headHash, commitHash, reflogMessage)
+}
+
+func printAmUsage() {
+	fmt.Println("Usage: gitgood am [-3] [<mbox>...]          Apply a series of patches from a mailbox as commits")
+}
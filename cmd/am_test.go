@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func TestSplitMailboxOnlyOnFromLines(t *testing.T) {
+	mailbox := "From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001\n" +
+		"From: A U Thor <author@example.com>\n" +
+		"Date: Mon, 1 Jan 2024 10:00:00 +0000\n" +
+		"Subject: [PATCH 1/2] first\n" +
+		"\n" +
+		"Some context.\n" +
+		"\n" +
+		"From the docs, this is how it should work.\n" +
+		"---\n" +
+		" a | 1 +\n" +
+		"\n" +
+		"From 2222222222222222222222222222222222222222 Mon Sep 17 00:00:00 2001\n" +
+		"From: A U Thor <author@example.com>\n" +
+		"Date: Mon, 1 Jan 2024 11:00:00 +0000\n" +
+		"Subject: [PATCH 2/2] second\n" +
+		"\n" +
+		"---\n"
+
+	messages := splitMailbox([]byte(mailbox))
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	mail, err := parseMail(messages[0])
+	if err != nil {
+		t.Fatalf("expected no error parsing the first message, got %v", err)
+	}
+	if mail.subject != "first" {
+		t.Errorf("expected subject first, got %q", mail.subject)
+	}
+	if !strings.Contains(mail.body, "From the docs, this is how it should work.") {
+		t.Errorf("expected the body paragraph to stay in the message, got %q", mail.body)
+	}
+}
+
+func TestIsFromLine(t *testing.T) {
+	cases := map[string]bool{
+		"From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001\n": true,
+		"From author@example.com Tue Jan  2 10:04:05 2024\n":                       true,
+		"From the docs, this is how it should work.\n":                             false,
+		"From: A U Thor <author@example.com>\n":                                    false,
+		"From someone at 10:00:00\n":                                               false,
+	}
+	for line, expected := range cases {
+		if got := isFromLine(line); got != expected {
+			t.Errorf("isFromLine(%q) = %v, expected %v", line, got, expected)
+		}
+	}
+}
+
+func TestAmRejectsPathsInTheRepository(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Init(nil)
+	writeTestFile(t, "a", "a\n")
+	Add([]string{"a"})
+	Commit([]string{"-m", "base"})
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	head, err := repository.ResolveHead()
+	if err != nil {
+		t.Fatalf("expected no error resolving HEAD, got %v", err)
+	}
+
+	mailbox := "From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001\n" +
+		"From: A U Thor <author@example.com>\n" +
+		"Date: Mon, 1 Jan 2024 10:00:00 +0000\n" +
+		"Subject: [PATCH] hook\n" +
+		"\n" +
+		"---\n" +
+		"diff --git a/.gitgood/hooks/x b/.gitgood/hooks/x\n" +
+		"new file mode 100755\n" +
+		"--- /dev/null\n" +
+		"+++ b/.gitgood/hooks/x\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+echo pwned\n"
+	writeTestFile(t, "hostile.mbox", mailbox)
+	Am([]string{"hostile.mbox"})
+
+	if _, err := os.Lstat(".gitgood/hooks/x"); !os.IsNotExist(err) {
+		t.Fatalf("expected .gitgood/hooks/x not to be written, got %v", err)
+	}
+	after, err := repository.ResolveHead()
+	if err != nil || after != head {
+		t.Fatalf("expected HEAD to stay at %s, got %s %v", head, after, err)
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil || index.FindEntry(".gitgood/hooks/x") != nil {
+		t.Fatalf("expected nothing staged for the hook, got %v", err)
+	}
+}
@@ -0,0 +1,740 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/diff"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+// Returned once the reason a patch doesn't apply has been printed
+var errPatchFailed = errors.New("patch does not apply")
+
+type applyOptions struct {
+	check    bool
+	cached   bool
+	index    bool
+	reverse  bool
+	threeWay bool
+	reject   bool
+	verbose  bool
+}
+
+// Where patches are read from and written to - there's no repository when applying to plain files
+// outside of one and patch paths are relative to the directory apply runs in like git
+type applier struct {
+	options    *applyOptions
+	repository *common.Repository
+	index      *common.Index
+	workTree   string
+	prefix     string
+	// Paths earlier patches in the series touched so later patches build on their results
+	files map[string]*applyFile
+}
+
+type applyFile struct {
+	data   []byte
+	mode   uint32
+	exists bool
+}
+
+// A patch that applied and what to write for it
+type appliedPatch struct {
+	patch   *diff.FilePatch
+	oldName string
+	newName string
+	data    []byte
+	mode    uint32
+	// Hunks that didn't apply with --reject
+	rejected []int
+	// Base, ours and theirs entries when a 3-way merge conflicted
+	stages []*common.IndexEntry
+}
+
+// Ref https://github.com/git/git/blob/master/apply.c
+func Apply(flags []string) {
+	options := &applyOptions{}
+	var files []string
+	for _, flag := range flags {
+		switch flag {
+		case "--check":
+			options.check = true
+		case "--cached":
+			options.cached = true
+		case "--index":
+			options.index = true
+		case "-R", "--reverse":
+			options.reverse = true
+		case "-3", "--3way":
+			options.threeWay = true
+		case "--reject":
+			options.reject = true
+		case "-v", "--verbose":
+			options.verbose = true
+		default:
+			if strings.HasPrefix(flag, "-") && flag != "-" {
+				fmt.Println("Unsupported flag...")
+				printApplyUsage()
+				return
+			}
+			files = append(files, flag)
+		}
+	}
+	if options.reject && options.threeWay {
+		fmt.Println("fatal: options '--reject' and '--3way' cannot be used together")
+		return
+	}
+	// A 3-way merge records conflicts in the index so it works on the index too unless told to stay there
+	if options.threeWay && !options.cached {
+		options.index = true
+	}
+	// Rejects are reported hunk by hunk so they come with the rest of the verbose output
+	if options.reject {
+		options.verbose = true
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	applier, err := newApplier(options)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, file := range files {
+		var data []byte
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("error: can't open patch '%s': No such file or directory\n", file)
+			return
+		}
+		if err != nil {
+			fmt.Printf("error: can't open patch '%s': %v\n", file, err)
+			return
+		}
+		err = applier.applyPatches(data)
+		if errors.Is(err, errPatchFailed) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+}
+
+func newApplier(options *applyOptions) (*applier, error) {
+	applier := &applier{options: options}
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		if options.cached || options.index {
+			return nil, err
+		}
+		applier.workTree, err = os.Getwd()
+		return applier, err
+	}
+	applier.repository = repository
+	applier.workTree = repository.WorkTree
+
+	prefix, err := indexPath(repository, ".")
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "." {
+		applier.prefix = filepath.ToSlash(prefix) + "/"
+	}
+
+	if options.cached || options.index {
+		applier.index, err = common.GetIndex(repository)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return applier, nil
+}
+
+// Check every patch first and only write anything once they all apply - with --reject the patches
+// that apply are written anyway and the hunks that don't go to .rej files
+func (applier *applier) applyPatches(data []byte) error {
+	patches, err := diff.ParsePatch(data)
+	if err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	if len(patches) == 0 {
+		return fmt.Errorf("error: No valid patches in input (allow with \"--allow-empty\")")
+	}
+
+	// Reversed patches undo the files in the opposite order
+	if applier.options.reverse {
+		slices.Reverse(patches)
+	}
+	applier.files = map[string]*applyFile{}
+	var results []*appliedPatch
+	failed := false
+	for _, patch := range patches {
+		if applier.options.reverse {
+			patch.Reverse()
+		}
+		if applier.options.verbose {
+			fmt.Printf("Checking patch %s...\n", applier.displayName(patch))
+		}
+		result, err := applier.applyPatch(patch)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			failed = true
+			continue
+		}
+		results = append(results, result)
+	}
+	if failed && !applier.options.reject {
+		return errPatchFailed
+	}
+	if applier.options.check {
+		return nil
+	}
+
+	// Removals go first so a file can be renamed onto a path another patch frees up
+	for _, result := range results {
+		if result.patch.IsDelete || result.patch.IsRename {
+			err = applier.remove(result.oldName)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	var conflicts []string
+	for _, result := range results {
+		if !result.patch.IsDelete {
+			err = applier.write(result)
+			if err != nil {
+				return err
+			}
+		}
+		if len(result.stages) > 0 {
+			conflicts = append(conflicts, result.newName)
+		}
+		err = applier.writeRejects(result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if applier.index != nil {
+		err = common.WriteIndex(applier.repository, applier.index)
+		if err != nil {
+			return err
+		}
+	}
+	for _, path := range conflicts {
+		fmt.Printf("U %s\n", path)
+	}
+	return nil
+}
+
+// "old => new" for renames and copies like git's say_patch_name
+func (applier *applier) displayName(patch *diff.FilePatch) string {
+	if patch.OldName != "" && patch.NewName != "" && patch.OldName != patch.NewName {
+		return applier.prefix + patch.OldName + " => " + applier.prefix + patch.NewName
+	}
+	if patch.NewName != "" {
+		return applier.prefix + patch.NewName
+	}
+	return applier.prefix + patch.OldName
+}
+
+func (applier *applier) applyPatch(patch *diff.FilePatch) (*appliedPatch, error) {
+	result := &appliedPatch{patch: patch}
+	if patch.OldName != "" {
+		result.oldName = applier.prefix + patch.OldName
+	}
+	if patch.NewName != "" {
+		result.newName = applier.prefix + patch.NewName
+	}
+	name := result.oldName
+	if name == "" {
+		name = result.newName
+	}
+	// Nothing gets read or written for a patch that reaches outside the work tree or into the repository
+	var names []string
+	if !patch.IsNew {
+		names = append(names, result.oldName)
+	}
+	if !patch.IsDelete {
+		names = append(names, result.newName)
+	}
+	for _, checkName := range names {
+		if !common.VerifyPath(checkName) {
+			return nil, fmt.Errorf("error: invalid path '%s'", checkName)
+		}
+		if applier.beyondSymlink(checkName) {
+			return nil, fmt.Errorf("error: affected file '%s' is beyond a symbolic link", checkName)
+		}
+	}
+
+	var oldData []byte
+	oldMode := uint32(0)
+	if !patch.IsNew {
+		old, err := applier.readPreimage(result.oldName)
+		if err != nil {
+			return nil, err
+		}
+		oldData, oldMode = old.data, old.mode
+		if patch.OldMode != 0 && patch.OldMode != oldMode {
+			fmt.Printf("warning: %s has type %o, expected %o\n", result.oldName, oldMode, patch.OldMode)
+		}
+	}
+	if !patch.IsDelete && (patch.IsNew || result.newName != result.oldName) {
+		err := applier.checkNewPath(result.newName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.mode = patch.NewMode
+	if result.mode == 0 {
+		result.mode = oldMode
+	}
+	if result.mode == 0 {
+		result.mode = 0100644
+	}
+
+	var err error
+	merged := false
+	if applier.options.threeWay {
+		merged, err = applier.tryThreeWay(result, name, oldData)
+		if err != nil {
+			return nil, err
+		}
+		if !merged {
+			fmt.Println("Falling back to direct application...")
+		}
+	}
+	switch {
+	case merged:
+	case patch.IsBinary || patch.Binary != nil:
+		result.data, err = applier.applyBinary(patch, name, oldData)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	default:
+		result.data, result.rejected, err = applier.applyHunks(patch, name, oldData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: %s: patch does not apply", name)
+	}
+	if patch.IsDelete && len(result.data) > 0 {
+		fmt.Println("error: removal patch leaves file contents")
+		return nil, fmt.Errorf("error: %s: patch does not apply", name)
+	}
+
+	if patch.IsDelete || patch.IsRename {
+		applier.files[result.oldName] = &applyFile{}
+	}
+	if !patch.IsDelete {
+		applier.files[result.newName] = &applyFile{data: result.data, mode: result.mode, exists: true}
+	}
+	return result, nil
+}
+
+// The content a patch applies to: what an earlier patch left, the staged blob with --cached and
+// --index (which also wants the file to match it) or otherwise the file
+func (applier *applier) readPreimage(name string) (*applyFile, error) {
+	if file, found := applier.files[name]; found {
+		if !file.exists {
+			return nil, fmt.Errorf("error: path %s has been renamed/deleted", name)
+		}
+		return file, nil
+	}
+
+	if applier.index != nil {
+		entry := applier.index.FindEntry(name)
+		if entry == nil || entry.Stage() != 0 {
+			return nil, fmt.Errorf("error: %s: does not exist in index", name)
+		}
+		if !applier.options.cached {
+			matches, err := worktreeMatchesEntry(applier.repository, applier.index, entry)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				return nil, fmt.Errorf("error: %s: does not match index", name)
+			}
+		}
+		blob, err := objects.ReadBlob(applier.repository, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return &applyFile{data: blob.Data, mode: entry.FileMode, exists: true}, nil
+	}
+
+	filePath := filepath.Join(applier.workTree, name)
+	fileInfo, err := os.Lstat(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error: %s: No such file or directory", name)
+		}
+		return nil, err
+	}
+	if fileInfo.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filePath)
+		return &applyFile{data: []byte(target), mode: 0120000, exists: true}, err
+	}
+	data, err := os.ReadFile(filePath)
+	return &applyFile{data: data, mode: common.IndexFileMode(fileInfo.Mode()), exists: true}, err
+}
+
+// Writing through a symlinked directory could land anywhere - earlier patches in the series, the index
+// and the work tree all get a say in whether a leading directory is one
+// Ref https://github.com/git/git/blob/master/apply.c (path_is_beyond_symlink)
+func (applier *applier) beyondSymlink(name string) bool {
+	for directory := path.Dir(name); directory != "."; directory = path.Dir(directory) {
+		if file, found := applier.files[directory]; found {
+			if file.exists && file.mode == 0120000 {
+				return true
+			}
+			continue
+		}
+		if applier.index != nil {
+			entry := applier.index.FindEntry(directory)
+			if entry != nil && entry.FileMode == 0120000 {
+				return true
+			}
+		}
+		if !applier.options.cached {
+			fileInfo, err := os.Lstat(filepath.Join(applier.workTree, filepath.FromSlash(directory)))
+			if err == nil && fileInfo.Mode()&os.ModeSymlink != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Files a patch creates can't be there already unless an earlier patch removed them
+func (applier *applier) checkNewPath(name string) error {
+	if file, found := applier.files[name]; found {
+		if file.exists {
+			return fmt.Errorf("error: %s: already exists in working directory", name)
+		}
+		return nil
+	}
+	if applier.index != nil && applier.index.FindEntry(name) != nil {
+		return fmt.Errorf("error: %s: already exists in index", name)
+	}
+	if applier.options.cached {
+		return nil
+	}
+	_, err := os.Lstat(filepath.Join(applier.workTree, name))
+	if err == nil {
+		return fmt.Errorf("error: %s: already exists in working directory", name)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Context has to match exactly - without --reject the first hunk that doesn't apply fails the patch
+func (applier *applier) applyHunks(patch *diff.FilePatch, name string, oldData []byte) ([]byte, []int, error) {
+	image, results := diff.ApplyHunks(diff.SplitLines(oldData), patch.Hunks)
+	var rejected []int
+	for i, result := range results {
+		hunk := patch.Hunks[i]
+		if result.Applied() {
+			if result.Offset != 0 && applier.options.verbose {
+				unit := "lines"
+				if result.Offset == 1 {
+					unit = "line"
+				}
+				fmt.Printf("Hunk #%d succeeded at %d (offset %d %s).\n", i+1, result.Line, result.Offset, unit)
+			}
+			continue
+		}
+
+		if applier.options.verbose {
+			var preimage strings.Builder
+			for _, line := range hunk.Lines {
+				if line.Operation != diff.Insert {
+					preimage.WriteString(line.Text)
+				}
+			}
+			fmt.Printf("error: while searching for:\n%s\n", preimage.String())
+		}
+		fmt.Printf("error: patch failed: %s:%d\n", name, hunk.OldStart)
+		if !applier.options.reject {
+			return nil, nil, errPatchFailed
+		}
+		rejected = append(rejected, i)
+	}
+	return []byte(strings.Join(image, "")), rejected, nil
+}
+
+// Apply the patch to the blob it was made against and merge that with what's there now - false
+// when there's nothing to merge or the blob isn't around so the patch gets applied directly instead
+func (applier *applier) tryThreeWay(result *appliedPatch, name string, ours []byte) (bool, error) {
+	patch := result.patch
+	added, deleted := patch.LineCounts()
+	binary := patch.IsBinary || patch.Binary != nil
+	if patch.IsNew || patch.IsDelete || (patch.IsRename && !binary && added == 0 && deleted == 0) {
+		return false, nil
+	}
+	baseHash, found := applier.findBlob(patch.OldHash)
+	if !found {
+		fmt.Println("error: repository lacks the necessary blob to perform 3-way merge.")
+		return false, nil
+	}
+	base, err := objects.ReadBlob(applier.repository, baseHash)
+	if err != nil {
+		return false, err
+	}
+
+	var theirData []byte
+	conflicts := 0
+	if binary {
+		theirData, err = applier.applyBinary(patch, name, base.Data)
+		if err != nil {
+			return false, nil
+		}
+		// Binary files can't be merged so one side has to have left the file alone
+		switch {
+		case bytes.Equal(ours, base.Data):
+			result.data = theirData
+		case bytes.Equal(theirData, base.Data) || bytes.Equal(ours, theirData):
+			result.data = ours
+		default:
+			fmt.Printf("warning: Cannot merge binary files: %s (ours vs. theirs)\n", result.newName)
+			result.data = ours
+			conflicts = 1
+		}
+	} else {
+		theirData, _, err = applier.applyHunks(patch, name, base.Data)
+		if err != nil {
+			return false, nil
+		}
+		var merged []string
+		merged, conflicts = diff.Merge(diff.SplitLines(base.Data), diff.SplitLines(ours), diff.SplitLines(theirData), "ours", "theirs")
+		result.data = []byte(strings.Join(merged, ""))
+	}
+	if conflicts == 0 {
+		fmt.Printf("Applied patch to '%s' cleanly.\n", result.newName)
+		return true, nil
+	}
+	fmt.Printf("Applied patch to '%s' with conflicts.\n", result.newName)
+
+	result.stages = []*common.IndexEntry{{Hash: baseHash}}
+	for _, data := range [][]byte{ours, theirData} {
+		hash, err := writeBlob(applier.repository, data)
+		if err != nil {
+			return false, err
+		}
+		result.stages = append(result.stages, &common.IndexEntry{Hash: hash})
+	}
+	for i, entry := range result.stages {
+		entry.EntryPath = result.newName
+		entry.FileMode = result.mode
+		entry.SetStage(i + 1)
+	}
+	return true, nil
+}
+
+// The one blob an abbreviated hash from an index line names
+func (applier *applier) findBlob(prefix string) (common.Hash, bool) {
+	if applier.repository == nil || len(prefix) < 4 {
+		return common.Hash{}, false
+	}
+	hashes, err := applier.repository.FindObjects(prefix)
+	if err != nil || len(hashes) != 1 {
+		return common.Hash{}, false
+	}
+	_, err = objects.ReadBlob(applier.repository, hashes[0])
+	return hashes[0], err == nil
+}
+
+// Binary patches name the exact blobs they go between so the current content has to be the old one
+// and the new one is taken from the repository when it's there, otherwise the patch data makes it
+func (applier *applier) applyBinary(patch *diff.FilePatch, name string, oldData []byte) ([]byte, error) {
+	oldHash, oldErr := common.ParseHash(patch.OldHash)
+	newHash, newErr := common.ParseHash(patch.NewHash)
+	if oldErr != nil || newErr != nil {
+		return nil, fmt.Errorf("error: cannot apply binary patch to '%s' without full index line", name)
+	}
+	if patch.IsNew {
+		if len(oldData) > 0 {
+			return nil, fmt.Errorf("error: the patch applies to an empty '%s' but it is not empty", name)
+		}
+	} else {
+		currentHash, err := blobHash(oldData)
+		if err != nil {
+			return nil, err
+		}
+		if currentHash != oldHash {
+			return nil, fmt.Errorf("error: the patch applies to '%s' (%s), which does not match the current contents.", name, currentHash)
+		}
+	}
+	if newHash.Empty() {
+		return nil, nil
+	}
+
+	if applier.repository != nil {
+		if blob, err := objects.ReadBlob(applier.repository, newHash); err == nil {
+			return blob.Data, nil
+		}
+	}
+	if patch.Binary == nil {
+		fmt.Printf("error: missing binary patch data for '%s'\n", name)
+		return nil, fmt.Errorf("error: binary patch does not apply to '%s'", name)
+	}
+	data, err := patch.Binary.Apply(oldData)
+	if err != nil {
+		return nil, fmt.Errorf("error: binary patch does not apply to '%s'", name)
+	}
+	resultHash, err := blobHash(data)
+	if err != nil {
+		return nil, err
+	}
+	if resultHash != newHash {
+		return nil, fmt.Errorf("error: binary patch to '%s' creates incorrect result (expecting %s, got %s)", name, newHash, resultHash)
+	}
+	return data, nil
+}
+
+func blobHash(data []byte) (common.Hash, error) {
+	blob := &objects.Blob{Data: data}
+	return common.HashObject(blob.Serialize())
+}
+
+func writeBlob(repository *common.Repository, data []byte) (common.Hash, error) {
+	blob := &objects.Blob{Data: data}
+	hash, err := common.HashObject(blob.Serialize())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return hash, repository.WriteObject(hash.String(), blob.Serialize())
+}
+
+func (applier *applier) remove(name string) error {
+	if applier.index != nil {
+		applier.index.RemoveEntry(name)
+	}
+	if applier.options.cached {
+		return nil
+	}
+	filePath := filepath.Join(applier.workTree, name)
+	err := os.Remove(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// Directories the file leaves empty go with it
+	for directory := filepath.Dir(filePath); directory != applier.workTree; directory = filepath.Dir(directory) {
+		if os.Remove(directory) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (applier *applier) write(result *appliedPatch) error {
+	filePath := filepath.Join(applier.workTree, result.newName)
+	var fileInfo os.FileInfo
+	if !applier.options.cached {
+		err := os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			return fmt.Errorf("error creating directory for %s: %v", result.newName, err)
+		}
+		// Remove whatever is there first so the new file's permissions come from the new mode
+		err = os.Remove(filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing %s: %v", filePath, err)
+		}
+		switch result.mode {
+		case 0120000:
+			err = os.Symlink(string(result.data), filePath)
+		case 0100755:
+			err = os.WriteFile(filePath, result.data, 0755)
+		default:
+			err = os.WriteFile(filePath, result.data, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s: %v", filePath, err)
+		}
+		fileInfo, err = os.Lstat(filePath)
+		if err != nil {
+			return err
+		}
+	}
+	if applier.index == nil {
+		return nil
+	}
+
+	if len(result.stages) > 0 {
+		applier.index.RemoveEntry(result.newName)
+		for _, entry := range result.stages {
+			applier.index.AddEntry(entry)
+		}
+		return nil
+	}
+	hash, err := writeBlob(applier.repository, result.data)
+	if err != nil {
+		return err
+	}
+	entry := &common.IndexEntry{
+		Hash:      hash,
+		FileMode:  result.mode,
+		EntryPath: result.newName,
+	}
+	// Only the content is known with --cached so the stat data stays empty and the file gets re-checked
+	if fileInfo != nil {
+		entry.ModifiedTime = fileInfo.ModTime()
+		entry.FileSize = uint32(fileInfo.Size())
+	}
+	applier.index.AddEntry(entry)
+	return nil
+}
+
+// Hunks that didn't apply go to <file>.rej so they can be sorted out by hand
+func (applier *applier) writeRejects(result *appliedPatch) error {
+	name := applier.displayName(result.patch)
+	if len(result.rejected) == 0 {
+		if applier.options.verbose {
+			fmt.Printf("Applied patch %s cleanly.\n", name)
+		}
+		return nil
+	}
+	unit := "rejects"
+	if len(result.rejected) == 1 {
+		unit = "reject"
+	}
+	fmt.Printf("Applying patch %s with %d %s...\n", name, len(result.rejected), unit)
+
+	oldName := result.oldName
+	if oldName == "" {
+		oldName = result.newName
+	}
+	var rejects strings.Builder
+	fmt.Fprintf(&rejects, "diff a/%s b/%s\t(rejected hunks)\n", oldName, result.newName)
+	for i, hunk := range result.patch.Hunks {
+		if !slices.Contains(result.rejected, i) {
+			fmt.Printf("Hunk #%d applied cleanly.\n", i+1)
+			continue
+		}
+		fmt.Printf("Rejected hunk #%d.\n", i+1)
+		rejects.WriteString(hunk.String())
+	}
+	return os.WriteFile(filepath.Join(applier.workTree, result.newName+".rej"), []byte(rejects.String()), 0644)
+}
+
+func printApplyUsage() {
+	fmt.Println("Usage: gitgood apply [--check] [--cached | --index] [-R] [--3way | --reject] [-v] [<patch>...]     Apply patches to the working tree and/or the index")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// A patch creating one file with a single line
+func newFilePatch(name string) string {
+	return "diff --git a/" + name + " b/" + name + "\n" +
+		"new file mode 100644\n" +
+		"--- /dev/null\n" +
+		"+++ b/" + name + "\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+evil\n"
+}
+
+func createApplyRepository(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	t.Chdir(root)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.Mkdir("repo", 0755); err != nil {
+		t.Fatalf("expected no error creating repo, got %v", err)
+	}
+	t.Chdir(filepath.Join(root, "repo"))
+	Init(nil)
+	return root
+}
+
+func applyTestPatch(t *testing.T, patch string, flags ...string) {
+	t.Helper()
+	patchFile := filepath.Join(t.TempDir(), "test.patch")
+	writeTestFile(t, patchFile, patch)
+	Apply(append(flags, patchFile))
+}
+
+func TestApplyRejectsPathsOutsideTheWorkTree(t *testing.T) {
+	root := createApplyRepository(t)
+	head, err := os.ReadFile(".gitgood/HEAD")
+	if err != nil {
+		t.Fatalf("expected no error reading HEAD, got %v", err)
+	}
+
+	for _, flags := range [][]string{nil, {"--index"}, {"--cached"}} {
+		applyTestPatch(t, newFilePatch("../evil"), flags...)
+		if _, err := os.Lstat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+			t.Fatalf("%v: expected ../evil not to be written, got %v", flags, err)
+		}
+
+		absolute := filepath.Join(root, "absolute")
+		applyTestPatch(t, newFilePatch(absolute), flags...)
+		if _, err := os.Lstat(absolute); !os.IsNotExist(err) {
+			t.Fatalf("%v: expected %s not to be written, got %v", flags, absolute, err)
+		}
+
+		for _, name := range []string{"a//b", "a/./b", "a/../b", ".gitgood/hooks/x", "sub/.git/config"} {
+			applyTestPatch(t, newFilePatch(name), flags...)
+		}
+
+		applyTestPatch(t, "diff --git a/.gitgood/HEAD b/.gitgood/HEAD\n"+
+			"--- a/.gitgood/HEAD\n"+
+			"+++ b/.gitgood/HEAD\n"+
+			"@@ -1 +1 @@\n"+
+			"-"+string(head)+
+			"+ref: refs/heads/pwn\n", flags...)
+	}
+
+	if data, err := os.ReadFile(".gitgood/HEAD"); err != nil || string(data) != string(head) {
+		t.Fatalf("expected HEAD to be left alone, got %q %v", data, err)
+	}
+	if _, err := os.Lstat(".gitgood/hooks/x"); !os.IsNotExist(err) {
+		t.Fatalf("expected .gitgood/hooks/x not to be written, got %v", err)
+	}
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil || len(index.Entries) != 0 {
+		t.Fatalf("expected nothing staged, got %v %v", index, err)
+	}
+	entries, err := os.ReadDir(".")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only .gitgood in the work tree, got %v %v", entries, err)
+	}
+}
+
+func TestApplyRefusesToWriteThroughSymlinks(t *testing.T) {
+	root := createApplyRepository(t)
+	outside := filepath.Join(root, "outside")
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatalf("expected no error creating %s, got %v", outside, err)
+	}
+	if err := os.Symlink(outside, "link"); err != nil {
+		t.Fatalf("expected no error creating link, got %v", err)
+	}
+
+	applyTestPatch(t, newFilePatch("link/evil"))
+	applyTestPatch(t, newFilePatch("link/evil"), "--index")
+	// A symlink an earlier patch in the same series creates counts too
+	applyTestPatch(t, "diff --git a/other b/other\n"+
+		"new file mode 120000\n"+
+		"--- /dev/null\n"+
+		"+++ b/other\n"+
+		"@@ -0,0 +1 @@\n"+
+		"+"+outside+"\n"+
+		"\\ No newline at end of file\n"+
+		newFilePatch("other/evil"))
+
+	if _, err := os.Lstat(filepath.Join(outside, "evil")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written through the symlink, got %v", err)
+	}
+	if _, err := os.Lstat("other"); !os.IsNotExist(err) {
+		t.Fatalf("expected the series to be refused as a whole, got %v", err)
+	}
+}
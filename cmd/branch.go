@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+func Branch(flags []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch {
+	case len(flags) == 0 || (len(flags) == 1 && (flags[0] == "--list" || flags[0] == "-v")):
+		verbose := len(flags) == 1 && flags[0] == "-v"
+		err = listBranches(repository, verbose)
+	case flags[0] == "-d" || flags[0] == "-D":
+		if len(flags) < 2 {
+			printBranchUsage()
+			return
+		}
+		for _, branch := range flags[1:] {
+			err = deleteBranch(repository, branch, flags[0] == "-D")
+			if err != nil {
+				break
+			}
+		}
+	case flags[0] == "-m":
+		switch len(flags) {
+		case 2:
+			// Rename the current branch
+			var currentBranch string
+			currentBranch, err = repository.GetBranch()
+			if err == nil {
+				err = renameBranch(repository, currentBranch, flags[1])
+			}
+		case 3:
+			err = renameBranch(repository, flags[1], flags[2])
+		default:
+			printBranchUsage()
+			return
+		}
+	case len(flags) <= 2 && flags[0][0] != '-':
+		startPoint := "HEAD"
+		if len(flags) == 2 {
+			startPoint = flags[1]
+		}
+		err = createBranch(repository, flags[0], startPoint)
+	default:
+		printBranchUsage()
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+func listBranches(repository *common.Repository, verbose bool) error {
+	currentBranch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	branches, err := repository.ListBranches()
+	if err != nil {
+		return err
+	}
+
+	nameWidth := 0
+	for _, branch := range branches {
+		nameWidth = max(nameWidth, len(branch.Name))
+	}
+
+	// A detached HEAD gets listed first as a pseudo branch
+	if currentBranch == "" {
+		headHash, err := repository.ResolveHead()
+		if err != nil {
+			return err
+		}
+		detached := &common.Ref{
+			Name: fmt.Sprintf("(HEAD detached at %s)", headHash.String()[:7]),
+			Hash: headHash,
+		}
+		branches = append([]*common.Ref{detached}, branches...)
+		nameWidth = max(nameWidth, len(detached.Name))
+		currentBranch = detached.Name
+	}
+	for _, branch := range branches {
+		marker := " "
+		if branch.Name == currentBranch {
+			marker = "*"
+		}
+		if !verbose {
+			fmt.Printf("%s %s\n", marker, branch.Name)
+			continue
+		}
+
+		commit, err := objects.ReadCommit(repository, branch.Hash)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %-*s %s %s\n", marker, nameWidth, branch.Name, branch.Hash.String()[:7], commit.Subject())
+	}
+	return nil
+}
+
+func createBranch(repository *common.Repository, branch string, startPoint string) error {
+	if !common.IsValidBranchName(branch) {
+		return fmt.Errorf("fatal: '%s' is not a valid branch name", branch)
+	}
+	if repository.BranchExists(branch) {
+		return fmt.Errorf("fatal: a branch named '%s' already exists", branch)
+	}
+	startHash, err := resolveCommit(repository, startPoint)
+	if err != nil {
+		return err
+	}
+	return repository.UpdateBranch(branch, common.Hash{}, startHash, "branch: Created from "+startPoint)
+}
+
+// Refuse to delete branches whose commits aren't reachable from HEAD unless forced
+func deleteBranch(repository *common.Repository, branch string, force bool) error {
+	if !repository.BranchExists(branch) {
+		return fmt.Errorf("error: branch '%s' not found", branch)
+	}
+	currentBranch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	if branch == currentBranch {
+		return fmt.Errorf("error: cannot delete branch '%s' checked out", branch)
+	}
+	ref, err := repository.FindRef(branch)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		merged := false
+		headHash, err := resolveCommit(repository, "HEAD")
+		if err == nil {
+			merged, err = objects.IsAncestor(repository, ref.Hash, headHash)
+			if err != nil {
+				return err
+			}
+		}
+		if !merged {
+			return fmt.Errorf("error: the branch '%s' is not fully merged\nIf you are sure you want to delete it, run 'gitgood branch -D %s'", branch, branch)
+		}
+	}
+
+	err = repository.DeleteBranch(branch)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted branch %s (was %s).\n", branch, ref.Hash.String()[:7])
+	return nil
+}
+
+func renameBranch(repository *common.Repository, oldBranch, newBranch string) error {
+	if !repository.BranchExists(oldBranch) {
+		return fmt.Errorf("error: no branch named '%s'", oldBranch)
+	}
+	if !common.IsValidBranchName(newBranch) {
+		return fmt.Errorf("fatal: '%s' is not a valid branch name", newBranch)
+	}
+	if repository.BranchExists(newBranch) {
+		return fmt.Errorf("fatal: a branch named '%s' already exists", newBranch)
+	}
+
+	ref, err := repository.FindRef(oldBranch)
+	if err != nil {
+		return err
+	}
+	// The history of the branch moves along with it and goes back if the ref can't be moved
+	err = repository.RenameReflog("refs/heads/"+oldBranch, "refs/heads/"+newBranch)
+	if err != nil {
+		return err
+	}
+	// The new ref is written before the old one goes in a single transaction so a failure can't lose the branch
+	message := fmt.Sprintf("Branch: renamed refs/heads/%s to refs/heads/%s", oldBranch, newBranch)
+	transaction := repository.NewRefTransaction()
+	transaction.Add(&common.RefUpdate{Name: "refs/heads/" + newBranch, NewHash: ref.Hash, CheckOld: true, Message: message})
+	transaction.Add(&common.RefUpdate{Name: "refs/heads/" + oldBranch, OldHash: ref.Hash, CheckOld: true})
+	err = transaction.Commit()
+	if err != nil {
+		repository.RenameReflog("refs/heads/"+newBranch, "refs/heads/"+oldBranch)
+		return fmt.Errorf("error: unable to rename branch '%s': %v", oldBranch, err)
+	}
+
+	currentBranch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	if currentBranch != oldBranch {
+		return nil
+	}
+	err = repository.SetHeadBranch(newBranch)
+	if err != nil {
+		return err
+	}
+	return repository.AppendReflog("HEAD", ref.Hash, ref.Hash, message)
+}
+
+// Resolve any revision expression to a commit, ie HEAD, a branch, a tag or an abbreviated hash
+func resolveCommit(repository *common.Repository, revision string) (common.Hash, error) {
+	hash, err := objects.ResolveCommitish(repository, revision)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("fatal: %v", err)
+	}
+	return hash, nil
+}
+
+func printBranchUsage() {
+	fmt.Println("Usage: gitgood branch [-v]                          List branches, marking the current branch")
+	fmt.Println("Usage: gitgood branch <name> [<start-point>]        Create a branch at HEAD or the start point")
+	fmt.Println("Usage: gitgood branch (-d | -D) <name>...           Delete branches, -D skips the merged check")
+	fmt.Println("Usage: gitgood branch -m [<old-name>] <new-name>    Rename a branch")
+}
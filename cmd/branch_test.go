@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func createBranchRepository(t *testing.T) (*common.Repository, common.Hash) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Init(nil)
+	writeTestFile(t, "a", "a\n")
+	Add([]string{"a"})
+	Commit([]string{"-m", "first"})
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	head, err := repository.ResolveHead()
+	if err != nil {
+		t.Fatalf("expected no error resolving HEAD, got %v", err)
+	}
+	return repository, head
+}
+
+func TestRenameBranchMovesRefAndReflog(t *testing.T) {
+	repository, head := createBranchRepository(t)
+	Branch([]string{"-m", "renamed"})
+
+	expectBranch(t, repository, "renamed")
+	if repository.BranchExists("main") {
+		t.Fatalf("expected main to be gone")
+	}
+	if ref, err := repository.FindRef("renamed"); err != nil || ref.Hash != head {
+		t.Fatalf("expected renamed at %s, got %v %v", head, ref, err)
+	}
+	entries, err := repository.ReadReflog("refs/heads/renamed")
+	if err != nil || len(entries) != 2 || entries[0].Message != "commit (initial): first" {
+		t.Fatalf("expected the reflog of main plus the rename, got %v %v", entries, err)
+	}
+	if repository.ReflogExists("refs/heads/main") {
+		t.Fatalf("expected the reflog of main to be moved")
+	}
+}
+
+func TestRenameBranchKeepsTheBranchWhenItFails(t *testing.T) {
+	repository, head := createBranchRepository(t)
+	Branch([]string{"other"})
+	// Somebody else holding the new name's lock stops the rename part way
+	if err := os.WriteFile(".gitgood/refs/heads/renamed.lock", nil, 0644); err != nil {
+		t.Fatalf("expected no error creating the lock, got %v", err)
+	}
+	Branch([]string{"-m", "other", "renamed"})
+
+	if ref, err := repository.FindRef("other"); err != nil || ref.Hash != head {
+		t.Fatalf("expected other to stay at %s, got %v %v", head, ref, err)
+	}
+	if repository.BranchExists("renamed") {
+		t.Fatalf("expected renamed not to be created")
+	}
+	if entries, err := repository.ReadReflog("refs/heads/other"); err != nil || len(entries) != 1 {
+		t.Fatalf("expected the reflog of other to stay, got %v %v", entries, err)
+	}
+}
@@ -14,18 +14,18 @@ func CatFile(flags []string) {
 		printCatFileUsage()
 		return
 	}
-	objectHash := flags[0]
-	if len(objectHash) != 40 {
-		fmt.Printf("invalid hash length: expected length 40 got %v\n", len(objectHash))
-		return
-	}
 	repository, err := common.FindRepository(".")
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
+	hash, err := objects.ResolveRevision(repository, flags[0])
+	if err != nil {
+		fmt.Printf("fatal: %v\n", err)
+		return
+	}
 
-	rawObjectData, err := repository.ReadObject(objectHash)
+	rawObjectData, err := repository.ReadObject(hash.String())
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
@@ -49,7 +49,7 @@ func CatFile(flags []string) {
 		content := string(rawObjectData[nullIndex+1:])
 		fmt.Printf("%v", content)
 	case "tree":
-		LsTree(flags)
+		LsTree([]string{hash.String()})
 	case "commit":
 		content := rawObjectData[nullIndex:]
 		commit, err := objects.ParseCommit(content)
@@ -57,12 +57,13 @@ func CatFile(flags []string) {
 			fmt.Printf("%v\n", err)
 		}
 		utcOffset := fmt.Sprintf("%d %s", commit.Timestamp.Unix(), commit.Timestamp.Format("-0700"))
-		commitString := fmt.Sprintf("tree %s\nauthor %s %s\ncommitter %v %s\n", commit.Tree.Hash.String(), commit.Author, utcOffset, commit.Author, utcOffset)
+		committerOffset := fmt.Sprintf("%d %s", commit.CommitterTime.Unix(), commit.CommitterTime.Format("-0700"))
+		commitString := fmt.Sprintf("tree %s\nauthor %s %s\ncommitter %v %s\n", commit.Tree.Hash.String(), commit.Author, utcOffset, commit.Committer, committerOffset)
 		fmt.Println(commitString)
 
 	}
 }
 
 func printCatFileUsage() {
-	fmt.Println("Usage: gitgood cat-file <object-hash>          Print the file contents")
+	fmt.Println("Usage: gitgood cat-file <object>          Print the contents of an object (any revision expression)")
 }
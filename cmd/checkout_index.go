@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+func CheckoutIndex(flags []string) {
+	all := false
+	force := false
+	prefix := ""
+	var files []string
+	for i, flag := range flags {
+		if flag == "--" {
+			files = append(files, flags[i+1:]...)
+			break
+		}
+		switch {
+		case flag == "-a" || flag == "--all":
+			all = true
+		case flag == "-f" || flag == "--force":
+			force = true
+		case strings.HasPrefix(flag, "--prefix="):
+			prefix = strings.TrimPrefix(flag, "--prefix=")
+		case strings.HasPrefix(flag, "-"):
+			fmt.Println("Unsupported flag...")
+			printCheckoutIndexUsage()
+			return
+		default:
+			files = append(files, flag)
+		}
+	}
+	if all == (len(files) > 0) {
+		printCheckoutIndexUsage()
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	var entries []*common.IndexEntry
+	if all {
+		for _, entry := range index.Entries {
+			// Unmerged, sparse and intent to add entries have nothing to write
+			if entry.Stage() != 0 || entry.SkipWorktree() || entry.IntentToAdd() {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	for _, file := range files {
+		absolutePath, err := filepath.Abs(file)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		indexEntryRelativePath, err := filepath.Rel(repository.WorkTree, absolutePath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		entry := index.FindEntry(indexEntryRelativePath)
+		if entry == nil {
+			fmt.Printf("error: %s is not in the cache\n", file)
+			continue
+		}
+		if entry.Stage() != 0 {
+			fmt.Printf("error: %s is unmerged\n", file)
+			continue
+		}
+		// Only the path is staged so there's no content to replace the file with
+		if entry.IntentToAdd() {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, entry := range entries {
+		targetPath := checkoutTarget(repository, entry, prefix)
+		if !force {
+			_, err := os.Lstat(targetPath)
+			if err == nil {
+				// Files already matching the index aren't worth a warning
+				if prefix == "" {
+					clean, err := worktreeMatchesEntry(repository, index, entry)
+					if err == nil && clean {
+						continue
+					}
+				}
+				fmt.Printf("%s already exists, no checkout\n", prefix+entry.EntryPath)
+				continue
+			}
+		}
+
+		err = checkoutEntry(repository, entry, prefix)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+
+	// Stat data only changes when writing to the working tree itself
+	if prefix == "" {
+		err = common.WriteIndex(repository, index)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+}
+
+// Write the entry's blob to the working tree (or under the prefix) as a symlink or a file with the
+// executable bit set from its mode - gitlinks get an empty directory where the submodule would go
+// Entries written to the working tree itself get their stat data refreshed so they compare clean afterwards
+func checkoutEntry(repository *common.Repository, entry *common.IndexEntry, prefix string) error {
+	targetPath := checkoutTarget(repository, entry, prefix)
+	if entry.FileMode == 0160000 {
+		fileInfo, err := os.Lstat(targetPath)
+		if err == nil && fileInfo.IsDir() {
+			return nil
+		}
+		if err == nil {
+			err = os.Remove(targetPath)
+			if err != nil {
+				return fmt.Errorf("error removing %s: %v", targetPath, err)
+			}
+		}
+		err = os.MkdirAll(targetPath, 0755)
+		if err != nil {
+			return fmt.Errorf("cannot create submodule directory %s: %v", entry.EntryPath, err)
+		}
+		return nil
+	}
+
+	blob, err := objects.ReadBlob(repository, entry.Hash)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(targetPath), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating directory for %s: %v", entry.EntryPath, err)
+	}
+
+	// Remove whatever is there first so the new file's permissions come from the entry mode
+	err = os.Remove(targetPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing %s: %v", targetPath, err)
+	}
+	switch entry.FileMode {
+	case 0120000:
+		err = os.Symlink(string(blob.Data), targetPath)
+	case 0100755:
+		err = os.WriteFile(targetPath, blob.Data, 0755)
+	default:
+		err = os.WriteFile(targetPath, blob.Data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", targetPath, err)
+	}
+
+	if prefix == "" {
+		fileInfo, err := os.Lstat(targetPath)
+		if err != nil {
+			return err
+		}
+		entry.ModifiedTime = fileInfo.ModTime()
+		entry.FileSize = uint32(fileInfo.Size())
+	}
+	return nil
+}
+
+// Like git the prefix is prepended to the path as is, so an absolute one exports outside the work tree
+// and a relative one is taken from the top of it
+func checkoutTarget(repository *common.Repository, entry *common.IndexEntry, prefix string) string {
+	if filepath.IsAbs(prefix) {
+		return filepath.FromSlash(prefix + entry.EntryPath)
+	}
+	return filepath.Join(repository.WorkTree, filepath.FromSlash(prefix+entry.EntryPath))
+}
+
+func printCheckoutIndexUsage() {
+	fmt.Println("Usage: gitgood checkout-index [-f] [--prefix=<dir>/] <filename>...     Write files from the index to the working tree")
+	fmt.Println("Usage: gitgood checkout-index -a [-f] [--prefix=<dir>/]                 Write every file in the index to the working tree")
+}
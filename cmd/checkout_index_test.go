@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCheckoutIndexWritesSymlinksAndGitlinks(t *testing.T) {
+	createUpdateIndexRepository(t)
+	// The link's blob holds where it points
+	writeTestFile(t, "link-blob", "target")
+	HashObject([]string{"-w", "link-blob"})
+	UpdateIndex([]string{"--cacheinfo", "120000,1de565933b05f74c75ff9a6520af5f9f8a5a2f1d,link"})
+	UpdateIndex([]string{"--cacheinfo", "160000,1111111111111111111111111111111111111111,module"})
+	UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + ",file"})
+
+	CheckoutIndex([]string{"-a"})
+	if target, err := os.Readlink("link"); err != nil || target != "target" {
+		t.Fatalf("expected link to point at target, got %q %v", target, err)
+	}
+	if fileInfo, err := os.Lstat("module"); err != nil || !fileInfo.IsDir() {
+		t.Fatalf("expected an empty directory for the gitlink, got %v %v", fileInfo, err)
+	}
+	if data, err := os.ReadFile("file"); err != nil || len(data) != 0 {
+		t.Fatalf("expected the file after the gitlink to be written, got %q %v", data, err)
+	}
+}
+
+func TestCheckoutIndexSkipsIntentToAddEntries(t *testing.T) {
+	createUpdateIndexRepository(t)
+	writeTestFile(t, "new", "work in progress\n")
+	Add([]string{"-N", "new"})
+
+	CheckoutIndex([]string{"-f", "new"})
+	if data, err := os.ReadFile("new"); err != nil || string(data) != "work in progress\n" {
+		t.Fatalf("expected the file to be left alone, got %q %v", data, err)
+	}
+}
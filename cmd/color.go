@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// Color settings are always, never or auto - booleans work too with true meaning auto like git
+func parseColorWhen(value string) (string, bool) {
+	switch strings.ToLower(value) {
+	case "always", "never", "auto":
+		return strings.ToLower(value), true
+	case "true", "yes", "on", "1":
+		return "auto", true
+	case "false", "no", "off", "0":
+		return "never", true
+	}
+	return "", false
+}
+
+// Flags for --color and --no-color - handled is false for anything else
+func parseColorFlag(flag string, when *string) (handled bool, err error) {
+	switch {
+	case flag == "--color":
+		*when = "always"
+	case flag == "--no-color":
+		*when = "never"
+	case strings.HasPrefix(flag, "--color="):
+		value, ok := parseColorWhen(strings.TrimPrefix(flag, "--color="))
+		if !ok {
+			return true, fmt.Errorf("error: option `color' expects \"always\", \"auto\", or \"never\"")
+		}
+		*when = value
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// Decide whether to color output when the command line didn't say: color.diff wins over color.ui
+// and auto means only when printing to a terminal
+func useColor(config *common.Config, when string) (bool, error) {
+	if when == "" {
+		when = "auto"
+		for _, key := range []string{"color.diff", "color.ui"} {
+			value, found := config.Get(key)
+			if !found {
+				continue
+			}
+			setting, ok := parseColorWhen(value)
+			if !ok {
+				return false, fmt.Errorf("fatal: bad boolean config value '%s' for '%s'", value, key)
+			}
+			when = setting
+			break
+		}
+	}
+	switch when {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	}
+	return stdoutIsTerminal(), nil
+}
+
+// Dumb terminals can't show escape codes so they count as no terminal at all
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	term := os.Getenv("TERM")
+	return term != "" && term != "dumb"
+}
+
+// A color from the config under key or the default when it isn't set
+func configColor(config *common.Config, key, defaultValue string) (string, error) {
+	value, found := config.Get(key)
+	if !found {
+		value = defaultValue
+	}
+	color, err := common.ParseColor(value)
+	if err != nil {
+		return "", fmt.Errorf("error: invalid color value: %s\nfatal: bad config variable '%s'", value, key)
+	}
+	return color, nil
+}
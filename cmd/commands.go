@@ -25,7 +25,45 @@ func Execute(command string, flags []string) {
 	case "commit":
 		Commit(flags)
 	case "log":
-		Log()
+		Log(flags)
+	case "branch":
+		Branch(flags)
+	case "switch":
+		Switch(flags)
+	case "checkout":
+		Checkout(flags)
+	case "read-tree":
+		ReadTree(flags)
+	case "checkout-index":
+		CheckoutIndex(flags)
+	case "sparse-checkout":
+		SparseCheckout(flags)
+	case "pack-refs":
+		PackRefs(flags)
+	case "reflog":
+		Reflog(flags)
+	case "rev-parse":
+		RevParse(flags)
+	case "update-ref":
+		UpdateRef(flags)
+	case "symbolic-ref":
+		SymbolicRef(flags)
+	case "for-each-ref":
+		ForEachRef(flags)
+	case "show-ref":
+		ShowRef(flags)
+	case "status":
+		Status(flags)
+	case "diff":
+		Diff(flags)
+	case "diff-tree":
+		DiffTree(flags)
+	case "apply":
+		Apply(flags)
+	case "format-patch":
+		FormatPatch(flags)
+	case "am":
+		Am(flags)
 	default:
 		fmt.Println("Unsupported command...")
 		PrintUsage()
@@ -45,4 +83,23 @@ func PrintUsage() {
 	fmt.Println("ls-tree       List the contents of a tree object")
 	fmt.Println("commit        Record changes to the repository")
 	fmt.Println("log           Show commit logs")
+	fmt.Println("branch        List, create, or delete branches")
+	fmt.Println("switch        Switch branches")
+	fmt.Println("checkout      Switch branches or detach HEAD at a commit")
+	fmt.Println("read-tree     Read tree information into the index, optionally merging trees")
+	fmt.Println("checkout-index  Copy files from the index to the working tree")
+	fmt.Println("sparse-checkout Reduce the working tree to a subset of directories")
+	fmt.Println("pack-refs     Pack refs into a single packed-refs file")
+	fmt.Println("reflog        Show or prune the history of ref updates")
+	fmt.Println("rev-parse     Resolve revision expressions to object names")
+	fmt.Println("update-ref    Safely update, create or delete refs")
+	fmt.Println("symbolic-ref  Read, modify and delete symbolic refs")
+	fmt.Println("for-each-ref  Output information on each ref using a format string")
+	fmt.Println("show-ref      List references and the objects they point at")
+	fmt.Println("status        Show the working tree status")
+	fmt.Println("diff          Show changes between commits, the index and the working tree")
+	fmt.Println("diff-tree     Compare the content and mode of blobs found via two tree objects")
+	fmt.Println("apply         Apply a patch to files and/or to the index")
+	fmt.Println("format-patch  Prepare patches for e-mail submission")
+	fmt.Println("am            Apply a series of patches from a mailbox")
 }
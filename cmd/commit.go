@@ -1,12 +1,7 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/CLBRITTON2/go-git-good/common"
@@ -47,15 +42,15 @@ func Commit(flags []string) {
 	}
 	// Ensure the tree is written to the object DB
 	WriteTree([]string{"-q"})
-	author, email := parseGitConfig()
-	if author == "" {
-		author = "local user"
+	author, err := repository.Identity()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
 	}
-	authorString := fmt.Sprintf("%s <%s>", author, email)
 	commit := &objects.Commit{
 		Tree:      rootTree,
 		Parents:   []common.Hash{},
-		Author:    authorString,
+		Author:    author,
 		Timestamp: time.Now(),
 		Message:   message,
 	}
@@ -65,15 +60,15 @@ func Commit(flags []string) {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	// Empty ref is returned if this is the first commit
-	ref, err := repository.FindRef(branch)
+	// Empty hash is returned if this is the first commit
+	headHash, err := repository.ResolveHead()
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
 
-	if !ref.Hash.Empty() {
-		commit.Parents = []common.Hash{ref.Hash}
+	if !headHash.Empty() {
+		commit.Parents = []common.Hash{headHash}
 	}
 
 	serializedCommitData := commit.Serialize()
@@ -82,59 +77,29 @@ func Commit(flags []string) {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	ref.Hash = commitHash
-	repository.WriteRef(ref, branch)
-	repository.WriteObject(commitHash.String(), serializedCommitData)
-}
-
-// This might need to be moved to a more accessible location
-func parseGitConfig() (name, email string) {
-	userHomeDirectory, err := os.UserHomeDir()
+	err = repository.WriteObject(commitHash.String(), serializedCommitData)
 	if err != nil {
-		fmt.Printf("error locating user home directory: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
-	configLocations := []string{
-		filepath.Join(userHomeDirectory, ".gitconfig"),
-		filepath.Join(userHomeDirectory, ".config", "git", "config"),
+	reflogMessage := "commit: " + commit.Subject()
+	if headHash.Empty() {
+		reflogMessage = "commit (initial): " + commit.Subject()
 	}
-
-	var fileContent []byte
-	found := false
-
-	for _, config := range configLocations {
-		fileInfo, err := os.ReadFile(config)
-		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				continue
-			}
-			fmt.Printf("error reading config file %s: %v\n", config, err)
-			continue
+	// A detached HEAD moves on its own without any branch following it
+	if branch == "" {
+		err = repository.SetHeadDetached(commitHash)
+		if err == nil {
+			err = repository.AppendReflog("HEAD", headHash, commitHash, reflogMessage)
 		}
-		fileContent = fileInfo
-		found = true
-		break
-	}
-
-	if !found {
-		return "", ""
+	} else {
+		err = repository.UpdateBranch(branch, headHash, commitHash, reflogMessage)
 	}
-
-	nameRegex := regexp.MustCompile(`name\s*=\s*(.+)`)
-	emailRegex := regexp.MustCompile(`email\s*=\s*(.+)`)
-
-	nameMatch := nameRegex.FindStringSubmatch(string(fileContent))
-	if len(nameMatch) > 1 {
-		name = strings.TrimSpace(nameMatch[1])
-	}
-
-	emailMatch := emailRegex.FindStringSubmatch(string(fileContent))
-	if len(emailMatch) > 1 {
-		email = strings.TrimSpace(emailMatch[1])
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
 	}
-
-	return name, email
 }
 
 func printCommitUsage() {
@@ -0,0 +1,634 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/diff"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+type diffOptions struct {
+	// Unchanged lines shown around each change
+	context   int
+	algorithm diff.Algorithm
+	// Set when a flag picked the algorithm so diff.algorithm doesn't override it
+	algorithmFromFlag bool
+
+	// always, never or auto from the command line, empty leaves it to the config
+	colorWhen string
+	color     bool
+	// Escape codes by color.diff slot, all empty without color
+	colors        map[string]string
+	moved         diff.MovedMode
+	movedFromFlag bool
+	// plain, color or porcelain when showing changed words instead of lines
+	wordDiff          string
+	wordRegex         string
+	wordRegexFromFlag bool
+	wordPattern       *regexp.Regexp
+	// Write binary changes as data git apply can use instead of just saying they differ
+	binary bool
+}
+
+func newDiffOptions() *diffOptions {
+	return &diffOptions{context: 3}
+}
+
+// Flags shared by every command that prints patches - handled is false for anything else
+func (options *diffOptions) parseFlag(flag string) (handled bool, err error) {
+	switch {
+	case strings.HasPrefix(flag, "-U") || strings.HasPrefix(flag, "--unified="):
+		context, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(flag, "--unified="), "-U"))
+		if err != nil || context < 0 {
+			return true, fmt.Errorf("fatal: invalid context length: %s", flag)
+		}
+		options.context = context
+	case strings.HasPrefix(flag, "--diff-algorithm="):
+		algorithm, err := diff.ParseAlgorithm(strings.TrimPrefix(flag, "--diff-algorithm="))
+		if err != nil {
+			return true, fmt.Errorf("error: option diff-algorithm accepts \"myers\", \"minimal\", \"patience\" and \"histogram\"")
+		}
+		options.setAlgorithm(algorithm)
+	case flag == "--binary":
+		options.binary = true
+	case flag == "--minimal":
+		options.setAlgorithm(diff.MinimalAlgorithm)
+	case flag == "--patience":
+		options.setAlgorithm(diff.PatienceAlgorithm)
+	case flag == "--histogram":
+		options.setAlgorithm(diff.HistogramAlgorithm)
+	case flag == "--color-moved" || strings.HasPrefix(flag, "--color-moved="):
+		mode, _ := strings.CutPrefix(flag, "--color-moved=")
+		if flag == "--color-moved" {
+			mode = "default"
+		}
+		moved, err := diff.ParseMovedMode(mode)
+		if err != nil {
+			return true, fmt.Errorf("error: %v\nerror: bad --color-moved argument: %s", err, mode)
+		}
+		options.moved = moved
+		options.movedFromFlag = true
+	case flag == "--no-color-moved":
+		options.moved = diff.NoMoved
+		options.movedFromFlag = true
+	case flag == "--word-diff" || strings.HasPrefix(flag, "--word-diff="):
+		mode, _ := strings.CutPrefix(flag, "--word-diff=")
+		if flag == "--word-diff" {
+			mode = "plain"
+		}
+		switch mode {
+		case "plain", "porcelain":
+		case "color":
+			options.colorWhen = "always"
+		case "none":
+			mode = ""
+		default:
+			return true, fmt.Errorf("error: bad --word-diff argument: %s", mode)
+		}
+		options.wordDiff = mode
+	case strings.HasPrefix(flag, "--word-diff-regex="):
+		// A pattern on its own asks for a plain word diff
+		options.wordDiff = cmp.Or(options.wordDiff, "plain")
+		options.wordRegex = strings.TrimPrefix(flag, "--word-diff-regex=")
+		options.wordRegexFromFlag = true
+	case flag == "--color-words" || strings.HasPrefix(flag, "--color-words="):
+		options.wordDiff = "color"
+		options.colorWhen = "always"
+		if regex, found := strings.CutPrefix(flag, "--color-words="); found {
+			options.wordRegex = regex
+			options.wordRegexFromFlag = true
+		}
+	default:
+		return parseColorFlag(flag, &options.colorWhen)
+	}
+	return true, nil
+}
+
+func (options *diffOptions) setAlgorithm(algorithm diff.Algorithm) {
+	options.algorithm = algorithm
+	options.algorithmFromFlag = true
+}
+
+// Config only fills in what wasn't given on the command line
+func (options *diffOptions) readConfig(repository *common.Repository) error {
+	config, err := repository.Config()
+	if err != nil {
+		return err
+	}
+	if name, found := config.Get("diff.algorithm"); found && !options.algorithmFromFlag {
+		algorithm, err := diff.ParseAlgorithm(name)
+		if err != nil {
+			return fmt.Errorf("error: unknown value for config 'diff.algorithm': %s", name)
+		}
+		options.algorithm = algorithm
+	}
+	if value, found := config.Get("diff.colorMoved"); found && !options.movedFromFlag {
+		moved, err := diff.ParseMovedMode(value)
+		if err != nil {
+			return fmt.Errorf("error: %v\nfatal: bad config variable 'diff.colormoved'", err)
+		}
+		options.moved = moved
+	}
+	if value, found := config.Get("diff.wordRegex"); found && !options.wordRegexFromFlag {
+		options.wordRegex = value
+	}
+	if options.wordDiff != "" && options.wordRegex != "" {
+		options.wordPattern, err = diff.CompileWordPattern(options.wordRegex)
+		if err != nil {
+			return fmt.Errorf("fatal: invalid regular expression: %s", options.wordRegex)
+		}
+	}
+
+	options.color, err = useColor(config, options.colorWhen)
+	if err != nil || !options.color {
+		return err
+	}
+	return options.readColors(config)
+}
+
+// One side of a file pair - a zero mode means the file doesn't exist on that side
+// Sides from the object database are read lazily since most never need their content
+type diffSide struct {
+	mode   uint32
+	hash   common.Hash
+	data   []byte
+	loaded bool
+}
+
+// A file to print as a patch - both sides share the path since renames aren't detected
+type filePatch struct {
+	path string
+	old  diffSide
+	new  diffSide
+}
+
+func Diff(flags []string) {
+	options := newDiffOptions()
+	cached := false
+	separated := false
+	var arguments, pathspecs []string
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		switch {
+		case flag == "--":
+			pathspecs = append(pathspecs, flags[i+1:]...)
+			separated = true
+			i = len(flags)
+		case flag == "--cached" || flag == "--staged":
+			cached = true
+		case strings.HasPrefix(flag, "-"):
+			handled, err := options.parseFlag(flag)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			if handled {
+				continue
+			}
+			fmt.Println("Unsupported flag...")
+			printDiffUsage()
+			return
+		default:
+			arguments = append(arguments, flag)
+		}
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	err = options.readConfig(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	trees, argumentPathspecs, err := splitDiffArguments(repository, arguments, separated)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	pathspecs, err = normalizePathspecs(repository, append(argumentPathspecs, pathspecs...))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	output := newPatchWriter(options)
+	switch {
+	case cached && len(trees) <= 1:
+		err = diffTreeToIndex(repository, trees, pathspecs, output)
+	case cached:
+		printDiffUsage()
+		return
+	case len(trees) == 0:
+		err = diffIndexToWorktree(repository, pathspecs, output)
+	case len(trees) == 1:
+		err = diffTreeToWorktree(repository, trees[0], pathspecs, output)
+	case len(trees) == 2:
+		err = diffTreeToTree(repository, trees[0], trees[1], pathspecs, output)
+	default:
+		printDiffUsage()
+		return
+	}
+	// Whatever came before an error is still shown
+	output.flush()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// Leading arguments that name commits or trees are what to compare, anything after them is a path
+// With "--" every argument before it has to be a revision
+// Returns the tree hashes, with a..b standing for a and b
+func splitDiffArguments(repository *common.Repository, arguments []string, separated bool) ([]common.Hash, []string, error) {
+	var trees []common.Hash
+	for i, argument := range arguments {
+		revisions := []string{argument}
+		if from, to, isRange := strings.Cut(argument, ".."); isRange && !strings.HasPrefix(to, ".") {
+			revisions = []string{cmp.Or(from, "HEAD"), cmp.Or(to, "HEAD")}
+		}
+
+		var resolved []common.Hash
+		for _, revision := range revisions {
+			hash, err := objects.ResolveRevision(repository, revision)
+			if err == nil {
+				hash, err = objects.PeelRevision(repository, hash, "tree")
+			}
+			if err != nil {
+				if separated {
+					return nil, nil, fmt.Errorf("fatal: %v", err)
+				}
+				return trees, arguments[i:], nil
+			}
+			resolved = append(resolved, hash)
+		}
+		trees = append(trees, resolved...)
+	}
+	return trees, nil, nil
+}
+
+// Pathspecs are given relative to the current directory and compared against paths from the top of the work tree
+func normalizePathspecs(repository *common.Repository, pathspecs []string) ([]string, error) {
+	normalized := make([]string, 0, len(pathspecs))
+	for _, pathspec := range pathspecs {
+		entryPath, err := indexPath(repository, pathspec)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(entryPath, "..") {
+			return nil, fmt.Errorf("fatal: %s: '%s' is outside repository", pathspec, pathspec)
+		}
+		normalized = append(normalized, filepath.ToSlash(entryPath))
+	}
+	return normalized, nil
+}
+
+// No pathspecs matches everything, otherwise a path matches a pathspec naming it or one of its directories
+func matchesPathspecs(entryPath string, pathspecs []string) bool {
+	if len(pathspecs) == 0 {
+		return true
+	}
+	for _, pathspec := range pathspecs {
+		if pathspec == "." || entryPath == pathspec || strings.HasPrefix(entryPath, pathspec+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes in the working tree that haven't been staged yet
+func diffIndexToWorktree(repository *common.Repository, pathspecs []string, output *patchWriter) error {
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return err
+	}
+	for i, entry := range index.Entries {
+		if !matchesPathspecs(entry.EntryPath, pathspecs) {
+			continue
+		}
+		if entry.Stage() != 0 {
+			// Every stage of a conflicted path is reported once
+			if i == 0 || index.Entries[i-1].EntryPath != entry.EntryPath {
+				output.add(plainPatchLine, "* Unmerged path %s", entry.EntryPath)
+			}
+			continue
+		}
+
+		// Intent to add entries have nothing staged so the whole file shows up as new
+		old := diffSide{}
+		if !entry.IntentToAdd() {
+			old = indexSide(entry)
+		}
+		new, err := worktreeSide(repository, index, entry)
+		if err != nil {
+			return err
+		}
+		if sameSide(old, new) {
+			continue
+		}
+		err = printChange(repository, &filePatch{path: entry.EntryPath, old: old, new: new}, output)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Staged changes - against HEAD unless another tree is given
+func diffTreeToIndex(repository *common.Repository, trees []common.Hash, pathspecs []string, output *patchWriter) error {
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return err
+	}
+	treeEntries, err := diffBaseEntries(repository, trees)
+	if err != nil {
+		return err
+	}
+
+	for _, entryPath := range sortedPaths(mergePaths(index, treeEntries)) {
+		if !matchesPathspecs(entryPath, pathspecs) {
+			continue
+		}
+		entry := index.FindEntry(entryPath)
+		if entry != nil && entry.Stage() != 0 {
+			output.add(plainPatchLine, "* Unmerged path %s", entryPath)
+			continue
+		}
+		new := diffSide{}
+		if entry != nil && !entry.IntentToAdd() {
+			new = indexSide(entry)
+		}
+		old := treeSide(treeEntries[entryPath])
+		if sameSide(old, new) {
+			continue
+		}
+		err = printChange(repository, &filePatch{path: entryPath, old: old, new: new}, output)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A tree against the files in the working tree - the index decides which paths are tracked
+func diffTreeToWorktree(repository *common.Repository, tree common.Hash, pathspecs []string, output *patchWriter) error {
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return err
+	}
+	treeEntries, err := diffBaseEntries(repository, []common.Hash{tree})
+	if err != nil {
+		return err
+	}
+
+	for _, entryPath := range sortedPaths(mergePaths(index, treeEntries)) {
+		if !matchesPathspecs(entryPath, pathspecs) {
+			continue
+		}
+		entry := index.FindEntry(entryPath)
+		if entry != nil && entry.Stage() != 0 {
+			output.add(plainPatchLine, "* Unmerged path %s", entryPath)
+			continue
+		}
+		new := diffSide{}
+		if entry != nil {
+			new, err = worktreeSide(repository, index, entry)
+			if err != nil {
+				return err
+			}
+		}
+		old := treeSide(treeEntries[entryPath])
+		if sameSide(old, new) {
+			continue
+		}
+		err = printChange(repository, &filePatch{path: entryPath, old: old, new: new}, output)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffTreeToTree(repository *common.Repository, oldTree, newTree common.Hash, pathspecs []string, output *patchWriter) error {
+	changes, err := objects.DiffTrees(repository, oldTree, newTree, true)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if !matchesPathspecs(change.Path, pathspecs) {
+			continue
+		}
+		err = printChange(repository, treeChangePatch(change), output)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The files of the given tree, or HEAD's when there isn't one - nothing at all on an unborn branch
+func diffBaseEntries(repository *common.Repository, trees []common.Hash) (map[string]*common.IndexEntry, error) {
+	if len(trees) == 1 {
+		return readTreeEntries(repository, trees[0].String(), "")
+	}
+	headHash, err := repository.ResolveHead()
+	if err != nil || headHash.Empty() {
+		return map[string]*common.IndexEntry{}, err
+	}
+	return readTreeEntries(repository, headHash.String(), "")
+}
+
+func sortedPaths(paths map[string]bool) []string {
+	sorted := make([]string, 0, len(paths))
+	for entryPath := range paths {
+		sorted = append(sorted, entryPath)
+	}
+	slices.Sort(sorted)
+	return sorted
+}
+
+func treeChangePatch(change *objects.TreeChange) *filePatch {
+	return &filePatch{
+		path: change.Path,
+		old:  diffSide{mode: change.OldMode, hash: change.OldHash},
+		new:  diffSide{mode: change.NewMode, hash: change.NewHash},
+	}
+}
+
+func indexSide(entry *common.IndexEntry) diffSide {
+	return diffSide{mode: entry.FileMode, hash: entry.Hash}
+}
+
+func treeSide(entry *common.IndexEntry) diffSide {
+	if entry == nil {
+		return diffSide{}
+	}
+	return diffSide{mode: entry.FileMode, hash: entry.Hash}
+}
+
+func sameSide(a, b diffSide) bool {
+	return a.mode == b.mode && a.hash == b.hash
+}
+
+// Unchanged files are taken from the index without reading them, anything else is hashed from disk
+func worktreeSide(repository *common.Repository, index *common.Index, entry *common.IndexEntry) (diffSide, error) {
+	change, mode, err := worktreeChange(repository, index, entry)
+	if err != nil {
+		return diffSide{}, err
+	}
+	switch change {
+	case ' ':
+		return indexSide(entry), nil
+	case 'D':
+		return diffSide{}, nil
+	}
+
+	filePath := filepath.Join(repository.WorkTree, entry.EntryPath)
+	var data []byte
+	// Symlinks are diffed by their target like git stores them
+	if mode == 0120000 {
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return diffSide{}, err
+		}
+		data = []byte(target)
+	} else {
+		data, err = os.ReadFile(filePath)
+		if err != nil {
+			return diffSide{}, err
+		}
+	}
+	blob := &objects.Blob{Data: data}
+	hash, err := common.HashObject(blob.Serialize())
+	if err != nil {
+		return diffSide{}, err
+	}
+	return diffSide{mode: mode, hash: hash, data: data, loaded: true}, nil
+}
+
+// Submodules have no blob so their content is the commit they point at like git shows it
+func (side *diffSide) load(repository *common.Repository) error {
+	if side.loaded || side.mode == 0 {
+		return nil
+	}
+	side.loaded = true
+	if side.mode == 0160000 {
+		side.data = []byte("Subproject commit " + side.hash.String() + "\n")
+		return nil
+	}
+	blob, err := objects.ReadBlob(repository, side.hash)
+	if err != nil {
+		return err
+	}
+	side.data = blob.Data
+	return nil
+}
+
+// A file turning into a symlink or the other way around is shown as a deletion and an addition
+func printChange(repository *common.Repository, patch *filePatch, output *patchWriter) error {
+	if patch.old.mode != 0 && patch.new.mode != 0 && patch.old.mode&0170000 != patch.new.mode&0170000 {
+		err := printFilePatch(repository, &filePatch{path: patch.path, old: patch.old}, output)
+		if err != nil {
+			return err
+		}
+		return printFilePatch(repository, &filePatch{path: patch.path, new: patch.new}, output)
+	}
+	return printFilePatch(repository, patch, output)
+}
+
+// Print a patch in git's extended format: the diff --git line, mode and index headers and then the hunks
+func printFilePatch(repository *common.Repository, patch *filePatch, output *patchWriter) error {
+	old, new := patch.old, patch.new
+	output.add(metaPatchLine, "diff --git a/%s b/%s", patch.path, patch.path)
+	switch {
+	case old.mode == 0:
+		output.add(metaPatchLine, "new file mode %06o", new.mode)
+	case new.mode == 0:
+		output.add(metaPatchLine, "deleted file mode %06o", old.mode)
+	case old.mode != new.mode:
+		output.add(metaPatchLine, "old mode %06o", old.mode)
+		output.add(metaPatchLine, "new mode %06o", new.mode)
+	}
+	// A mode change on its own has no content to show
+	if old.hash == new.hash {
+		return nil
+	}
+
+	err := old.load(repository)
+	if err != nil {
+		return err
+	}
+	err = new.load(repository)
+	if err != nil {
+		return err
+	}
+	binary := diff.IsBinary(old.data) || diff.IsBinary(new.data)
+
+	// Binary patches check they apply to the exact blob so they need the full hashes
+	oldHash, newHash := old.hash.String(), new.hash.String()
+	if !binary || !output.options.binary {
+		oldHash, err = objects.ShortHash(repository, old.hash, objects.DefaultShortHashLength)
+		if err != nil {
+			return err
+		}
+		newHash, err = objects.ShortHash(repository, new.hash, objects.DefaultShortHashLength)
+		if err != nil {
+			return err
+		}
+	}
+	if old.mode == new.mode {
+		output.add(metaPatchLine, "index %s..%s %06o", oldHash, newHash, old.mode)
+	} else {
+		output.add(metaPatchLine, "index %s..%s", oldHash, newHash)
+	}
+
+	oldName, newName := "a/"+patch.path, "b/"+patch.path
+	if old.mode == 0 {
+		oldName = "/dev/null"
+	}
+	if new.mode == 0 {
+		newName = "/dev/null"
+	}
+	switch {
+	case binary && output.options.binary:
+		// The new content and then the old so the patch can be applied in reverse too
+		output.add(plainPatchLine, "GIT binary patch")
+		for _, data := range [][]byte{new.data, old.data} {
+			for _, line := range diff.SplitLines([]byte(diff.EncodeBinaryLiteral(data))) {
+				output.add(plainPatchLine, "%s", strings.TrimSuffix(line, "\n"))
+			}
+		}
+		return nil
+	case binary:
+		output.add(plainPatchLine, "Binary files %s and %s differ", oldName, newName)
+		return nil
+	}
+
+	oldLines := diff.SplitLines(old.data)
+	newLines := diff.SplitLines(new.data)
+	hunks := diff.BuildHunks(oldLines, newLines, output.options.algorithm.Diff(oldLines, newLines), output.options.context)
+	if len(hunks) == 0 {
+		return nil
+	}
+	output.add(fileNamePatchLine, "--- %s", oldName)
+	output.add(fileNamePatchLine, "+++ %s", newName)
+	output.addHunks(old.data, new.data, hunks)
+	return nil
+}
+
+func printDiffUsage() {
+	fmt.Println("Usage: gitgood diff [<options>] [--] [<path>...]                          Show unstaged changes between the index and the working tree")
+	fmt.Println("Usage: gitgood diff --cached [<options>] [<commit>] [--] [<path>...]      Show staged changes against HEAD or the given commit")
+	fmt.Println("Usage: gitgood diff [<options>] <commit> [--] [<path>...]                 Show working tree changes against a commit")
+	fmt.Println("Usage: gitgood diff [<options>] <commit> <commit> [--] [<path>...]        Show changes between two commits or trees, a..b works too")
+	fmt.Println("Options: -U<n>, --diff-algorithm=<algorithm>, --binary, --color[=<when>], --no-color, --color-moved[=<mode>], --word-diff[=<mode>], --word-diff-regex=<regex>, --color-words[=<regex>]")
+}
@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/diff"
+)
+
+type patchLineKind int
+
+const (
+	// Printed as is: raw changes, commit ids, binary and unmerged notices
+	plainPatchLine patchLineKind = iota
+	// diff --git and the extended headers after it
+	metaPatchLine
+	// The --- and +++ lines
+	fileNamePatchLine
+	hunkHeaderPatchLine
+	contextPatchLine
+	deletedPatchLine
+	addedPatchLine
+	// "\ No newline at end of file"
+	incompletePatchLine
+	// An unchanged line between word diffs
+	wordContextPatchLine
+	// Changed lines as a word diff, already formatted
+	wordsPatchLine
+)
+
+type patchLine struct {
+	kind patchLineKind
+	// Lines from the files have no sign and always end in a newline
+	text string
+	// An added blank line past the end of the old file which is highlighted as a whitespace error
+	blankAtEOF bool
+}
+
+// Everything a diff command prints is collected here first since moved lines are found across
+// the whole output before any of it can be colored
+type patchWriter struct {
+	options *diffOptions
+	lines   []patchLine
+}
+
+func newPatchWriter(options *diffOptions) *patchWriter {
+	return &patchWriter{options: options}
+}
+
+func (writer *patchWriter) add(kind patchLineKind, format string, arguments ...any) {
+	writer.lines = append(writer.lines, patchLine{kind: kind, text: fmt.Sprintf(format, arguments...)})
+}
+
+// Git's default colors for the color.diff.<slot> config
+var defaultDiffColors = map[string]string{
+	"context":                   "normal",
+	"meta":                      "bold",
+	"frag":                      "cyan",
+	"func":                      "normal",
+	"old":                       "red",
+	"new":                       "green",
+	"commit":                    "yellow",
+	"whitespace":                "normal red",
+	"oldMoved":                  "bold magenta",
+	"oldMovedAlternative":       "bold blue",
+	"oldMovedDimmed":            "dim",
+	"oldMovedAlternativeDimmed": "dim italic",
+	"newMoved":                  "bold cyan",
+	"newMovedAlternative":       "bold yellow",
+	"newMovedDimmed":            "dim",
+	"newMovedAlternativeDimmed": "dim italic",
+}
+
+// Only read when color is on so every slot is empty otherwise
+func (options *diffOptions) readColors(config *common.Config) error {
+	options.colors = make(map[string]string)
+	for slot, defaultColor := range defaultDiffColors {
+		key := "color.diff." + slot
+		// plain is the old name for context
+		if _, found := config.Get(key); !found && slot == "context" {
+			key = "color.diff.plain"
+		}
+		color, err := configColor(config, key, defaultColor)
+		if err != nil {
+			return err
+		}
+		options.colors[slot] = color
+	}
+	return nil
+}
+
+func (options *diffOptions) colorOf(slot string) string {
+	return options.colors[slot]
+}
+
+func (options *diffOptions) reset() string {
+	if !options.color {
+		return ""
+	}
+	return common.ColorReset
+}
+
+// The hunks of one file, word by word with --word-diff
+func (writer *patchWriter) addHunks(oldData, newData []byte, hunks []*diff.Hunk) {
+	if writer.options.wordDiff != "" {
+		writer.addWordHunks(hunks)
+		return
+	}
+
+	blank := findBlankAtEOF(oldData, newData)
+	for _, hunk := range hunks {
+		writer.add(hunkHeaderPatchLine, "%s", hunk.Header())
+		// Numbered like git does it, starting from the header's numbers and counted before each line
+		oldNumber, newNumber := headerStart(hunk.OldStart, hunk.OldCount), headerStart(hunk.NewStart, hunk.NewCount)
+		for _, line := range hunk.Lines {
+			text, complete := completeLine(line.Text)
+			switch line.Operation {
+			case diff.Equal:
+				oldNumber++
+				newNumber++
+				writer.lines = append(writer.lines, patchLine{kind: contextPatchLine, text: text})
+			case diff.Delete:
+				oldNumber++
+				writer.lines = append(writer.lines, patchLine{kind: deletedPatchLine, text: text})
+			case diff.Insert:
+				newNumber++
+				blankAtEOF := blank.covers(oldNumber, newNumber) && strings.TrimLeft(text, " \t\n\v\f\r") == ""
+				writer.lines = append(writer.lines, patchLine{kind: addedPatchLine, text: text, blankAtEOF: blankAtEOF})
+			}
+			if !complete {
+				oldNumber++
+				writer.add(incompletePatchLine, "\\ No newline at end of file\n")
+			}
+		}
+	}
+}
+
+// Removed and added lines are collected until an unchanged line or the end of the hunk and then shown
+// as one word diff, which leaves out any "\ No newline at end of file"
+func (writer *patchWriter) addWordHunks(hunks []*diff.Hunk) {
+	var oldText, newText strings.Builder
+	flush := func() {
+		if oldText.Len() == 0 && newText.Len() == 0 {
+			return
+		}
+		segments := diff.WordDiff(oldText.String(), newText.String(), writer.options.wordPattern)
+		writer.add(wordsPatchLine, "%s", writer.formatWords(segments))
+		oldText.Reset()
+		newText.Reset()
+	}
+	for _, hunk := range hunks {
+		writer.add(hunkHeaderPatchLine, "%s", hunk.Header())
+		for _, line := range hunk.Lines {
+			text, _ := completeLine(line.Text)
+			switch line.Operation {
+			case diff.Delete:
+				oldText.WriteString(text)
+			case diff.Insert:
+				newText.WriteString(text)
+			default:
+				flush()
+				writer.add(wordContextPatchLine, "%s", text)
+			}
+		}
+		flush()
+	}
+}
+
+func completeLine(text string) (string, bool) {
+	if strings.HasSuffix(text, "\n") {
+		return text, true
+	}
+	return text + "\n", false
+}
+
+// The start line shown in a hunk header
+func headerStart(start, count int) int {
+	if count == 0 {
+		return start
+	}
+	return start + 1
+}
+
+// Where the blank lines at the end of each side start when the change adds some, zero otherwise
+type blankAtEOF struct {
+	preimage  int
+	postimage int
+}
+
+func findBlankAtEOF(oldData, newData []byte) blankAtEOF {
+	oldBlank, newBlank := countTrailingBlank(oldData), countTrailingBlank(newData)
+	if newBlank <= oldBlank {
+		return blankAtEOF{}
+	}
+	return blankAtEOF{
+		preimage:  len(diff.SplitLines(oldData)) - oldBlank + 1,
+		postimage: len(diff.SplitLines(newData)) - newBlank + 1,
+	}
+}
+
+func (blank blankAtEOF) covers(oldNumber, newNumber int) bool {
+	return blank.preimage != 0 && blank.postimage != 0 && blank.preimage <= oldNumber && blank.postimage <= newNumber
+}
+
+// Blank lines at the end of the data - like git the first line never counts
+// Ref https://github.com/git/git/blob/master/diff.c (count_trailing_blank)
+func countTrailingBlank(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	count := 0
+	end := len(data) - 1
+	if data[end] == '\n' {
+		end--
+	}
+	for 0 < end {
+		previous := end
+		for previous >= 0 && data[previous] != '\n' {
+			previous--
+		}
+		if previous < 0 || strings.TrimLeft(string(data[previous+1:end+1]), " \t\n\v\f\r") != "" {
+			break
+		}
+		count++
+		end = previous - 1
+	}
+	return count
+}
+
+// How each kind of word is marked in the word diff modes
+type wordAffixes struct {
+	prefix string
+	suffix string
+}
+
+type wordDiffStyle struct {
+	old     wordAffixes
+	new     wordAffixes
+	context wordAffixes
+	// What a newline inside the words turns into
+	newline string
+}
+
+var wordDiffStyles = map[string]wordDiffStyle{
+	"plain":     {old: wordAffixes{"[-", "-]"}, new: wordAffixes{"{+", "+}"}, newline: "\n"},
+	"color":     {newline: "\n"},
+	"porcelain": {old: wordAffixes{"-", "\n"}, new: wordAffixes{"+", "\n"}, context: wordAffixes{" ", "\n"}, newline: "~\n"},
+}
+
+func (writer *patchWriter) formatWords(segments []diff.WordSegment) string {
+	style := wordDiffStyles[writer.options.wordDiff]
+	var builder strings.Builder
+	for _, segment := range segments {
+		switch segment.Operation {
+		case diff.Delete:
+			writeWords(&builder, segment.Text, writer.options.colorOf("old"), style.old, style.newline)
+		case diff.Insert:
+			writeWords(&builder, segment.Text, writer.options.colorOf("new"), style.new, style.newline)
+		default:
+			writeWords(&builder, segment.Text, writer.options.colorOf("context"), style.context, style.newline)
+		}
+	}
+	return builder.String()
+}
+
+// Each piece of the text between newlines gets the color and affixes of its own
+// Ref https://github.com/git/git/blob/master/diff.c (fn_out_diff_words_write_helper)
+func writeWords(builder *strings.Builder, text, color string, affixes wordAffixes, newline string) {
+	for {
+		piece, rest, found := strings.Cut(text, "\n")
+		if piece != "" {
+			builder.WriteString(color + affixes.prefix + piece + affixes.suffix)
+			if color != "" {
+				builder.WriteString(common.ColorReset)
+			}
+		}
+		if !found {
+			return
+		}
+		builder.WriteString(newline)
+		text = rest
+		if text == "" {
+			return
+		}
+	}
+}
+
+// Color whatever was collected and print it
+func (writer *patchWriter) flush() {
+	fmt.Print(writer.render())
+}
+
+// Color whatever was collected and hand it back instead
+func (writer *patchWriter) render() string {
+	var marks []diff.MoveMark
+	if writer.options.color && writer.options.moved != diff.NoMoved {
+		lines := make([]diff.Line, len(writer.lines))
+		for i, line := range writer.lines {
+			lines[i] = diff.Line{Operation: diff.Equal, Text: line.text}
+			switch line.kind {
+			case deletedPatchLine:
+				lines[i].Operation = diff.Delete
+			case addedPatchLine:
+				lines[i].Operation = diff.Insert
+			}
+		}
+		marks = diff.MarkMoved(lines, writer.options.moved)
+	}
+
+	var builder strings.Builder
+	for i, line := range writer.lines {
+		var mark diff.MoveMark
+		if marks != nil {
+			mark = marks[i]
+		}
+		writer.renderLine(&builder, line, mark)
+	}
+	writer.lines = nil
+	return builder.String()
+}
+
+func (writer *patchWriter) renderLine(builder *strings.Builder, line patchLine, mark diff.MoveMark) {
+	options := writer.options
+	reset := options.reset()
+	switch line.kind {
+	case plainPatchLine:
+		builder.WriteString(line.text + "\n")
+	case metaPatchLine:
+		builder.WriteString(options.colorOf("meta") + line.text + reset + "\n")
+	case fileNamePatchLine:
+		builder.WriteString(options.colorOf("meta") + line.text + reset)
+		// Names with spaces get a tab after them so patch tools can tell where they end
+		if strings.Contains(line.text[len("--- "):], " ") {
+			builder.WriteString("\t")
+		}
+		builder.WriteString("\n")
+	case hunkHeaderPatchLine:
+		writer.renderHunkHeader(builder, line.text)
+	case contextPatchLine:
+		writeLine(builder, options.colorOf("context"), ' ', line.text, reset)
+	case deletedPatchLine:
+		writeLine(builder, options.colorOf(movedColorSlot("old", mark)), '-', line.text, reset)
+	case addedPatchLine:
+		color := options.colorOf(movedColorSlot("new", mark))
+		whitespace := options.colorOf("whitespace")
+		switch {
+		case whitespace == "":
+			writeLine(builder, color, '+', line.text, reset)
+		case line.blankAtEOF:
+			writeLine(builder, whitespace, '+', line.text, reset)
+		default:
+			writeLine(builder, color, '+', "", reset)
+			writeWhitespaceErrors(builder, line.text, color, reset, whitespace)
+		}
+	case incompletePatchLine:
+		writeLine(builder, options.colorOf("context"), 0, line.text, reset)
+	case wordContextPatchLine:
+		if options.wordDiff == "porcelain" {
+			writeLine(builder, options.colorOf("context"), ' ', line.text, reset)
+			builder.WriteString("~\n")
+		} else {
+			writeLine(builder, options.colorOf("context"), 0, line.text, reset)
+		}
+	case wordsPatchLine:
+		builder.WriteString(line.text)
+	}
+}
+
+func movedColorSlot(side string, mark diff.MoveMark) string {
+	switch {
+	case mark&diff.Moved == 0:
+		return side
+	case mark&diff.MovedAlternative != 0 && mark&diff.MovedUninteresting != 0:
+		return side + "MovedAlternativeDimmed"
+	case mark&diff.MovedAlternative != 0:
+		return side + "MovedAlternative"
+	case mark&diff.MovedUninteresting != 0:
+		return side + "MovedDimmed"
+	}
+	return side + "Moved"
+}
+
+// The line numbers in one color and the function name after them in another
+func (writer *patchWriter) renderHunkHeader(builder *strings.Builder, header string) {
+	options := writer.options
+	reset := options.reset()
+	end := strings.Index(header[2:], "@@") + 4
+	builder.WriteString(options.colorOf("frag") + header[:end] + reset)
+	function := strings.TrimLeft(header[end:], " \t")
+	if blank := header[end : len(header)-len(function)]; blank != "" {
+		builder.WriteString(options.colorOf("context") + blank + reset)
+	}
+	if function != "" {
+		builder.WriteString(options.colorOf("func") + function + reset)
+	}
+	builder.WriteString("\n")
+}
+
+// The sign and text in one color with a carriage return kept out of it so terminals don't lose the reset
+// Ref https://github.com/git/git/blob/master/diff.c (emit_line_0)
+func writeLine(builder *strings.Builder, color string, sign byte, text, reset string) {
+	text, hasNewline := strings.CutSuffix(text, "\n")
+	text, hasCarriageReturn := strings.CutSuffix(text, "\r")
+	if text != "" || sign != 0 {
+		builder.WriteString(color)
+		if sign != 0 {
+			builder.WriteByte(sign)
+		}
+		builder.WriteString(text + reset)
+	}
+	if hasCarriageReturn {
+		builder.WriteString("\r")
+	}
+	if hasNewline {
+		builder.WriteString("\n")
+	}
+}
+
+// An added line with spaces before tabs in its indent and whitespace at its end highlighted
+// Ref https://github.com/git/git/blob/master/ws.c (ws_check_emit_1)
+func writeWhitespaceErrors(builder *strings.Builder, text, color, reset, whitespace string) {
+	text, hasNewline := strings.CutSuffix(text, "\n")
+	trailing := len(strings.TrimRight(text, " \t\n\v\f\r"))
+
+	written := 0
+	for i := 0; i < trailing; i++ {
+		if text[i] == ' ' {
+			continue
+		}
+		if text[i] != '\t' {
+			break
+		}
+		if written < i {
+			builder.WriteString(whitespace + text[written:i] + reset + "\t")
+		} else {
+			builder.WriteString(text[written : i+1])
+		}
+		written = i + 1
+	}
+	if written < trailing {
+		builder.WriteString(color + text[written:trailing] + reset)
+	}
+	if trailing != len(text) {
+		builder.WriteString(whitespace + text[trailing:] + reset)
+	}
+	if hasNewline {
+		builder.WriteString("\n")
+	}
+}
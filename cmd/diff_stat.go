@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/diff"
+)
+
+// A file's line in a diffstat - binary files count the bytes on each side instead of lines
+type statEntry struct {
+	path    string
+	added   int
+	deleted int
+	binary  bool
+}
+
+func newStatEntry(repository *common.Repository, patch *filePatch, options *diffOptions) (statEntry, error) {
+	entry := statEntry{path: patch.path}
+	old, new := patch.old, patch.new
+	if old.hash == new.hash {
+		return entry, nil
+	}
+	err := old.load(repository)
+	if err != nil {
+		return entry, err
+	}
+	err = new.load(repository)
+	if err != nil {
+		return entry, err
+	}
+	if diff.IsBinary(old.data) || diff.IsBinary(new.data) {
+		entry.binary = true
+		entry.added, entry.deleted = len(new.data), len(old.data)
+		return entry, nil
+	}
+
+	oldLines, newLines := diff.SplitLines(old.data), diff.SplitLines(new.data)
+	for _, edit := range options.algorithm.Diff(oldLines, newLines) {
+		switch edit.Operation {
+		case diff.Insert:
+			entry.added++
+		case diff.Delete:
+			entry.deleted++
+		}
+	}
+	return entry, nil
+}
+
+// Names, change counts and a +/- graph scaled to fit in width columns, then the totals
+// Ref https://github.com/git/git/blob/master/diff.c (show_stats)
+func formatDiffStat(entries []statEntry, width int) string {
+	maxChange, maxName, numberWidth, binaryWidth := 0, 0, 0, 0
+	for _, entry := range entries {
+		maxName = max(maxName, utf8.RuneCountInString(entry.path))
+		if entry.binary {
+			// "Bin XXX -> YYY bytes" with the counts lined up with "Bin"
+			binaryWidth = max(binaryWidth, 14+decimalWidth(entry.added)+decimalWidth(entry.deleted))
+			numberWidth = 3
+			continue
+		}
+		maxChange = max(maxChange, entry.added+entry.deleted)
+	}
+	numberWidth = max(numberWidth, decimalWidth(maxChange))
+	width = max(width, 16+6+numberWidth)
+
+	graphWidth := maxChange
+	if maxChange+4 <= binaryWidth {
+		graphWidth = binaryWidth - 4
+	}
+	nameWidth := maxName
+	// When everything doesn't fit the graph gets up to 3/8 of the width and the name the rest
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = max(width*3/8-numberWidth-6, 6)
+		}
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	var builder strings.Builder
+	insertions, deletions := 0, 0
+	for _, entry := range entries {
+		// Names too long for their column lose their start, preferably up to a slash
+		name, prefix := entry.path, ""
+		if utf8.RuneCountInString(name) > nameWidth {
+			prefix = "..."
+			length := max(nameWidth-3, 0)
+			for utf8.RuneCountInString(name) > length {
+				_, size := utf8.DecodeRuneInString(name)
+				name = name[size:]
+			}
+			if slash := strings.Index(name, "/"); slash != -1 {
+				name = name[slash:]
+			}
+		}
+		padding := strings.Repeat(" ", max(nameWidth-len(prefix)-utf8.RuneCountInString(name), 0))
+
+		if entry.binary {
+			fmt.Fprintf(&builder, " %s%s%s | %*s", prefix, name, padding, numberWidth, "Bin")
+			if entry.added != 0 || entry.deleted != 0 {
+				fmt.Fprintf(&builder, " %d -> %d bytes", entry.deleted, entry.added)
+			}
+			builder.WriteByte('\n')
+			continue
+		}
+
+		insertions += entry.added
+		deletions += entry.deleted
+		added, deleted := entry.added, entry.deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(added+deleted, graphWidth, maxChange)
+			if total < 2 && added != 0 && deleted != 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleLinear(added, graphWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleLinear(deleted, graphWidth, maxChange)
+				added = total - deleted
+			}
+		}
+		fmt.Fprintf(&builder, " %s%s%s | %*d", prefix, name, padding, numberWidth, entry.added+entry.deleted)
+		if entry.added+entry.deleted != 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(strings.Repeat("+", added) + strings.Repeat("-", deleted) + "\n")
+	}
+	builder.WriteString(statSummary(len(entries), insertions, deletions))
+	return builder.String()
+}
+
+// Every changed file gets at least one + or - so the scale is a column short and adds one back
+func scaleLinear(count, width, maxChange int) int {
+	if count == 0 {
+		return 0
+	}
+	return 1 + count*(width-1)/maxChange
+}
+
+func decimalWidth(number int) int {
+	return len(strconv.Itoa(number))
+}
+
+// Insertions and deletions are only left out when the other one isn't zero
+func statSummary(files, insertions, deletions int) string {
+	summary := fmt.Sprintf(" %d %s changed", files, plural(files, "file", "files"))
+	if insertions != 0 || deletions == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions != 0 || insertions == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	return summary + "\n"
+}
+
+func plural(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+// Created and deleted files and mode changes, which the stat doesn't show
+func summaryLine(patch *filePatch) string {
+	old, new := patch.old, patch.new
+	switch {
+	case old.mode == 0:
+		return fmt.Sprintf(" create mode %06o %s\n", new.mode, patch.path)
+	case new.mode == 0:
+		return fmt.Sprintf(" delete mode %06o %s\n", old.mode, patch.path)
+	case old.mode != new.mode:
+		return fmt.Sprintf(" mode change %06o => %06o %s\n", old.mode, new.mode, patch.path)
+	}
+	return ""
+}
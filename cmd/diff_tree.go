@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+type diffTreeOptions struct {
+	diffOptions
+	recursive bool
+	patch     bool
+	// Compare a root commit against the empty tree instead of skipping it
+	root bool
+	// Leave out the commit hash line printed before the changes of a single commit
+	noCommitID bool
+}
+
+func DiffTree(flags []string) {
+	options := &diffTreeOptions{diffOptions: *newDiffOptions()}
+	// Plumbing output is only colored when asked for on the command line
+	options.colorWhen = "never"
+	var arguments, pathspecs []string
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		switch {
+		case flag == "--":
+			pathspecs = append(pathspecs, flags[i+1:]...)
+			i = len(flags)
+		case flag == "-r":
+			options.recursive = true
+		case flag == "-p" || flag == "--patch":
+			options.patch = true
+			options.recursive = true
+		case flag == "--root":
+			options.root = true
+		case flag == "--no-commit-id":
+			options.noCommitID = true
+		case strings.HasPrefix(flag, "-"):
+			handled, err := options.parseFlag(flag)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			// Like git asking for a patch option implies -p
+			if handled && (strings.HasPrefix(flag, "-U") || strings.HasPrefix(flag, "--unified=")) {
+				options.patch = true
+				options.recursive = true
+			}
+			if handled {
+				continue
+			}
+			fmt.Println("Unsupported flag...")
+			printDiffTreeUsage()
+			return
+		default:
+			arguments = append(arguments, flag)
+		}
+	}
+
+	// Like git the first two arguments are always the trees, everything after them is a path
+	if len(arguments) == 0 {
+		printDiffTreeUsage()
+		return
+	}
+	treeish := arguments[:min(len(arguments), 2)]
+	pathspecs = append(arguments[len(treeish):], pathspecs...)
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	err = options.readConfig(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	// Paths given to diff-tree are always from the top of the work tree
+	for i, pathspec := range pathspecs {
+		pathspecs[i] = strings.TrimSuffix(pathspec, "/")
+	}
+
+	output := newPatchWriter(&options.diffOptions)
+	if len(treeish) == 1 {
+		err = diffTreeCommit(repository, treeish[0], pathspecs, options, output)
+	} else {
+		err = diffTreePair(repository, treeish[0], treeish[1], pathspecs, options, output)
+	}
+	output.flush()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// A single commit is compared against its parent with its hash printed first
+// Merges and root commits (without --root) show nothing
+func diffTreeCommit(repository *common.Repository, revision string, pathspecs []string, options *diffTreeOptions, output *patchWriter) error {
+	hash, err := objects.ResolveRevision(repository, revision)
+	if err != nil {
+		return fmt.Errorf("fatal: %v", err)
+	}
+	commitHash, err := objects.PeelRevision(repository, hash, "commit")
+	if err != nil {
+		return err
+	}
+	commit, err := objects.ReadCommit(repository, commitHash)
+	if err != nil {
+		return err
+	}
+
+	var parentTree common.Hash
+	switch {
+	case len(commit.Parents) > 1:
+		return nil
+	case len(commit.Parents) == 1:
+		parentTree, err = objects.PeelRevision(repository, commit.Parents[0], "tree")
+		if err != nil {
+			return err
+		}
+	case !options.root:
+		return nil
+	}
+
+	changes, err := diffTreeChanges(repository, parentTree, commit.Tree.Hash, pathspecs, options)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	if !options.noCommitID {
+		output.add(plainPatchLine, "%s", commitHash)
+	}
+	return printTreeChanges(repository, changes, options, output)
+}
+
+func diffTreePair(repository *common.Repository, oldRevision, newRevision string, pathspecs []string, options *diffTreeOptions, output *patchWriter) error {
+	var trees []common.Hash
+	for _, revision := range []string{oldRevision, newRevision} {
+		hash, err := objects.ResolveRevision(repository, revision)
+		if err != nil {
+			return fmt.Errorf("fatal: %v", err)
+		}
+		tree, err := objects.PeelRevision(repository, hash, "tree")
+		if err != nil {
+			return err
+		}
+		trees = append(trees, tree)
+	}
+
+	changes, err := diffTreeChanges(repository, trees[0], trees[1], pathspecs, options)
+	if err != nil {
+		return err
+	}
+	return printTreeChanges(repository, changes, options, output)
+}
+
+func diffTreeChanges(repository *common.Repository, oldTree, newTree common.Hash, pathspecs []string, options *diffTreeOptions) ([]*objects.TreeChange, error) {
+	changes, err := objects.DiffTrees(repository, oldTree, newTree, options.recursive)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*objects.TreeChange
+	for _, change := range changes {
+		if matchesTreePathspecs(change, pathspecs) {
+			matched = append(matched, change)
+		}
+	}
+	return matched, nil
+}
+
+// A changed subtree also matches pathspecs for files inside it since that's where those changes are
+func matchesTreePathspecs(change *objects.TreeChange, pathspecs []string) bool {
+	if matchesPathspecs(change.Path, pathspecs) {
+		return true
+	}
+	if change.OldMode != 040000 && change.NewMode != 040000 {
+		return false
+	}
+	for _, pathspec := range pathspecs {
+		if strings.HasPrefix(pathspec, change.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Raw output is ":oldmode newmode oldhash newhash status<tab>path" with full hashes
+func printTreeChanges(repository *common.Repository, changes []*objects.TreeChange, options *diffTreeOptions, output *patchWriter) error {
+	for _, change := range changes {
+		if options.patch {
+			err := printChange(repository, treeChangePatch(change), output)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		output.add(plainPatchLine, ":%06o %06o %s %s %c\t%s", change.OldMode, change.NewMode, change.OldHash, change.NewHash, change.Status(), change.Path)
+	}
+	return nil
+}
+
+func printDiffTreeUsage() {
+	fmt.Println("Usage: gitgood diff-tree [-r] [-p] [<diff-options>] <tree-ish> <tree-ish> [<path>...]          Compare the content and mode of blobs found via two tree objects")
+	fmt.Println("Usage: gitgood diff-tree [-r] [-p] [<diff-options>] [--root] [--no-commit-id] <commit> [<path>...]     Compare a commit against its parent")
+}
@@ -0,0 +1,500 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+const defaultRefFormat = "%(objectname) %(objecttype)\t%(refname)"
+
+// Same layout git uses for dates when no format is given
+const defaultDateLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+func ForEachRef(flags []string) {
+	format := defaultRefFormat
+	var sortKeys []string
+	var patterns []string
+	contains := ""
+	count := 0
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		switch {
+		case strings.HasPrefix(flag, "--format="):
+			format = strings.TrimPrefix(flag, "--format=")
+		case strings.HasPrefix(flag, "--sort="):
+			sortKeys = append(sortKeys, strings.TrimPrefix(flag, "--sort="))
+		case strings.HasPrefix(flag, "--count="):
+			var err error
+			count, err = strconv.Atoi(strings.TrimPrefix(flag, "--count="))
+			if err != nil || count < 0 {
+				fmt.Printf("fatal: invalid --count argument: %s\n", flag)
+				return
+			}
+		case strings.HasPrefix(flag, "--contains="):
+			contains = strings.TrimPrefix(flag, "--contains=")
+		case flag == "--contains":
+			// Without a commit --contains means HEAD like git
+			contains = "HEAD"
+			if i+1 < len(flags) && !strings.HasPrefix(flags[i+1], "-") {
+				contains = flags[i+1]
+				i++
+			}
+		case strings.HasPrefix(flag, "-"):
+			fmt.Println("Unsupported flag...")
+			printForEachRefUsage()
+			return
+		default:
+			patterns = append(patterns, flag)
+		}
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	refs, err := listMatchingRefs(repository, patterns, contains)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	details := make([]*refDetails, 0, len(refs))
+	for _, ref := range refs {
+		detail, err := loadRefDetails(repository, ref)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		details = append(details, detail)
+	}
+	err = sortRefDetails(details, sortKeys)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if count > 0 && count < len(details) {
+		details = details[:count]
+	}
+
+	for _, detail := range details {
+		line, err := detail.format(format)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Println(line)
+	}
+}
+
+// Patterns match whole leading path components like refs/heads or use shell globs like refs/tags/v1.*
+func matchesRefPattern(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+			continue
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+		if name == pattern || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func listMatchingRefs(repository *common.Repository, patterns []string, contains string) ([]*common.Ref, error) {
+	refs, err := repository.ListRefs("refs/")
+	if err != nil {
+		return nil, err
+	}
+	containsHash := common.Hash{}
+	if contains != "" {
+		containsHash, err = objects.ResolveCommitish(repository, contains)
+		if err != nil {
+			return nil, fmt.Errorf("error: malformed object name %s: %v", contains, err)
+		}
+	}
+
+	var matching []*common.Ref
+	for _, ref := range refs {
+		if !matchesRefPattern(ref.Name, patterns) {
+			continue
+		}
+		if !containsHash.Empty() {
+			// Only refs pointing at commits (directly or through tags) can contain a commit
+			commitHash, err := objects.PeelRevision(repository, ref.Hash, "commit")
+			if err != nil {
+				continue
+			}
+			contained, err := objects.IsAncestor(repository, containsHash, commitHash)
+			if err != nil {
+				return nil, err
+			}
+			if !contained {
+				continue
+			}
+		}
+		matching = append(matching, ref)
+	}
+	return matching, nil
+}
+
+// Everything the format atoms can show about a ref, read once up front
+type refDetails struct {
+	repository *common.Repository
+	ref        *common.Ref
+	objectType string
+	objectSize int
+	commit     *objects.Commit
+	tag        *objects.Tag
+}
+
+func loadRefDetails(repository *common.Repository, ref *common.Ref) (*refDetails, error) {
+	objectType, content, err := objects.ReadObject(repository, ref.Hash)
+	if err != nil {
+		return nil, err
+	}
+	detail := &refDetails{
+		repository: repository,
+		ref:        ref,
+		objectType: objectType,
+		objectSize: len(content),
+	}
+	switch objectType {
+	case "commit":
+		detail.commit, err = objects.ReadCommit(repository, ref.Hash)
+	case "tag":
+		detail.tag, err = objects.ReadTag(repository, ref.Hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// Expand %(atom) placeholders, %% and %xx hex escapes the way git's --format does
+func (detail *refDetails) format(format string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			builder.WriteByte(format[i])
+			continue
+		}
+		switch {
+		case format[i+1] == '%':
+			builder.WriteByte('%')
+			i++
+		case format[i+1] == '(':
+			closingIndex := strings.IndexByte(format[i:], ')')
+			if closingIndex == -1 {
+				return "", fmt.Errorf("fatal: malformed format string %s", format[i:])
+			}
+			value, err := detail.atom(format[i+2 : i+closingIndex])
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(value)
+			i += closingIndex
+		case i+2 < len(format):
+			character, err := strconv.ParseUint(format[i+1:i+3], 16, 8)
+			if err != nil {
+				builder.WriteByte('%')
+				continue
+			}
+			builder.WriteByte(byte(character))
+			i += 2
+		default:
+			builder.WriteByte('%')
+		}
+	}
+	return builder.String(), nil
+}
+
+func (detail *refDetails) atom(atom string) (string, error) {
+	// A leading * shows the atom for the object an annotated tag points at
+	if strings.HasPrefix(atom, "*") {
+		if detail.tag == nil {
+			return "", nil
+		}
+		peeledHash, err := objects.PeelTag(detail.repository, detail.ref.Hash)
+		if err != nil {
+			return "", err
+		}
+		peeled, err := loadRefDetails(detail.repository, &common.Ref{Name: detail.ref.Name, Hash: peeledHash})
+		if err != nil {
+			return "", err
+		}
+		return peeled.atom(atom[1:])
+	}
+
+	name, modifier, _ := strings.Cut(atom, ":")
+	switch name {
+	case "refname":
+		return formatRefName(detail.ref.Name, modifier)
+	case "objectname":
+		return detail.formatHash(detail.ref.Hash, modifier)
+	case "objecttype":
+		return detail.objectType, nil
+	case "objectsize":
+		return strconv.Itoa(detail.objectSize), nil
+	case "tree":
+		if detail.commit == nil {
+			return "", nil
+		}
+		return detail.formatHash(detail.commit.Tree.Hash, modifier)
+	case "parent":
+		if detail.commit == nil {
+			return "", nil
+		}
+		var parents []string
+		for _, parent := range detail.commit.Parents {
+			formatted, err := detail.formatHash(parent, modifier)
+			if err != nil {
+				return "", err
+			}
+			parents = append(parents, formatted)
+		}
+		return strings.Join(parents, " "), nil
+	case "subject", "body", "contents":
+		return detail.message(name), nil
+	case "authorname", "authoremail", "authordate", "committername", "committeremail", "committerdate":
+		if detail.commit == nil {
+			return "", nil
+		}
+		if field, found := strings.CutPrefix(name, "committer"); found {
+			return formatIdentityField(detail.commit.Committer, detail.commit.CommitterTime, field, modifier)
+		}
+		return formatIdentityField(detail.commit.Author, detail.commit.Timestamp, strings.TrimPrefix(name, "author"), modifier)
+	case "taggername", "taggeremail", "taggerdate":
+		if detail.tag == nil {
+			return "", nil
+		}
+		identity, timestamp := splitIdentityTime(detail.tag.Tagger)
+		return formatIdentityField(identity, timestamp, strings.TrimPrefix(name, "tagger"), modifier)
+	case "creator", "creatordate":
+		field := strings.TrimPrefix(name, "creator")
+		if field == "" {
+			field = "identity"
+		}
+		switch {
+		case detail.commit != nil:
+			return formatIdentityField(detail.commit.Committer, detail.commit.CommitterTime, field, modifier)
+		case detail.tag != nil:
+			identity, timestamp := splitIdentityTime(detail.tag.Tagger)
+			return formatIdentityField(identity, timestamp, field, modifier)
+		}
+		return "", nil
+	case "upstream":
+		if !strings.HasPrefix(detail.ref.Name, "refs/heads/") {
+			return "", nil
+		}
+		upstream, err := detail.repository.BranchUpstream(strings.TrimPrefix(detail.ref.Name, "refs/heads/"))
+		if err != nil || upstream == "" {
+			return "", err
+		}
+		return formatRefName(upstream, modifier)
+	case "HEAD":
+		branch, err := detail.repository.GetBranch()
+		if err != nil {
+			return "", err
+		}
+		if branch != "" && detail.ref.Name == "refs/heads/"+branch {
+			return "*", nil
+		}
+		return " ", nil
+	}
+	return "", fmt.Errorf("fatal: unknown field name: %s", atom)
+}
+
+func formatRefName(name, modifier string) (string, error) {
+	switch {
+	case modifier == "":
+		return name, nil
+	case modifier == "short":
+		return common.ShortRefName(name), nil
+	case strings.HasPrefix(modifier, "lstrip=") || strings.HasPrefix(modifier, "strip="):
+		_, countString, _ := strings.Cut(modifier, "=")
+		count, err := strconv.Atoi(countString)
+		if err != nil {
+			return "", fmt.Errorf("fatal: invalid refname modifier: %s", modifier)
+		}
+		components := strings.Split(name, "/")
+		// Negative counts keep that many components from the end instead
+		if count < 0 {
+			count = max(len(components)+count, 0)
+		}
+		return strings.Join(components[min(count, len(components)):], "/"), nil
+	case strings.HasPrefix(modifier, "rstrip="):
+		count, err := strconv.Atoi(strings.TrimPrefix(modifier, "rstrip="))
+		if err != nil {
+			return "", fmt.Errorf("fatal: invalid refname modifier: %s", modifier)
+		}
+		components := strings.Split(name, "/")
+		if count < 0 {
+			count = max(len(components)+count, 0)
+		}
+		return strings.Join(components[:max(len(components)-count, 0)], "/"), nil
+	}
+	return "", fmt.Errorf("fatal: unrecognized %%(refname) argument: %s", modifier)
+}
+
+func (detail *refDetails) formatHash(hash common.Hash, modifier string) (string, error) {
+	switch {
+	case modifier == "":
+		return hash.String(), nil
+	case modifier == "short":
+		return objects.ShortHash(detail.repository, hash, objects.DefaultShortHashLength)
+	case strings.HasPrefix(modifier, "short="):
+		length, err := strconv.Atoi(strings.TrimPrefix(modifier, "short="))
+		if err != nil {
+			return "", fmt.Errorf("fatal: positive value expected objectname:short=%s", strings.TrimPrefix(modifier, "short="))
+		}
+		return objects.ShortHash(detail.repository, hash, length)
+	}
+	return "", fmt.Errorf("fatal: unrecognized %%(objectname) argument: %s", modifier)
+}
+
+func (detail *refDetails) message(part string) string {
+	message := ""
+	switch {
+	case detail.commit != nil:
+		message = detail.commit.Message
+	case detail.tag != nil:
+		message = detail.tag.Message
+	}
+	// The subject is the whole first paragraph folded onto one line
+	subject, body, _ := strings.Cut(message, "\n\n")
+	switch part {
+	case "subject":
+		return strings.Join(strings.Fields(subject), " ")
+	case "body":
+		return strings.TrimLeft(body, "\n")
+	}
+	return message
+}
+
+// Tagger lines carry their own "<unix time> <zone>" after the email
+func splitIdentityTime(line string) (string, time.Time) {
+	emailEnd := strings.LastIndex(line, ">")
+	if emailEnd == -1 {
+		return line, time.Time{}
+	}
+	identity := line[:emailEnd+1]
+	timeFields := strings.Fields(line[emailEnd+1:])
+	if len(timeFields) == 0 {
+		return identity, time.Time{}
+	}
+	seconds, err := strconv.ParseInt(timeFields[0], 10, 64)
+	if err != nil {
+		return identity, time.Time{}
+	}
+	timestamp := time.Unix(seconds, 0)
+	if len(timeFields) > 1 {
+		if zone, err := time.Parse("-0700", timeFields[1]); err == nil {
+			timestamp = timestamp.In(zone.Location())
+		}
+	}
+	return identity, timestamp
+}
+
+// Pick the name, email or date out of a "Name <email>" identity
+func formatIdentityField(identity string, timestamp time.Time, field, modifier string) (string, error) {
+	name, email, _ := strings.Cut(identity, " <")
+	switch field {
+	case "identity":
+		return identity, nil
+	case "name":
+		return name, nil
+	case "email":
+		return "<" + strings.TrimSuffix(email, ">") + ">", nil
+	case "date":
+		return formatDate(timestamp, modifier)
+	}
+	return "", fmt.Errorf("fatal: unknown field name: %s", field)
+}
+
+func formatDate(timestamp time.Time, modifier string) (string, error) {
+	if timestamp.IsZero() {
+		return "", nil
+	}
+	switch modifier {
+	case "", "default":
+		return timestamp.Format(defaultDateLayout), nil
+	case "iso", "iso8601":
+		return timestamp.Format("2006-01-02 15:04:05 -0700"), nil
+	case "iso-strict", "iso8601-strict":
+		return timestamp.Format(time.RFC3339), nil
+	case "rfc", "rfc2822":
+		return timestamp.Format("Mon, 2 Jan 2006 15:04:05 -0700"), nil
+	case "short":
+		return timestamp.Format("2006-01-02"), nil
+	case "unix":
+		return strconv.FormatInt(timestamp.Unix(), 10), nil
+	case "raw":
+		return fmt.Sprintf("%d %s", timestamp.Unix(), timestamp.Format("-0700")), nil
+	}
+	return "", fmt.Errorf("fatal: unknown date format %s", modifier)
+}
+
+// Git treats the last --sort as the primary key so the keys are applied first to last with a stable sort
+// A leading - reverses the order and refname is the default
+func sortRefDetails(details []*refDetails, sortKeys []string) error {
+	if len(sortKeys) == 0 {
+		sortKeys = []string{"refname"}
+	}
+	for _, sortKey := range sortKeys {
+		descending := strings.HasPrefix(sortKey, "-")
+		atom := strings.TrimPrefix(sortKey, "-")
+
+		values := make(map[*refDetails]string, len(details))
+		for _, detail := range details {
+			value, err := detail.sortValue(atom)
+			if err != nil {
+				return err
+			}
+			values[detail] = value
+		}
+		slices.SortStableFunc(details, func(a, b *refDetails) int {
+			comparison := strings.Compare(values[a], values[b])
+			if descending {
+				return -comparison
+			}
+			return comparison
+		})
+	}
+	return nil
+}
+
+// Dates and sizes sort numerically so they're compared as zero padded numbers
+func (detail *refDetails) sortValue(atom string) (string, error) {
+	baseAtom, _, _ := strings.Cut(atom, ":")
+	if strings.HasSuffix(baseAtom, "date") {
+		value, err := detail.atom(baseAtom + ":unix")
+		if err != nil || value == "" {
+			return "", err
+		}
+		seconds, _ := strconv.ParseInt(value, 10, 64)
+		return fmt.Sprintf("%020d", seconds), nil
+	}
+	if baseAtom == "objectsize" {
+		return fmt.Sprintf("%020d", detail.objectSize), nil
+	}
+	return detail.atom(atom)
+}
+
+func printForEachRefUsage() {
+	fmt.Println("Usage: gitgood for-each-ref [--format=<format>] [--sort=<key>]... [--count=<n>] [--contains [<commit>]] [<pattern>...]")
+	fmt.Println("       Atoms include %(refname[:short]), %(objectname[:short]), %(objecttype), %(subject), %(authordate[:iso]) and %(upstream[:short])")
+}
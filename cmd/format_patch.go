@@ -0,0 +1,384 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+type formatPatchOptions struct {
+	diffOptions
+	stdout          bool
+	outputDirectory string
+	// Only the last n commits when positive
+	maxCount int
+	// Everything reachable from the revision instead of what's on HEAD but not on it
+	root bool
+	// Shown under "-- " at the end of every patch, nothing at all when empty
+	signature string
+}
+
+// Ref https://github.com/git/git/blob/master/builtin/log.c (cmd_format_patch)
+func FormatPatch(flags []string) {
+	options := &formatPatchOptions{diffOptions: *newDiffOptions(), signature: "go-git-good"}
+	// Patches are meant to be applied so binary changes always come with their data
+	options.colorWhen = "never"
+	options.binary = true
+	var arguments []string
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		switch {
+		case flag == "--stdout":
+			options.stdout = true
+		case flag == "-o" || flag == "--output-directory":
+			if i+1 >= len(flags) {
+				printFormatPatchUsage()
+				return
+			}
+			i++
+			options.outputDirectory = flags[i]
+		case strings.HasPrefix(flag, "--output-directory="):
+			options.outputDirectory = strings.TrimPrefix(flag, "--output-directory=")
+		case flag == "--root":
+			options.root = true
+		case strings.HasPrefix(flag, "--signature="):
+			options.signature = strings.TrimPrefix(flag, "--signature=")
+		case flag == "--no-signature":
+			options.signature = ""
+		case isCountFlag(flag):
+			options.maxCount, _ = strconv.Atoi(flag[1:])
+		case strings.HasPrefix(flag, "-"):
+			handled, err := options.parseFlag(flag)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			if handled {
+				continue
+			}
+			fmt.Println("Unsupported flag...")
+			printFormatPatchUsage()
+			return
+		default:
+			arguments = append(arguments, flag)
+		}
+	}
+	if len(arguments) > 1 || (len(arguments) == 0 && options.maxCount == 0) {
+		printFormatPatchUsage()
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	err = options.readConfig(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	commits, err := formatPatchCommits(repository, arguments, options)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	if options.outputDirectory != "" && !options.stdout {
+		err = os.MkdirAll(options.outputDirectory, 0755)
+		if err != nil {
+			fmt.Printf("fatal: could not create directory '%s'\n", options.outputDirectory)
+			return
+		}
+	}
+	written := 0
+	for i, commitHash := range commits {
+		commit, err := objects.ReadCommit(repository, commitHash)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		message, err := formatPatchMessage(repository, commitHash, commit, i+1, len(commits), options)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if message == "" {
+			continue
+		}
+		if options.stdout {
+			// Messages in an mbox are kept apart by a blank line
+			if written > 0 {
+				fmt.Println()
+			}
+			fmt.Print(message)
+			written++
+			continue
+		}
+
+		fileName := patchFileName(i+1, commit.Subject())
+		if options.outputDirectory != "" {
+			fileName = filepath.Join(options.outputDirectory, fileName)
+		}
+		err = os.WriteFile(fileName, []byte(message), 0644)
+		if err != nil {
+			fmt.Printf("fatal: could not open '%s' for writing: %v\n", fileName, err)
+			return
+		}
+		fmt.Println(fileName)
+	}
+}
+
+// "-3" for the last three commits
+func isCountFlag(flag string) bool {
+	if len(flag) < 2 {
+		return false
+	}
+	for _, c := range flag[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// The commits to turn into patches, oldest first and without merges
+// A single revision means everything since it up to HEAD unless --root or a count makes it the tip
+func formatPatchCommits(repository *common.Repository, arguments []string, options *formatPatchOptions) ([]common.Hash, error) {
+	since, tip := "", "HEAD"
+	if len(arguments) == 1 {
+		argument := arguments[0]
+		if from, to, isRange := strings.Cut(argument, ".."); isRange && !strings.HasPrefix(to, ".") {
+			since, tip = cmp.Or(from, "HEAD"), cmp.Or(to, "HEAD")
+		} else if options.root || options.maxCount > 0 {
+			tip = argument
+		} else {
+			since = argument
+		}
+	}
+
+	tipHash, err := resolveCommit(repository, tip)
+	if err != nil {
+		return nil, err
+	}
+	excluded := map[common.Hash]bool{}
+	if since != "" {
+		sinceHash, err := resolveCommit(repository, since)
+		if err != nil {
+			return nil, err
+		}
+		excluded, err = objects.ReachableCommits(repository, []common.Hash{sinceHash})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parents come before their children so the patches apply in order
+	var commits []common.Hash
+	visited := map[common.Hash]bool{}
+	var visit func(hash common.Hash) error
+	visit = func(hash common.Hash) error {
+		if hash.Empty() || visited[hash] || excluded[hash] {
+			return nil
+		}
+		visited[hash] = true
+		commit, err := objects.ReadCommit(repository, hash)
+		if err != nil {
+			return err
+		}
+		for _, parent := range commit.Parents {
+			err = visit(parent)
+			if err != nil {
+				return err
+			}
+		}
+		if len(commit.Parents) <= 1 {
+			commits = append(commits, hash)
+		}
+		return nil
+	}
+	err = visit(tipHash)
+	if err != nil {
+		return nil, err
+	}
+	if options.maxCount > 0 && len(commits) > options.maxCount {
+		commits = commits[len(commits)-options.maxCount:]
+	}
+	return commits, nil
+}
+
+// One commit as an email: the headers, the message, a diffstat and then the patch
+// Ref https://github.com/git/git/blob/master/log-tree.c (log_write_email_headers)
+func formatPatchMessage(repository *common.Repository, commitHash common.Hash, commit *objects.Commit, number, total int, options *formatPatchOptions) (string, error) {
+	var parentTree common.Hash
+	if len(commit.Parents) == 1 {
+		var err error
+		parentTree, err = objects.PeelRevision(repository, commit.Parents[0], "tree")
+		if err != nil {
+			return "", err
+		}
+	}
+	changes, err := objects.DiffTrees(repository, parentTree, commit.Tree.Hash, true)
+	// Like git commits without changes still count towards the total but have nothing to send
+	if err != nil || len(changes) == 0 {
+		return "", err
+	}
+
+	var builder strings.Builder
+	// The date is always the same so mbox readers can tell the separator from text
+	fmt.Fprintf(&builder, "From %s Mon Sep 17 00:00:00 2001\n", commitHash)
+	builder.WriteString(mailFromHeader(commit.Author))
+	fmt.Fprintf(&builder, "Date: %s\n", commit.Timestamp.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+
+	title, body := splitCommitMessage(commit.Message)
+	subject := "Subject: [PATCH] "
+	if total > 1 {
+		subject = fmt.Sprintf("Subject: [PATCH %d/%d] ", number, total)
+	}
+	builder.WriteString(subject)
+	if needsRFC2047(title) {
+		appendRFC2047(&builder, title, len(subject), false)
+	} else {
+		appendWrapped(&builder, title, -len(subject), 1, mailLineWidth)
+	}
+	builder.WriteByte('\n')
+	// Only the message counts here, a name outside ASCII is encoded in its header already
+	if hasNonASCII(title) || hasNonASCII(body) {
+		builder.WriteString("MIME-Version: 1.0\n")
+		builder.WriteString("Content-Type: text/plain; charset=UTF-8\n")
+		builder.WriteString("Content-Transfer-Encoding: 8bit\n")
+	}
+	builder.WriteString("\n")
+	builder.WriteString(body)
+	builder.WriteString("---\n")
+
+	var entries []statEntry
+	var summary strings.Builder
+	output := newPatchWriter(&options.diffOptions)
+	for _, change := range changes {
+		patch := treeChangePatch(change)
+		entry, err := newStatEntry(repository, patch, &options.diffOptions)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, entry)
+		summary.WriteString(summaryLine(patch))
+		err = printChange(repository, patch, output)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(entries) > 0 {
+		builder.WriteString(formatDiffStat(entries, 72))
+		builder.WriteString(summary.String())
+	}
+	builder.WriteString("\n")
+	builder.WriteString(output.render())
+	if options.signature != "" {
+		fmt.Fprintf(&builder, "-- \n%s\n\n", options.signature)
+	}
+	return builder.String(), nil
+}
+
+// "From: Name <email>" with the name quoted or encoded when it has to be and the address moved to
+// its own line when it doesn't fit
+// Ref https://github.com/git/git/blob/master/pretty.c (pp_user_info)
+func mailFromHeader(identity string) string {
+	name, email := splitIdentity(identity)
+	var builder strings.Builder
+	builder.WriteString("From: ")
+	maxLength := mailLineWidth
+	switch {
+	case needsRFC2047(name):
+		appendRFC2047(&builder, name, len("From: "), true)
+		maxLength = 76
+	case needsRFC822Quoting(name):
+		appendWrapped(&builder, rfc822Quote(name), -len("From: "), 1, maxLength)
+	default:
+		appendWrapped(&builder, name, -len("From: "), 1, maxLength)
+	}
+	header := builder.String()
+	lastLine := header[strings.LastIndex(header, "\n")+1:]
+	if len(lastLine)+len(" <")+len(email)+len(">") > maxLength {
+		builder.WriteByte('\n')
+	}
+	fmt.Fprintf(&builder, " <%s>\n", email)
+	return builder.String()
+}
+
+// "Name <email>" as stored in commits
+func splitIdentity(identity string) (string, string) {
+	start := strings.LastIndex(identity, "<")
+	if start == -1 {
+		return strings.TrimSpace(identity), ""
+	}
+	return strings.TrimSpace(identity[:start]), strings.TrimSuffix(identity[start+1:], ">")
+}
+
+// The title is the first paragraph joined into one line, the body is everything after it without
+// the blank lines around it
+// Ref https://github.com/git/git/blob/master/pretty.c (format_subject)
+func splitCommitMessage(message string) (string, string) {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	var title []string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		title = append(title, strings.TrimRight(lines[i], " \t\r"))
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	body := ""
+	if i < len(lines) {
+		body = strings.Join(lines[i:], "\n") + "\n"
+	}
+	return strings.Join(title, " "), body
+}
+
+// "0001-Fix-the-thing.patch" - words of the subject joined by dashes and cut to fit
+// Ref https://github.com/git/git/blob/master/pretty.c (format_sanitized_subject)
+func patchFileName(number int, subject string) string {
+	const maxLength = 64 - len(".patch") - 1
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%04d-", number)
+	start := builder.Len()
+	space := 2
+	for i := 0; i < len(subject); i++ {
+		c := subject[i]
+		if !isAlphanumeric(c) && c != '.' && c != '_' {
+			space |= 1
+			continue
+		}
+		if space == 1 {
+			builder.WriteByte('-')
+		}
+		space = 0
+		builder.WriteByte(c)
+		// Runs of dots would make ".." which means something else in paths
+		for c == '.' && i+1 < len(subject) && subject[i+1] == '.' {
+			i++
+		}
+	}
+	name := builder.String()
+	for len(name) > start && (name[len(name)-1] == '.' || name[len(name)-1] == '-') {
+		name = name[:len(name)-1]
+	}
+	if len(name) > maxLength {
+		name = name[:maxLength]
+	}
+	return name + ".patch"
+}
+
+func printFormatPatchUsage() {
+	fmt.Println("Usage: gitgood format-patch [<options>] [-o <dir>] [--stdout] [--root] [-<n>] [<since> | <revision range>]     Prepare each commit as an email with its patch")
+	fmt.Println("Options: --signature=<text>, --no-signature, -U<n>, --diff-algorithm=<algorithm>")
+}
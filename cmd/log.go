@@ -2,38 +2,116 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/CLBRITTON2/go-git-good/common"
 	"github.com/CLBRITTON2/go-git-good/objects"
 )
 
-func Log() {
+// Escape codes for the commit line, all empty without color
+type logColors struct {
+	commit string
+	head   string
+	branch string
+	reset  string
+}
+
+func Log(flags []string) {
+	colorWhen := ""
+	var revisions []string
+	for _, flag := range flags {
+		handled, err := parseColorFlag(flag, &colorWhen)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		if handled {
+			continue
+		}
+		if strings.HasPrefix(flag, "-") {
+			fmt.Println("Unsupported flag...")
+			printLogUsage()
+			return
+		}
+		revisions = append(revisions, flag)
+	}
+	if len(revisions) > 1 {
+		printLogUsage()
+		return
+	}
 	repository, err := common.FindRepository(".")
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
+	colors, err := readLogColors(repository, colorWhen)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
 	branch, err := repository.GetBranch()
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	// Empty ref is returned if this is the first commit
-	ref, err := repository.FindRef(branch)
+	// Empty hash is returned if this is the first commit
+	headHash, err := repository.ResolveHead()
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	if ref.Hash.Empty() {
+
+	startHash := headHash
+	if len(revisions) == 1 {
+		startHash, err = objects.ResolveCommitish(repository, revisions[0])
+		if err != nil {
+			fmt.Printf("fatal: %v\n", err)
+			return
+		}
+	} else if headHash.Empty() {
 		fmt.Printf("fatal: your current branch '%s' does not have any commits yet\n", branch)
 		return
 	}
+	ref := &common.Ref{
+		Name: branch,
+		Hash: startHash,
+	}
 
 	// Start recursive commit printing
-	printCommitHistory(repository, ref, branch, true)
+	printCommitHistory(repository, ref, branch, startHash == headHash, colors)
+}
+
+// Log is colored like diff output: by color.diff or color.ui unless a flag says otherwise
+func readLogColors(repository *common.Repository, colorWhen string) (*logColors, error) {
+	config, err := repository.Config()
+	if err != nil {
+		return nil, err
+	}
+	colors := &logColors{}
+	color, err := useColor(config, colorWhen)
+	if err != nil || !color {
+		return colors, err
+	}
+	colors.reset = common.ColorReset
+	slots := []struct {
+		color        *string
+		key          string
+		defaultColor string
+	}{
+		{&colors.commit, "color.diff.commit", "yellow"},
+		{&colors.head, "color.decorate.HEAD", "bold cyan"},
+		{&colors.branch, "color.decorate.branch", "bold green"},
+	}
+	for _, slot := range slots {
+		*slot.color, err = configColor(config, slot.key, slot.defaultColor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return colors, nil
 }
 
-func printCommitHistory(repository *common.Repository, ref *common.Ref, branch string, isHead bool) {
+func printCommitHistory(repository *common.Repository, ref *common.Ref, branch string, isHead bool, colors *logColors) {
 	rawCommitData, err := repository.ReadObject(ref.Hash.String())
 	if err != nil {
 		fmt.Printf("%v\n", err)
@@ -45,9 +123,14 @@ func printCommitHistory(repository *common.Repository, ref *common.Ref, branch s
 		return
 	}
 	// Format the commit header: include (HEAD -> branch) only for the HEAD commit
-	commitHeader := fmt.Sprintf("commit %s", ref.Hash.String())
-	if isHead {
-		commitHeader += fmt.Sprintf(" (HEAD -> %s)", branch)
+	// or just (HEAD) when it's detached
+	// With color the parentheses keep the commit color and each name gets its own
+	commitHeader := colors.commit + "commit " + ref.Hash.String() + colors.reset
+	decoration := colors.commit + " (" + colors.reset
+	if isHead && branch == "" {
+		commitHeader += decoration + colors.head + "HEAD" + colors.reset + colors.commit + ")" + colors.reset
+	} else if isHead {
+		commitHeader += decoration + colors.head + "HEAD -> " + colors.reset + colors.branch + branch + colors.reset + colors.commit + ")" + colors.reset
 	}
 	fmt.Printf("%s\nAuthor: %s\nDate: %v\n\n    %s\n", commitHeader, commit.Author, commit.Timestamp.Format("Mon Jan 02 15:04:05 2006 -0700"), commit.Message)
 
@@ -57,6 +140,10 @@ func printCommitHistory(repository *common.Repository, ref *common.Ref, branch s
 			Name: branch,
 			Hash: commit.Parents[0],
 		}
-		printCommitHistory(repository, parentRef, branch, false)
+		printCommitHistory(repository, parentRef, branch, false, colors)
 	}
 }
+
+func printLogUsage() {
+	fmt.Println("Usage: gitgood log [--color[=<when>] | --no-color] [<revision>]          Show the commit history starting at HEAD or the revision")
+}
@@ -26,7 +26,7 @@ func LsFiles(flags []string) {
 
 	if len(flags) == 1 && flags[0] == "-s" {
 		for _, entry := range index.Entries {
-			fmt.Printf("%o %v         %v\n", entry.FileMode, entry.Hash.String(), entry.EntryPath)
+			fmt.Printf("%o %v %d\t%v\n", entry.FileMode, entry.Hash.String(), entry.Stage(), entry.EntryPath)
 		}
 		return
 	}
@@ -37,5 +37,5 @@ func LsFiles(flags []string) {
 
 func printLsFilesUsage() {
 	fmt.Println("Usage: gitgood ls-files          Show information about files in the index")
-	fmt.Println("Usage: gitgood ls-files -s       Show staged contents' mode bits, object hash, and index entry path")
+	fmt.Println("Usage: gitgood ls-files -s       Show staged contents' mode bits, object hash, stage number, and index entry path")
 }
@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
-	"strings"
 
 	"github.com/CLBRITTON2/go-git-good/common"
 	"github.com/CLBRITTON2/go-git-good/objects"
@@ -14,64 +12,29 @@ func LsTree(flags []string) {
 		printLsTreeUsage()
 		return
 	}
-	objectHash := flags[0]
-	if len(objectHash) != 40 {
-		fmt.Printf("invalid hash length: expected length 40 got %v\n", len(objectHash))
-		return
-	}
 	repository, err := common.FindRepository(".")
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
-
-	rawObjectData, err := repository.ReadObject(objectHash)
+	hash, err := objects.ResolveRevision(repository, flags[0])
 	if err != nil {
-		fmt.Printf("%v\n", err)
+		fmt.Printf("fatal: %v\n", err)
 		return
 	}
 
-	nullIndex := bytes.IndexByte(rawObjectData, byte('\x00'))
-	if nullIndex == -1 {
-		fmt.Printf("invalid object format: no null byte found")
+	// Commits and tags are peeled down to their tree
+	treeHash, err := objects.PeelRevision(repository, hash, "tree")
+	if err != nil {
+		fmt.Printf("fatal: %v\n", err)
 		return
 	}
-
-	header := string(rawObjectData[:nullIndex])
-	parts := strings.Split(header, " ")
-	if len(parts) != 2 {
-		fmt.Printf("invalid object header format expected <type> <data length> got: %s", header)
+	tree, err := objects.ReadTree(repository, treeHash)
+	if err != nil {
+		fmt.Printf("%v\n", err)
 		return
 	}
 
-	objectType := parts[0]
-	var tree *objects.Tree
-	switch objectType {
-	case "tree":
-		tree, err = objects.ParseTree(rawObjectData)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-			return
-		}
-	case "commit":
-		commit, err := objects.ParseCommit(rawObjectData)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-			return
-		}
-		tree = commit.Tree
-		rawTreeData, err := repository.ReadObject(tree.Hash.String())
-		if err != nil {
-			fmt.Printf("%v\n", err)
-			return
-		}
-		tree, err = objects.ParseTree(rawTreeData)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-			return
-		}
-	}
-
 	// Create the same format that Git uses for ls-tree and cat-file -p with a tree hash
 	for _, entry := range tree.Entries {
 		objectType := ""
@@ -86,5 +49,5 @@ func LsTree(flags []string) {
 }
 
 func printLsTreeUsage() {
-	fmt.Println("Usage: gitgood ls-tree <tree-hash>          Print the tree contents")
+	fmt.Println("Usage: gitgood ls-tree <tree-ish>          Print the tree contents")
 }
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Header lines in patch emails are kept to this many columns
+const mailLineWidth = 78
+
+func isMailSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isAlphanumeric(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func hasNonASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
+
+// Word wrap text at width columns with continuation lines indented by indent - a negative first
+// indent means the first line already has that many columns in front of it
+// Ref https://github.com/git/git/blob/master/utf8.c (strbuf_add_wrapped_text)
+func appendWrapped(builder *strings.Builder, text string, firstIndent, indent, width int) {
+	lineStart := 0
+	columns, lineIndent := firstIndent, firstIndent
+	// Where the last word ended, the text so far is written up to it
+	space := -1
+	if firstIndent < 0 {
+		columns = -firstIndent
+		space = 0
+	}
+
+	for i := 0; ; {
+		end := i >= len(text)
+		if !end && !isMailSpace(text[i]) {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			columns++
+			i += size
+			continue
+		}
+
+		newLine := true
+		if columns <= width || space == -1 {
+			start := lineStart
+			if end && i == start {
+				return
+			}
+			if space != -1 {
+				start = space
+			} else {
+				builder.WriteString(strings.Repeat(" ", max(lineIndent, 0)))
+			}
+			builder.WriteString(text[start:i])
+			if end {
+				return
+			}
+			space = i
+			newLine = false
+			switch text[i] {
+			case '\t':
+				columns |= 0x07
+			case '\n':
+				// Paragraphs and lines that don't start with a word keep their line breaks
+				space++
+				switch {
+				case space < len(text) && text[space] == '\n':
+					builder.WriteByte('\n')
+					newLine = true
+				case space >= len(text) || !isAlphanumeric(text[space]):
+					newLine = true
+				default:
+					builder.WriteByte(' ')
+				}
+			}
+			if !newLine {
+				columns++
+				i++
+				continue
+			}
+		}
+
+		builder.WriteByte('\n')
+		i = space
+		if i < len(text) && isMailSpace(text[i]) {
+			i++
+		}
+		lineStart = i
+		space = -1
+		columns, lineIndent = indent, indent
+	}
+}
+
+// Headers can only hold ASCII and "=?" would be taken for the start of an encoded word
+func needsRFC2047(text string) bool {
+	return hasNonASCII(text) || strings.Contains(text, "\n") || strings.Contains(text, "=?")
+}
+
+// Quoted printable encoded words in UTF-8, split so no line goes past 76 columns - lineLength is
+// how much of the current line is already used
+// Ref https://github.com/git/git/blob/master/pretty.c (add_rfc2047)
+func appendRFC2047(builder *strings.Builder, text string, lineLength int, address bool) {
+	const maxEncodedLength = 76
+	builder.WriteString("=?UTF-8?q?")
+	lineLength += len("UTF-8") + 5
+	for len(text) > 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		character := text[:size]
+		text = text[size:]
+
+		c := character[0]
+		special := size > 1 || c >= 0x80 || c < 0x20 || c == 0x7f || c == ' ' || c == '=' || c == '?' || c == '_'
+		// Names also can't hold anything that means something in an address
+		if address && (c == '(' || c == ')' || c == '"') {
+			special = true
+		}
+		encoded := character
+		if special {
+			encoded = ""
+			for i := 0; i < size; i++ {
+				encoded += fmt.Sprintf("=%02X", character[i])
+			}
+		}
+		if lineLength+len(encoded)+2 > maxEncodedLength {
+			builder.WriteString("?=\n =?UTF-8?q?")
+			lineLength = len("UTF-8") + 5 + 1
+		}
+		builder.WriteString(encoded)
+		lineLength += len(encoded)
+	}
+	builder.WriteString("?=")
+}
+
+// Names with characters special in addresses go in double quotes
+func needsRFC822Quoting(name string) bool {
+	return strings.ContainsAny(name, "()<>@,;:\\\".[]")
+}
+
+func rfc822Quote(name string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		if name[i] == '"' || name[i] == '\\' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(name[i])
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+func rfc822Unquote(name string) string {
+	if len(name) < 2 || name[0] != '"' || name[len(name)-1] != '"' {
+		return name
+	}
+	var builder strings.Builder
+	for i := 1; i < len(name)-1; i++ {
+		if name[i] == '\\' && i+1 < len(name)-1 {
+			i++
+		}
+		builder.WriteByte(name[i])
+	}
+	return builder.String()
+}
+
+// Turn the encoded words of a header back into text
+func decodeRFC2047(value string) string {
+	var builder strings.Builder
+	previousEncoded := false
+	for {
+		start := strings.Index(value, "=?")
+		if start == -1 {
+			builder.WriteString(value)
+			return builder.String()
+		}
+		decoded, length, ok := decodeEncodedWord(value[start:])
+		if !ok {
+			builder.WriteString(value[:start+2])
+			value = value[start+2:]
+			previousEncoded = false
+			continue
+		}
+		// Whitespace between two encoded words is only there to break the line
+		if !previousEncoded || strings.Trim(value[:start], " \t\n") != "" {
+			builder.WriteString(value[:start])
+		}
+		builder.WriteString(decoded)
+		previousEncoded = true
+		value = value[start+length:]
+	}
+}
+
+// "=?charset?q?text?=" or "=?charset?b?text?=" - the charset is assumed to be UTF-8 compatible
+func decodeEncodedWord(word string) (string, int, bool) {
+	parts := strings.SplitN(word[2:], "?", 3)
+	if len(parts) != 3 {
+		return "", 0, false
+	}
+	text, _, found := strings.Cut(parts[2], "?=")
+	if !found {
+		return "", 0, false
+	}
+	length := 2 + len(parts[0]) + 1 + len(parts[1]) + 1 + len(text) + 2
+	switch strings.ToLower(parts[1]) {
+	case "q":
+		var builder strings.Builder
+		for i := 0; i < len(text); i++ {
+			switch {
+			case text[i] == '_':
+				builder.WriteByte(' ')
+			case text[i] == '=' && i+2 < len(text):
+				value, err := strconv.ParseUint(text[i+1:i+3], 16, 8)
+				if err != nil {
+					return "", 0, false
+				}
+				builder.WriteByte(byte(value))
+				i += 2
+			default:
+				builder.WriteByte(text[i])
+			}
+		}
+		return builder.String(), length, true
+	case "b":
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return "", 0, false
+		}
+		return string(decoded), length, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+// Move loose refs into packed-refs - by default only tags and refs that are already packed
+// since branches are expected to move and are cheaper to update as loose files
+func PackRefs(flags []string) {
+	all := false
+	prune := true
+	for _, flag := range flags {
+		switch flag {
+		case "--all":
+			all = true
+		case "--prune":
+			prune = true
+		case "--no-prune":
+			prune = false
+		default:
+			printPackRefsUsage()
+			return
+		}
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	err = packRefs(repository, all, prune)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// packed-refs stays locked from reading it to writing it back and each loose ref is only pruned
+// while it still holds the value that was packed
+// Ref https://github.com/git/git/blob/master/refs/files-backend.c (files_pack_refs)
+func packRefs(repository *common.Repository, all, prune bool) error {
+	lock, err := repository.LockPackedRefs()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	packedRefs, err := repository.ReadPackedRefs()
+	if err != nil {
+		return err
+	}
+	refsByName := make(map[string]*common.Ref, len(packedRefs))
+	for _, ref := range packedRefs {
+		refsByName[ref.Name] = ref
+	}
+
+	looseRefs, err := repository.LooseRefs("refs/")
+	if err != nil {
+		return err
+	}
+	var packedLooseRefs []*common.Ref
+	for _, ref := range looseRefs {
+		// Symbolic refs like refs/remotes/origin/HEAD always stay loose
+		if ref.Target != "" {
+			continue
+		}
+		_, alreadyPacked := refsByName[ref.Name]
+		if !all && !alreadyPacked && !strings.HasPrefix(ref.Name, "refs/tags/") {
+			continue
+		}
+		refsByName[ref.Name] = ref
+		packedLooseRefs = append(packedLooseRefs, ref)
+	}
+
+	// Recording what annotated tags point at saves readers from opening the tag objects
+	refs := make([]*common.Ref, 0, len(refsByName))
+	for _, ref := range refsByName {
+		ref.Peeled, err = objects.PeelTag(repository, ref.Hash)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
+	err = lock.Write(refs)
+	if err != nil {
+		return err
+	}
+
+	if !prune {
+		return nil
+	}
+	for _, ref := range packedLooseRefs {
+		err = repository.PruneLooseRef(ref)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printPackRefsUsage() {
+	fmt.Println("Usage: gitgood pack-refs [--all] [--no-prune]    Pack tags (or all refs with --all) into packed-refs")
+}
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+func ReadTree(flags []string) {
+	merge := false
+	prefix := ""
+	var treeishes []string
+	for _, flag := range flags {
+		switch {
+		case flag == "-m":
+			merge = true
+		case strings.HasPrefix(flag, "--prefix="):
+			prefix = strings.TrimSuffix(strings.TrimPrefix(flag, "--prefix="), "/")
+		case strings.HasPrefix(flag, "-"):
+			fmt.Println("Unsupported flag...")
+			printReadTreeUsage()
+			return
+		default:
+			treeishes = append(treeishes, flag)
+		}
+	}
+	if len(treeishes) == 0 || len(treeishes) > 3 || (!merge && len(treeishes) > 1) {
+		printReadTreeUsage()
+		return
+	}
+	if prefix != "" && merge {
+		fmt.Println("--prefix cannot be used with -m")
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	var trees []map[string]*common.IndexEntry
+	for _, treeish := range treeishes {
+		treeEntries, err := readTreeEntries(repository, treeish, prefix)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		trees = append(trees, treeEntries)
+	}
+
+	var mergedEntries []*common.IndexEntry
+	switch {
+	case prefix != "":
+		// Graft the tree under the prefix and leave everything else in the index alone
+		for _, entry := range index.Entries {
+			if entry.EntryPath == prefix || strings.HasPrefix(entry.EntryPath, prefix+"/") {
+				fmt.Printf("subdirectory '%s/' already exists\n", prefix)
+				return
+			}
+		}
+		mergedEntries = append(index.Entries, entryList(trees[0])...)
+	case !merge:
+		mergedEntries = entryList(trees[0])
+	default:
+		if index.HasConflicts() {
+			fmt.Println("you need to resolve your current index first")
+			return
+		}
+		switch len(trees) {
+		case 1:
+			mergedEntries = oneWayMerge(index, trees[0])
+		case 2:
+			mergedEntries, err = twoWayMerge(repository, index, trees[0], trees[1])
+		case 3:
+			mergedEntries, err = threeWayMerge(index, trees[0], trees[1], trees[2])
+		}
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+
+	index.Entries = nil
+	index.AddEntries(mergedEntries)
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+// Flatten a tree-ish into index entries keyed by path
+func readTreeEntries(repository *common.Repository, treeish string, prefix string) (map[string]*common.IndexEntry, error) {
+	hash, err := objects.ResolveRevision(repository, treeish)
+	if err != nil {
+		return nil, fmt.Errorf("fatal: %v", err)
+	}
+	treeHash, err := objects.PeelRevision(repository, hash, "tree")
+	if err != nil {
+		return nil, err
+	}
+	tree, err := objects.ReadTree(repository, treeHash)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := objects.IndexEntriesFromTree(repository, tree, prefix)
+	if err != nil {
+		return nil, err
+	}
+	treeEntries := make(map[string]*common.IndexEntry, len(entries))
+	for _, entry := range entries {
+		treeEntries[entry.EntryPath] = entry
+	}
+	return treeEntries, nil
+}
+
+func entryList(entries map[string]*common.IndexEntry) []*common.IndexEntry {
+	list := make([]*common.IndexEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	return list
+}
+
+// Every path touched by the index or any of the trees
+func mergePaths(index *common.Index, trees ...map[string]*common.IndexEntry) map[string]bool {
+	paths := make(map[string]bool)
+	for _, entry := range index.Entries {
+		paths[entry.EntryPath] = true
+	}
+	for _, tree := range trees {
+		for entryPath := range tree {
+			paths[entryPath] = true
+		}
+	}
+	return paths
+}
+
+// Replace the index with the tree but keep the stat data of entries that didn't change
+// so the working tree doesn't have to be re-hashed
+func oneWayMerge(index *common.Index, tree map[string]*common.IndexEntry) []*common.IndexEntry {
+	var mergedEntries []*common.IndexEntry
+	for entryPath, treeEntry := range tree {
+		indexEntry := index.FindEntry(entryPath)
+		if sameEntry(indexEntry, treeEntry) {
+			mergedEntries = append(mergedEntries, indexEntry)
+			continue
+		}
+		mergedEntries = append(mergedEntries, treeEntry)
+	}
+	return mergedEntries
+}
+
+// Move the index from the head tree to the target tree while carrying local changes over
+// Ref https://git-scm.com/docs/git-read-tree#_two_tree_merge for the full table of cases
+func twoWayMerge(repository *common.Repository, index *common.Index, head, target map[string]*common.IndexEntry) ([]*common.IndexEntry, error) {
+	// An empty index means this is the initial checkout so there's nothing local to preserve
+	initialCheckout := len(index.Entries) == 0
+
+	var mergedEntries []*common.IndexEntry
+	for entryPath := range mergePaths(index, head, target) {
+		indexEntry := index.FindEntry(entryPath)
+		headEntry := head[entryPath]
+		targetEntry := target[entryPath]
+
+		if indexEntry == nil {
+			switch {
+			case headEntry == nil:
+				mergedEntries = append(mergedEntries, targetEntry)
+			case targetEntry == nil:
+				// Removed by the target and already removed from the index
+			case sameEntry(headEntry, targetEntry):
+				if initialCheckout {
+					mergedEntries = append(mergedEntries, targetEntry)
+				}
+			default:
+				return nil, fmt.Errorf("entry '%s' would be overwritten by merge: cannot merge", entryPath)
+			}
+			continue
+		}
+
+		// Local changes are kept as long as the target tree doesn't also change the path
+		if sameEntry(headEntry, targetEntry) || sameEntry(indexEntry, targetEntry) {
+			mergedEntries = append(mergedEntries, indexEntry)
+			continue
+		}
+		if !sameEntry(indexEntry, headEntry) {
+			return nil, fmt.Errorf("entry '%s' would be overwritten by merge: cannot merge", entryPath)
+		}
+		clean, err := worktreeIsUpToDate(repository, index, indexEntry)
+		if err != nil {
+			return nil, err
+		}
+		if !clean {
+			return nil, fmt.Errorf("entry '%s' not uptodate: cannot merge", entryPath)
+		}
+		if targetEntry != nil {
+			mergedEntries = append(mergedEntries, targetEntry)
+		}
+	}
+	return mergedEntries, nil
+}
+
+// Merge the changes between base and ours with the changes between base and theirs
+// Trivial cases collapse to stage 0 and everything else is left as conflict stages 1 (base), 2 (ours) and 3 (theirs)
+func threeWayMerge(index *common.Index, base, ours, theirs map[string]*common.IndexEntry) ([]*common.IndexEntry, error) {
+	var mergedEntries []*common.IndexEntry
+	for entryPath := range mergePaths(index, base, ours, theirs) {
+		indexEntry := index.FindEntry(entryPath)
+		baseEntry := base[entryPath]
+		ourEntry := ours[entryPath]
+		theirEntry := theirs[entryPath]
+
+		// The index may differ from our tree only where the merge keeps our side anyway
+		keepsOurs := sameEntry(ourEntry, theirEntry) || (sameEntry(baseEntry, theirEntry) && ourEntry != nil)
+		if keepsOurs {
+			if indexEntry != nil {
+				mergedEntries = append(mergedEntries, indexEntry)
+			}
+			continue
+		}
+		if !sameEntry(indexEntry, ourEntry) {
+			return nil, fmt.Errorf("entry '%s' would be overwritten by merge: cannot merge", entryPath)
+		}
+
+		// Only their side changed the path
+		if sameEntry(baseEntry, ourEntry) && theirEntry != nil {
+			mergedEntries = append(mergedEntries, theirEntry)
+			continue
+		}
+
+		for stage, entry := range []*common.IndexEntry{baseEntry, ourEntry, theirEntry} {
+			if entry != nil {
+				entry.SetStage(stage + 1)
+				mergedEntries = append(mergedEntries, entry)
+			}
+		}
+	}
+	return mergedEntries, nil
+}
+
+func printReadTreeUsage() {
+	fmt.Println("Usage: gitgood read-tree <tree-ish>                      Replace the index with the contents of a tree")
+	fmt.Println("Usage: gitgood read-tree --prefix=<dir>/ <tree-ish>      Read a tree into the index under a subdirectory")
+	fmt.Println("Usage: gitgood read-tree -m <tree-ish>                   Read a tree keeping stat data of unchanged entries")
+	fmt.Println("Usage: gitgood read-tree -m <head> <target>              Two-way merge moving the index from head to target")
+	fmt.Println("Usage: gitgood read-tree -m <base> <ours> <theirs>       Three-way merge writing conflicts as index stages")
+}
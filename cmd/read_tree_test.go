@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// An entry for path "a" holding the content - contents double as names in the tables below
+func mergeEntry(t *testing.T, content string) *common.IndexEntry {
+	t.Helper()
+	if content == "" {
+		return nil
+	}
+	hash, err := blobHash([]byte(content))
+	if err != nil {
+		t.Fatalf("expected no error hashing %s, got %v", content, err)
+	}
+	return &common.IndexEntry{Hash: hash, FileMode: 0100644, EntryPath: "a"}
+}
+
+func mergeTree(t *testing.T, content string) map[string]*common.IndexEntry {
+	t.Helper()
+	tree := map[string]*common.IndexEntry{}
+	if entry := mergeEntry(t, content); entry != nil {
+		tree["a"] = entry
+	}
+	return tree
+}
+
+// The merged entries for "a" as content@stage, comparing by hash against the contents that were used
+func describeMerge(t *testing.T, entries []*common.IndexEntry, contents ...string) string {
+	t.Helper()
+	var described []string
+	for stage := 0; stage <= 3; stage++ {
+		for _, entry := range entries {
+			if entry.EntryPath != "a" || entry.Stage() != stage {
+				continue
+			}
+			name := "?"
+			for _, content := range contents {
+				if expected := mergeEntry(t, content); expected != nil && expected.Hash == entry.Hash {
+					name = content
+				}
+			}
+			described = append(described, name+"@"+string(rune('0'+stage)))
+		}
+	}
+	return strings.Join(described, " ")
+}
+
+func TestOneWayMerge(t *testing.T) {
+	cases := []struct {
+		name, index, tree, expected string
+		keepsStat                   bool
+	}{
+		{"unchanged keeps the stat data", "x", "x", "x@0", true},
+		{"changed takes the tree", "x", "y", "y@0", false},
+		{"new in the tree", "", "y", "y@0", false},
+		{"gone from the tree", "x", "", "", false},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			index := &common.Index{}
+			if entry := mergeEntry(t, test.index); entry != nil {
+				index.AddEntry(entry)
+			}
+			merged := oneWayMerge(index, mergeTree(t, test.tree))
+			if got := describeMerge(t, merged, "x", "y"); got != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+			if test.keepsStat && merged[0] != index.Entries[0] {
+				t.Fatalf("expected the index entry to be kept for its stat data")
+			}
+		})
+	}
+}
+
+// Ref https://git-scm.com/docs/git-read-tree#_two_tree_merge
+func TestTwoWayMerge(t *testing.T) {
+	cases := []struct {
+		name                  string
+		index, head, target   string
+		worktree              string
+		expected, expectedErr string
+	}{
+		{"new in target", "", "", "y", "", "y@0", ""},
+		{"removed from the index and the target", "", "x", "", "", "", ""},
+		{"removed from the index and unchanged", "", "x", "x", "", "", ""},
+		{"removed from the index but changed by target", "", "x", "y", "", "", "would be overwritten"},
+		{"unchanged everywhere", "x", "x", "x", "x", "x@0", ""},
+		{"staged change with target at head", "z", "x", "x", "z", "z@0", ""},
+		{"staged change matching target", "y", "x", "y", "y", "y@0", ""},
+		{"clean and changed by target", "x", "x", "y", "x", "y@0", ""},
+		{"clean and removed by target", "x", "x", "", "x", "", ""},
+		{"dirty and changed by target", "x", "x", "y", "local", "", "not uptodate"},
+		{"dirty and removed by target", "x", "x", "", "local", "", "not uptodate"},
+		{"missing and changed by target", "x", "x", "y", "", "", "not uptodate"},
+		{"staged change and changed by target", "z", "x", "y", "z", "", "would be overwritten"},
+		{"added locally", "z", "", "", "z", "z@0", ""},
+		{"added locally and by target", "z", "", "y", "z", "", "would be overwritten"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			repository := &common.Repository{WorkTree: t.TempDir()}
+			index := &common.Index{}
+			// Keeps the index from looking like an initial checkout
+			index.AddEntry(&common.IndexEntry{Hash: common.Hash{1}, FileMode: 0100644, EntryPath: "other"})
+			if entry := mergeEntry(t, test.index); entry != nil {
+				index.AddEntry(entry)
+			}
+			if test.worktree != "" {
+				if err := os.WriteFile(filepath.Join(repository.WorkTree, "a"), []byte(test.worktree), 0644); err != nil {
+					t.Fatalf("expected no error writing a, got %v", err)
+				}
+			}
+
+			merged, err := twoWayMerge(repository, index, mergeTree(t, test.head), mergeTree(t, test.target))
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := describeMerge(t, merged, "x", "y", "z"); got != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestTwoWayMergeInitialCheckout(t *testing.T) {
+	repository := &common.Repository{WorkTree: t.TempDir()}
+	merged, err := twoWayMerge(repository, &common.Index{}, mergeTree(t, "x"), mergeTree(t, "x"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := describeMerge(t, merged, "x"); got != "x@0" {
+		t.Fatalf("expected the target to be checked out into an empty index, got %q", got)
+	}
+}
+
+// Ref https://git-scm.com/docs/git-read-tree#_3_way_merge
+func TestThreeWayMerge(t *testing.T) {
+	cases := []struct {
+		name                      string
+		index, base, ours, theirs string
+		expected, expectedErr     string
+	}{
+		{"unchanged", "x", "x", "x", "x", "x@0", ""},
+		{"same change on both sides", "y", "x", "y", "y", "y@0", ""},
+		{"only ours changed", "y", "x", "y", "x", "y@0", ""},
+		{"only theirs changed", "x", "x", "x", "y", "y@0", ""},
+		{"added by theirs", "", "", "", "y", "y@0", ""},
+		{"added by ours", "y", "", "y", "", "y@0", ""},
+		{"removed on both sides", "", "x", "", "", "", ""},
+		{"both changed differently", "y", "x", "y", "z", "x@1 y@2 z@3", ""},
+		{"both added differently", "y", "", "y", "z", "y@2 z@3", ""},
+		{"changed by ours and removed by theirs", "y", "x", "y", "", "x@1 y@2", ""},
+		{"removed by ours and changed by theirs", "", "x", "", "z", "x@1 z@3", ""},
+		{"removed by theirs only", "x", "x", "x", "", "x@1 x@2", ""},
+		{"index differs from ours", "z", "x", "x", "y", "", "would be overwritten"},
+		{"index missing ours", "", "x", "x", "y", "", "would be overwritten"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			index := &common.Index{}
+			if entry := mergeEntry(t, test.index); entry != nil {
+				index.AddEntry(entry)
+			}
+			merged, err := threeWayMerge(index, mergeTree(t, test.base), mergeTree(t, test.ours), mergeTree(t, test.theirs))
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := describeMerge(t, merged, "x", "y", "z"); got != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// Entries older than this are dropped by reflog expire unless gc.reflogExpire says otherwise
+const defaultReflogExpire = "90.days.ago"
+
+func Reflog(flags []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	subcommand := "show"
+	if len(flags) > 0 && (flags[0] == "show" || flags[0] == "expire" || flags[0] == "delete") {
+		subcommand = flags[0]
+		flags = flags[1:]
+	}
+
+	switch subcommand {
+	case "show":
+		if len(flags) > 1 {
+			printReflogUsage()
+			return
+		}
+		ref := "HEAD"
+		if len(flags) == 1 {
+			ref = flags[0]
+		}
+		err = showReflog(repository, ref)
+	case "expire":
+		err = expireReflogs(repository, flags)
+	case "delete":
+		if len(flags) == 0 {
+			printReflogUsage()
+			return
+		}
+		err = deleteReflogEntries(repository, flags)
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// Map what the user typed to the ref the log belongs to: HEAD, a full ref name, a branch or a tag
+func reflogRefName(repository *common.Repository, name string) (string, error) {
+	for _, candidate := range []string{name, "refs/" + name, "refs/heads/" + name, "refs/tags/" + name} {
+		if repository.ReflogExists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("fatal: no reflog for '%s'", name)
+}
+
+// Newest entry first, numbered the way <ref>@{<n>} counts back from the current value
+func showReflog(repository *common.Repository, name string) error {
+	refName, err := reflogRefName(repository, name)
+	if err != nil {
+		return err
+	}
+	entries, err := repository.ReadReflog(refName)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Printf("%s %s@{%d}: %s\n", entry.NewHash.String()[:7], name, len(entries)-1-i, entry.Message)
+	}
+	return nil
+}
+
+func expireReflogs(repository *common.Repository, flags []string) error {
+	config, err := repository.Config()
+	if err != nil {
+		return err
+	}
+	expire, found := config.Get("gc.reflogExpire")
+	if !found {
+		expire = defaultReflogExpire
+	}
+
+	all := false
+	var names []string
+	for _, flag := range flags {
+		switch {
+		case flag == "--all":
+			all = true
+		case strings.HasPrefix(flag, "--expire="):
+			expire = strings.TrimPrefix(flag, "--expire=")
+		case strings.HasPrefix(flag, "-"):
+			printReflogUsage()
+			return nil
+		default:
+			names = append(names, flag)
+		}
+	}
+	cutoff, err := parseExpireTime(expire, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var refNames []string
+	if all {
+		refNames, err = repository.ListReflogs()
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		refName, err := reflogRefName(repository, name)
+		if err != nil {
+			return err
+		}
+		refNames = append(refNames, refName)
+	}
+	if len(refNames) == 0 {
+		printReflogUsage()
+		return nil
+	}
+
+	for _, refName := range refNames {
+		err := repository.RewriteReflog(refName, func(entries []*common.ReflogEntry) ([]*common.ReflogEntry, error) {
+			return slices.DeleteFunc(slices.Clone(entries), func(entry *common.ReflogEntry) bool {
+				return entry.Timestamp.Before(cutoff)
+			}), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var reflogSelectorPattern = regexp.MustCompile(`^(.+)@\{(\d+)\}$`)
+
+// Remove single entries named like HEAD@{2}
+func deleteReflogEntries(repository *common.Repository, selectors []string) error {
+	positions := make(map[string][]int)
+	var refNames []string
+	for _, selector := range selectors {
+		match := reflogSelectorPattern.FindStringSubmatch(selector)
+		if match == nil {
+			return fmt.Errorf("fatal: not a reflog: %s", selector)
+		}
+		refName, err := reflogRefName(repository, match[1])
+		if err != nil {
+			return err
+		}
+		position, err := strconv.Atoi(match[2])
+		if err != nil {
+			return fmt.Errorf("fatal: not a reflog: %s", selector)
+		}
+		if _, seen := positions[refName]; !seen {
+			refNames = append(refNames, refName)
+		}
+		positions[refName] = append(positions[refName], position)
+	}
+
+	for _, refName := range refNames {
+		err := repository.RewriteReflog(refName, func(entries []*common.ReflogEntry) ([]*common.ReflogEntry, error) {
+			// Positions count back from the newest entry which is stored last
+			deleted := make(map[int]bool)
+			for _, position := range positions[refName] {
+				if position >= len(entries) {
+					return nil, fmt.Errorf("error: reflog entry %s@{%d} not found", refName, position)
+				}
+				deleted[len(entries)-1-position] = true
+			}
+			var keptEntries []*common.ReflogEntry
+			for i, entry := range entries {
+				if !deleted[i] {
+					keptEntries = append(keptEntries, entry)
+				}
+			}
+			return keptEntries, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var relativeTimePattern = regexp.MustCompile(`^(\d+)[. ](second|minute|hour|day|week|month|year)s?[. ]ago$`)
+
+// The subset of git's approxidate that makes sense for expiry: now/all, never/false,
+// relative times like 2.weeks.ago and plain dates like 2024-01-31
+func parseExpireTime(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "now", "all":
+		// Entries written during this second still count as expired
+		return now.Add(time.Second), nil
+	case "never", "false":
+		return time.Time{}, nil
+	}
+
+	match := relativeTimePattern.FindStringSubmatch(value)
+	if match != nil {
+		amount, err := strconv.Atoi(match[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("fatal: invalid expiry time '%s'", value)
+		}
+		switch match[2] {
+		case "second":
+			return now.Add(-time.Duration(amount) * time.Second), nil
+		case "minute":
+			return now.Add(-time.Duration(amount) * time.Minute), nil
+		case "hour":
+			return now.Add(-time.Duration(amount) * time.Hour), nil
+		case "day":
+			return now.AddDate(0, 0, -amount), nil
+		case "week":
+			return now.AddDate(0, 0, -7*amount), nil
+		case "month":
+			return now.AddDate(0, -amount, 0), nil
+		case "year":
+			return now.AddDate(-amount, 0, 0), nil
+		}
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fatal: invalid expiry time '%s'", value)
+	}
+	return date, nil
+}
+
+func printReflogUsage() {
+	fmt.Println("Usage: gitgood reflog [show] [<ref>]                              Show the reflog of a ref (HEAD by default)")
+	fmt.Println("Usage: gitgood reflog expire [--expire=<time>] (--all | <ref>...)  Drop entries older than the expiry time")
+	fmt.Println("Usage: gitgood reflog delete <ref>@{<n>}...                       Delete single reflog entries")
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+func RevParse(flags []string) {
+	verify := false
+	shortLength := 0
+	var revisions []string
+	var locations []string
+	for _, flag := range flags {
+		switch {
+		case flag == "--verify":
+			verify = true
+		case flag == "--short":
+			shortLength = objects.DefaultShortHashLength
+		case strings.HasPrefix(flag, "--short="):
+			length, err := strconv.Atoi(strings.TrimPrefix(flag, "--short="))
+			if err != nil || length < 0 {
+				fmt.Printf("fatal: invalid --short length: %s\n", flag)
+				return
+			}
+			shortLength = length
+		case flag == "--show-toplevel" || flag == "--git-dir":
+			locations = append(locations, flag)
+		case strings.HasPrefix(flag, "-"):
+			fmt.Println("Unsupported flag...")
+			printRevParseUsage()
+			return
+		default:
+			revisions = append(revisions, flag)
+		}
+	}
+	if len(revisions) == 0 && len(locations) == 0 {
+		printRevParseUsage()
+		return
+	}
+	if verify && len(revisions) != 1 {
+		fmt.Println("fatal: Needed a single revision")
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, location := range locations {
+		switch location {
+		case "--show-toplevel":
+			fmt.Println(repository.WorkTree)
+		case "--git-dir":
+			fmt.Println(gitDirectoryForDisplay(repository))
+		}
+	}
+
+	for _, revision := range revisions {
+		hash, err := objects.ResolveRevision(repository, revision)
+		if err != nil {
+			if verify {
+				fmt.Println("fatal: Needed a single revision")
+			} else {
+				fmt.Printf("fatal: %v\n", err)
+			}
+			return
+		}
+		if shortLength == 0 {
+			fmt.Println(hash)
+			continue
+		}
+		shortHash, err := objects.ShortHash(repository, hash, shortLength)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		fmt.Println(shortHash)
+	}
+}
+
+// Like git the directory is relative when run from the top of the working tree and absolute otherwise
+func gitDirectoryForDisplay(repository *common.Repository) string {
+	workingDirectory, err := os.Getwd()
+	if err == nil && filepath.Clean(workingDirectory) == repository.WorkTree {
+		return filepath.Base(repository.GitDirectory)
+	}
+	return repository.GitDirectory
+}
+
+func printRevParseUsage() {
+	fmt.Println("Usage: gitgood rev-parse [--verify] [--short[=<n>]] <revision>...    Print the object names revisions resolve to")
+	fmt.Println("Usage: gitgood rev-parse --show-toplevel | --git-dir                 Print the working tree or repository directory")
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+type showRefOptions struct {
+	heads       bool
+	tags        bool
+	head        bool
+	verify      bool
+	quiet       bool
+	dereference bool
+	// Zero prints full hashes with the ref names, anything else prints only hashes of that length
+	hashLength int
+	hashOnly   bool
+}
+
+func ShowRef(flags []string) {
+	options := &showRefOptions{}
+	var patterns []string
+	for _, flag := range flags {
+		switch {
+		case flag == "--heads":
+			options.heads = true
+		case flag == "--tags":
+			options.tags = true
+		case flag == "--head":
+			options.head = true
+		case flag == "--verify":
+			options.verify = true
+		case flag == "-q" || flag == "--quiet":
+			options.quiet = true
+		case flag == "-d" || flag == "--dereference":
+			options.dereference = true
+		case flag == "-s" || flag == "--hash":
+			options.hashOnly = true
+		case strings.HasPrefix(flag, "--hash="):
+			length, err := strconv.Atoi(strings.TrimPrefix(flag, "--hash="))
+			if err != nil || length < 0 {
+				fmt.Printf("fatal: invalid --hash length: %s\n", flag)
+				return
+			}
+			options.hashOnly = true
+			options.hashLength = length
+		case strings.HasPrefix(flag, "-"):
+			fmt.Println("Unsupported flag...")
+			printShowRefUsage()
+			return
+		default:
+			patterns = append(patterns, flag)
+		}
+	}
+	if options.verify && len(patterns) == 0 {
+		fmt.Println("fatal: --verify requires a reference")
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if options.verify {
+		err = verifyRefs(repository, patterns, options)
+	} else {
+		err = showRefs(repository, patterns, options)
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// Patterns match the end of the ref name on whole components, so main matches
+// refs/heads/main and refs/remotes/origin/main but not refs/heads/domain
+func matchesShowRefPattern(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if name == pattern || strings.HasSuffix(name, "/"+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func showRefs(repository *common.Repository, patterns []string, options *showRefOptions) error {
+	var refs []*common.Ref
+	if options.head {
+		head, err := repository.ReadRef("HEAD")
+		if err != nil {
+			return err
+		}
+		if !head.Hash.Empty() {
+			refs = append(refs, head)
+		}
+	}
+	allRefs, err := repository.ListRefs("refs/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range allRefs {
+		// --heads and --tags together show both
+		if options.heads || options.tags {
+			isHead := options.heads && strings.HasPrefix(ref.Name, "refs/heads/")
+			isTag := options.tags && strings.HasPrefix(ref.Name, "refs/tags/")
+			if !isHead && !isTag {
+				continue
+			}
+		}
+		if matchesShowRefPattern(ref.Name, patterns) {
+			refs = append(refs, ref)
+		}
+	}
+
+	if options.quiet {
+		return nil
+	}
+	for _, ref := range refs {
+		err = printShowRef(repository, ref, options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Every argument has to be a full ref name (or HEAD) that exists
+func verifyRefs(repository *common.Repository, names []string, options *showRefOptions) error {
+	for _, name := range names {
+		if name != "HEAD" && !strings.HasPrefix(name, "refs/") {
+			return fmt.Errorf("fatal: '%s' - not a valid ref", name)
+		}
+		ref, err := repository.ReadRef(name)
+		if err != nil {
+			return err
+		}
+		if ref.Hash.Empty() {
+			return fmt.Errorf("fatal: '%s' - not a valid ref", name)
+		}
+		if options.quiet {
+			continue
+		}
+		err = printShowRef(repository, ref, options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Annotated tags get an extra ^{} line with the object they point at when dereferencing
+func printShowRef(repository *common.Repository, ref *common.Ref, options *showRefOptions) error {
+	err := printShowRefLine(repository, ref.Hash, ref.Name, options)
+	if err != nil || !options.dereference {
+		return err
+	}
+	peeled := ref.Peeled
+	if peeled.Empty() {
+		peeled, err = objects.PeelTag(repository, ref.Hash)
+		if err != nil {
+			return err
+		}
+	}
+	if peeled.Empty() {
+		return nil
+	}
+	return printShowRefLine(repository, peeled, ref.Name+"^{}", options)
+}
+
+func printShowRefLine(repository *common.Repository, hash common.Hash, name string, options *showRefOptions) error {
+	if !options.hashOnly {
+		fmt.Printf("%s %s\n", hash, name)
+		return nil
+	}
+	if options.hashLength == 0 {
+		fmt.Println(hash)
+		return nil
+	}
+	shortHash, err := objects.ShortHash(repository, hash, options.hashLength)
+	if err != nil {
+		return err
+	}
+	fmt.Println(shortHash)
+	return nil
+}
+
+func printShowRefUsage() {
+	fmt.Println("Usage: gitgood show-ref [--head] [--heads] [--tags] [-d] [-s | --hash[=<n>]] [-q] [<pattern>...]    List refs matching the patterns")
+	fmt.Println("Usage: gitgood show-ref --verify [-d] [-s | --hash[=<n>]] [-q] <ref>...                            Check that full ref names exist")
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func SparseCheckout(flags []string) {
+	if len(flags) < 1 {
+		printSparseCheckoutUsage()
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	enabled, err := repository.SparseCheckoutEnabled()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	sparse, err := common.ReadSparseCheckout(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	subcommand := flags[0]
+	directories := flags[1:]
+	switch subcommand {
+	case "init":
+		// Cone mode is the only mode supported so --cone is accepted but changes nothing
+		if len(directories) > 1 || (len(directories) == 1 && directories[0] != "--cone") {
+			printSparseCheckoutUsage()
+			return
+		}
+	case "set":
+		sparse.Directories = directories
+	case "add":
+		if !enabled {
+			fmt.Println("no sparse-checkout to add to")
+			return
+		}
+		sparse.Directories = append(sparse.Directories, directories...)
+	case "list":
+		if !enabled {
+			fmt.Println("this worktree is not sparse")
+			return
+		}
+		for _, directory := range sparse.Directories {
+			fmt.Println(directory)
+		}
+		return
+	case "disable":
+		err = setSparseCheckoutConfig(repository, false)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		err = updateSparseWorkTree(repository, nil)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+		}
+		return
+	default:
+		fmt.Println("Unsupported sparse-checkout subcommand...")
+		printSparseCheckoutUsage()
+		return
+	}
+
+	err = common.WriteSparseCheckout(repository, sparse)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	err = setSparseCheckoutConfig(repository, true)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	// Re-read so the working tree is updated from the normalized patterns
+	sparse, err = common.ReadSparseCheckout(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	err = updateSparseWorkTree(repository, sparse)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+func setSparseCheckoutConfig(repository *common.Repository, enabled bool) error {
+	config, err := repository.Config()
+	if err != nil {
+		return err
+	}
+	config.Set("core.sparseCheckout", fmt.Sprintf("%v", enabled))
+	if enabled {
+		config.Set("core.sparseCheckoutCone", "true")
+	}
+	return repository.WriteConfig(config)
+}
+
+// Sync skip worktree bits and the working tree with the sparse patterns
+func updateSparseWorkTree(repository *common.Repository, sparse *common.SparseCheckout) error {
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return err
+	}
+	err = applySparseCheckout(repository, index, sparse)
+	if err != nil {
+		return err
+	}
+	return common.WriteIndex(repository, index)
+}
+
+// Mark entries outside the patterns skip worktree and remove their files, and bring back
+// entries that are now included - a nil sparse checkout includes everything
+// Modified files are never removed and files already sitting where an entry comes back are never
+// overwritten so local work isn't lost
+func applySparseCheckout(repository *common.Repository, index *common.Index, sparse *common.SparseCheckout) error {
+	var notUpToDate, alreadyPresent []string
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 || entry.IntentToAdd() {
+			continue
+		}
+		included := sparse == nil || sparse.Includes(entry.EntryPath)
+
+		if included && entry.SkipWorktree() {
+			present, err := sparseEntryPresent(repository, index, entry)
+			if err != nil {
+				return err
+			}
+			if present {
+				alreadyPresent = append(alreadyPresent, entry.EntryPath)
+				continue
+			}
+			entry.SetSkipWorktree(false)
+			clean, err := worktreeIsUpToDate(repository, index, entry)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				err = checkoutEntry(repository, entry, "")
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if !included && !entry.SkipWorktree() {
+			clean, err := worktreeIsUpToDate(repository, index, entry)
+			if err != nil {
+				return err
+			}
+			if !clean {
+				notUpToDate = append(notUpToDate, entry.EntryPath)
+				continue
+			}
+			err = removeFromWorkTree(repository, entry.EntryPath)
+			if err != nil {
+				return err
+			}
+			entry.SetSkipWorktree(true)
+		}
+	}
+
+	if len(notUpToDate) > 0 {
+		fmt.Println("warning: The following paths are not up to date and were left despite sparse patterns:")
+		for _, entryPath := range notUpToDate {
+			fmt.Printf("\t%s\n", entryPath)
+		}
+	}
+	if len(alreadyPresent) > 0 {
+		fmt.Println("warning: The following paths were already present and thus not updated despite sparse patterns:")
+		for _, entryPath := range alreadyPresent {
+			fmt.Printf("\t%s\n", entryPath)
+		}
+	}
+	return nil
+}
+
+// Reports whether something other than the entry's own content is where a skip worktree entry would
+// be written back - an untracked or modified file, a directory, or a file where a leading directory goes
+// Ref https://github.com/git/git/blob/master/unpack-trees.c (verify_absent_sparse)
+func sparseEntryPresent(repository *common.Repository, index *common.Index, entry *common.IndexEntry) (bool, error) {
+	fileInfo, err := os.Lstat(filepath.Join(repository.WorkTree, entry.EntryPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if errors.Is(err, syscall.ENOTDIR) {
+			return true, nil
+		}
+		return false, err
+	}
+	if fileInfo.IsDir() && entry.FileMode != 0160000 {
+		return true, nil
+	}
+	clean, err := worktreeIsUpToDate(repository, index, entry)
+	return !clean, err
+}
+
+func printSparseCheckoutUsage() {
+	fmt.Println("Usage: gitgood sparse-checkout init [--cone]         Enable sparse checkout with only the root directory")
+	fmt.Println("Usage: gitgood sparse-checkout set <dir>...          Only check out the given directories")
+	fmt.Println("Usage: gitgood sparse-checkout add <dir>...          Add directories to the sparse checkout")
+	fmt.Println("Usage: gitgood sparse-checkout list                  List the directories in the sparse checkout")
+	fmt.Println("Usage: gitgood sparse-checkout disable               Restore the full working tree")
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func TestSparseCheckoutKeepsFilesAlreadyPresent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Init(nil)
+	writeTestFile(t, "kept/a", "a\n")
+	writeTestFile(t, "out/changed", "tracked\n")
+	writeTestFile(t, "out/same", "same\n")
+	writeTestFile(t, "out/dir", "file\n")
+	Add([]string{"."})
+	Commit([]string{"-m", "first"})
+	SparseCheckout([]string{"set", "kept"})
+	if _, err := os.Stat("out"); !os.IsNotExist(err) {
+		t.Fatalf("expected out to be removed, got %v", err)
+	}
+
+	// Local files show up where the entries come back
+	writeTestFile(t, "out/changed", "local\n")
+	writeTestFile(t, "out/same", "same\n")
+	writeTestFile(t, "out/dir/nested", "local\n")
+	SparseCheckout([]string{"disable"})
+
+	if data, err := os.ReadFile("out/changed"); err != nil || string(data) != "local\n" {
+		t.Fatalf("expected the local file to be kept, got %q %v", data, err)
+	}
+	if data, err := os.ReadFile("out/dir/nested"); err != nil || string(data) != "local\n" {
+		t.Fatalf("expected the local directory to be kept, got %q %v", data, err)
+	}
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		t.Fatalf("expected no error reading the index, got %v", err)
+	}
+	for entryPath, skipWorktree := range map[string]bool{"out/changed": true, "out/dir": true, "out/same": false, "kept/a": false} {
+		entry := index.FindEntry(entryPath)
+		if entry == nil || entry.SkipWorktree() != skipWorktree {
+			t.Errorf("expected %s to have skip worktree %v, got %v", entryPath, skipWorktree, entry)
+		}
+	}
+}
@@ -0,0 +1,708 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+type statusFormat int
+
+const (
+	longStatus statusFormat = iota
+	shortStatus
+	porcelainV1Status
+	porcelainV2Status
+)
+
+// One tracked path that differs somewhere between HEAD, the index and the working tree
+// Staged and unstaged use the short format letters: ' ', M, A, D and U for unmerged paths
+type statusEntry struct {
+	path     string
+	staged   byte
+	unstaged byte
+	// Nil when the path isn't in HEAD or the index
+	headEntry  *common.IndexEntry
+	indexEntry *common.IndexEntry
+	// Zero when the file is gone from the working tree
+	worktreeMode uint32
+	// Base, ours and theirs for unmerged paths
+	stages [3]*common.IndexEntry
+}
+
+func (entry *statusEntry) unmerged() bool {
+	return entry.stages != [3]*common.IndexEntry{}
+}
+
+type statusBranch struct {
+	// Empty for a detached HEAD
+	name string
+	head common.Hash
+	// Full ref name of the configured upstream, empty when there isn't one
+	upstream     string
+	upstreamGone bool
+	ahead        int
+	behind       int
+}
+
+type repositoryStatus struct {
+	branch    *statusBranch
+	entries   []*statusEntry
+	untracked []string
+}
+
+func Status(flags []string) {
+	format := longStatus
+	showBranch := false
+	untrackedMode := "normal"
+	for _, flag := range expandShortFlags(flags, "sb") {
+		switch {
+		case flag == "-s" || flag == "--short":
+			format = shortStatus
+		case flag == "--long":
+			format = longStatus
+		case flag == "--porcelain" || flag == "--porcelain=v1":
+			format = porcelainV1Status
+		case flag == "--porcelain=v2":
+			format = porcelainV2Status
+		case flag == "-b" || flag == "--branch":
+			showBranch = true
+		case flag == "-u" || flag == "--untracked-files":
+			untrackedMode = "all"
+		case strings.HasPrefix(flag, "-u") || strings.HasPrefix(flag, "--untracked-files="):
+			untrackedMode = strings.TrimPrefix(strings.TrimPrefix(flag, "--untracked-files="), "-u")
+			if untrackedMode != "no" && untrackedMode != "normal" && untrackedMode != "all" {
+				fmt.Printf("fatal: Invalid untracked files mode '%s'\n", untrackedMode)
+				return
+			}
+		default:
+			fmt.Println("Unsupported flag...")
+			printStatusUsage()
+			return
+		}
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	status, err := collectStatus(repository, untrackedMode)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch format {
+	case longStatus:
+		err = printLongStatus(repository, status, untrackedMode)
+	case shortStatus, porcelainV1Status:
+		err = printShortStatus(repository, status, showBranch, format == porcelainV1Status)
+	case porcelainV2Status:
+		err = printPorcelainV2Status(repository, status, showBranch)
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// Split bundled single letter flags like -sb into -s -b when every letter is one of the given ones
+func expandShortFlags(flags []string, letters string) []string {
+	var expanded []string
+	for _, flag := range flags {
+		bundle := strings.TrimPrefix(flag, "-")
+		if len(bundle) < 2 || strings.HasPrefix(bundle, "-") || strings.Trim(bundle, letters) != "" {
+			expanded = append(expanded, flag)
+			continue
+		}
+		for _, letter := range bundle {
+			expanded = append(expanded, "-"+string(letter))
+		}
+	}
+	return expanded
+}
+
+func collectStatus(repository *common.Repository, untrackedMode string) (*repositoryStatus, error) {
+	branch, err := readStatusBranch(repository)
+	if err != nil {
+		return nil, err
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return nil, err
+	}
+	headEntries := map[string]*common.IndexEntry{}
+	if !branch.head.Empty() {
+		headEntries, err = readTreeEntries(repository, branch.head.String(), "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	status := &repositoryStatus{branch: branch}
+	for i := 0; i < len(index.Entries); {
+		// Every stage of a path sits next to the others in the index
+		end := i + 1
+		for end < len(index.Entries) && index.Entries[end].EntryPath == index.Entries[i].EntryPath {
+			end++
+		}
+		entry, err := compareIndexPath(repository, index, headEntries, index.Entries[i:end])
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			status.entries = append(status.entries, entry)
+		}
+		i = end
+	}
+
+	// Anything left in HEAD was removed from the index
+	for entryPath, headEntry := range headEntries {
+		if index.FindEntry(entryPath) == nil {
+			status.entries = append(status.entries, &statusEntry{
+				path:      entryPath,
+				staged:    'D',
+				unstaged:  ' ',
+				headEntry: headEntry,
+			})
+		}
+	}
+	slices.SortFunc(status.entries, func(a, b *statusEntry) int {
+		return strings.Compare(a.path, b.path)
+	})
+
+	if untrackedMode != "no" {
+		status.untracked, err = findUntrackedFiles(repository, index, untrackedMode == "all")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Compare one path across HEAD, the index and the working tree - returns nil when all three agree
+func compareIndexPath(repository *common.Repository, index *common.Index, headEntries map[string]*common.IndexEntry, stages []*common.IndexEntry) (*statusEntry, error) {
+	indexEntry := stages[0]
+	entry := &statusEntry{
+		path:      indexEntry.EntryPath,
+		headEntry: headEntries[indexEntry.EntryPath],
+	}
+
+	if indexEntry.Stage() != 0 {
+		for _, stage := range stages {
+			entry.stages[stage.Stage()-1] = stage
+		}
+		entry.staged, entry.unstaged = unmergedCode(entry.stages)
+		mode, err := worktreeFileMode(repository, entry.path)
+		if err != nil {
+			return nil, err
+		}
+		entry.worktreeMode = mode
+		return entry, nil
+	}
+
+	entry.indexEntry = indexEntry
+	entry.worktreeMode = indexEntry.FileMode
+	switch {
+	case indexEntry.IntentToAdd():
+		// Nothing is staged yet so the whole file only counts as a working tree change
+		entry.staged = ' '
+	case entry.headEntry == nil:
+		entry.staged = 'A'
+	case !sameEntry(entry.headEntry, indexEntry):
+		entry.staged = 'M'
+	default:
+		entry.staged = ' '
+	}
+
+	unstaged, mode, err := worktreeChange(repository, index, indexEntry)
+	if err != nil {
+		return nil, err
+	}
+	entry.unstaged = unstaged
+	entry.worktreeMode = mode
+	if entry.staged == ' ' && entry.unstaged == ' ' {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// Returns the unstaged letter for an index entry along with the working tree file's mode
+// Skip worktree and assume unchanged entries are never looked at on disk
+func worktreeChange(repository *common.Repository, index *common.Index, entry *common.IndexEntry) (byte, uint32, error) {
+	if entry.IntentToAdd() {
+		mode, err := worktreeFileMode(repository, entry.EntryPath)
+		if err != nil {
+			return 0, 0, err
+		}
+		if mode == 0 {
+			return 'D', 0, nil
+		}
+		return 'A', mode, nil
+	}
+	if entry.SkipWorktree() || entry.AssumeUnchanged() {
+		return ' ', entry.FileMode, nil
+	}
+
+	mode, err := worktreeFileMode(repository, entry.EntryPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if mode == 0 {
+		return 'D', 0, nil
+	}
+	matches, err := worktreeMatchesEntry(repository, index, entry)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !matches {
+		return 'M', mode, nil
+	}
+	return ' ', mode, nil
+}
+
+// Zero when there's no file at the path - a directory where a file used to be counts as a deletion
+func worktreeFileMode(repository *common.Repository, entryPath string) (uint32, error) {
+	fileInfo, err := os.Lstat(filepath.Join(repository.WorkTree, entryPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if fileInfo.IsDir() {
+		return 0, nil
+	}
+	if mode := common.IndexFileMode(fileInfo.Mode()); mode != 0 {
+		return mode, nil
+	}
+	// Symlinks and other oddities still exist even if we can't stage them
+	return 0120000, nil
+}
+
+// Two letter conflict code based on which of the base, ours and theirs stages exist
+func unmergedCode(stages [3]*common.IndexEntry) (byte, byte) {
+	base, ours, theirs := stages[0] != nil, stages[1] != nil, stages[2] != nil
+	switch {
+	case base && ours && theirs:
+		return 'U', 'U'
+	case ours && theirs:
+		return 'A', 'A'
+	case base && ours:
+		return 'U', 'D'
+	case base && theirs:
+		return 'D', 'U'
+	case ours:
+		return 'A', 'U'
+	case theirs:
+		return 'U', 'A'
+	}
+	return 'D', 'D'
+}
+
+// Walk the working tree for files the index doesn't know about
+// Directories without a single tracked file are reported once as "dir/" unless every file was asked for
+func findUntrackedFiles(repository *common.Repository, index *common.Index, showAll bool) ([]string, error) {
+	trackedDirectories := make(map[string]bool)
+	for _, entry := range index.Entries {
+		for directory := filepath.Dir(entry.EntryPath); directory != "."; directory = filepath.Dir(directory) {
+			trackedDirectories[filepath.ToSlash(directory)] = true
+		}
+	}
+
+	var untracked []string
+	err := filepath.WalkDir(repository.WorkTree, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == repository.WorkTree {
+			return nil
+		}
+		relativePath, err := filepath.Rel(repository.WorkTree, path)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if dirEntry.Name() == ".gitgood" || dirEntry.Name() == ".git" {
+			if dirEntry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !dirEntry.IsDir() {
+			if index.FindEntry(relativePath) == nil {
+				untracked = append(untracked, relativePath)
+			}
+			return nil
+		}
+		if showAll || trackedDirectories[relativePath] {
+			return nil
+		}
+		hasFiles, err := containsFiles(path)
+		if err != nil {
+			return err
+		}
+		if hasFiles {
+			untracked = append(untracked, relativePath+"/")
+		}
+		return fs.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(untracked)
+	return untracked, nil
+}
+
+// Empty directories (or ones holding only empty directories) aren't worth mentioning
+func containsFiles(directory string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(directory, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !dirEntry.IsDir() {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+func readStatusBranch(repository *common.Repository) (*statusBranch, error) {
+	name, err := repository.GetBranch()
+	if err != nil {
+		return nil, err
+	}
+	head, err := repository.ResolveHead()
+	if err != nil {
+		return nil, err
+	}
+	branch := &statusBranch{name: name, head: head}
+	if name == "" || head.Empty() {
+		return branch, nil
+	}
+
+	branch.upstream, err = repository.BranchUpstream(name)
+	if err != nil || branch.upstream == "" {
+		return branch, err
+	}
+	upstreamRef, err := repository.ReadRef(branch.upstream)
+	if err != nil {
+		return nil, err
+	}
+	if upstreamRef.Hash.Empty() {
+		branch.upstreamGone = true
+		return branch, nil
+	}
+	branch.ahead, branch.behind, err = countAheadBehind(repository, head, upstreamRef.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+// Commits only reachable from local and commits only reachable from upstream
+func countAheadBehind(repository *common.Repository, local, upstream common.Hash) (int, int, error) {
+	localCommits, err := objects.ReachableCommits(repository, []common.Hash{local})
+	if err != nil {
+		return 0, 0, err
+	}
+	upstreamCommits, err := objects.ReachableCommits(repository, []common.Hash{upstream})
+	if err != nil {
+		return 0, 0, err
+	}
+	ahead := 0
+	for hash := range localCommits {
+		if !upstreamCommits[hash] {
+			ahead++
+		}
+	}
+	behind := 0
+	for hash := range upstreamCommits {
+		if !localCommits[hash] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// Long and short output show paths relative to the current directory
+func displayPath(repository *common.Repository, entryPath string) (string, error) {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	relativePath, err := filepath.Rel(workingDirectory, filepath.Join(repository.WorkTree, entryPath))
+	if err != nil {
+		return "", err
+	}
+	relativePath = filepath.ToSlash(relativePath)
+	if strings.HasSuffix(entryPath, "/") {
+		relativePath += "/"
+	}
+	return relativePath, nil
+}
+
+// Mirrors git's long format with advice.statusHints turned off since the hints point at commands we don't have
+func printLongStatus(repository *common.Repository, status *repositoryStatus, untrackedMode string) error {
+	branch := status.branch
+	if branch.name == "" {
+		fmt.Printf("HEAD detached at %s\n", branch.head.String()[:objects.DefaultShortHashLength])
+	} else {
+		fmt.Printf("On branch %s\n", branch.name)
+	}
+	if tracking := trackingMessage(branch); tracking != "" {
+		fmt.Printf("%s\n\n", tracking)
+	}
+	if branch.head.Empty() {
+		fmt.Printf("\nNo commits yet\n\n")
+	}
+
+	var staged, unstaged, unmerged []*statusEntry
+	for _, entry := range status.entries {
+		switch {
+		case entry.unmerged():
+			unmerged = append(unmerged, entry)
+			continue
+		case entry.staged != ' ':
+			staged = append(staged, entry)
+		}
+		if entry.unstaged != ' ' {
+			unstaged = append(unstaged, entry)
+		}
+	}
+	if len(unmerged) > 0 {
+		fmt.Printf("You have unmerged paths.\n\n")
+	}
+
+	sections := []struct {
+		title   string
+		entries []*statusEntry
+		label   func(*statusEntry) string
+	}{
+		{"Changes to be committed:", staged, func(entry *statusEntry) string { return changeLabel(entry.staged) }},
+		{"Unmerged paths:", unmerged, func(entry *statusEntry) string { return unmergedLabel(entry.staged, entry.unstaged) }},
+		{"Changes not staged for commit:", unstaged, func(entry *statusEntry) string { return changeLabel(entry.unstaged) }},
+	}
+	for _, section := range sections {
+		if len(section.entries) == 0 {
+			continue
+		}
+		fmt.Println(section.title)
+		for _, entry := range section.entries {
+			entryPath, err := displayPath(repository, entry.path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\t%s%s\n", section.label(entry), entryPath)
+		}
+		fmt.Println()
+	}
+	if len(status.untracked) > 0 {
+		fmt.Println("Untracked files:")
+		for _, untrackedPath := range status.untracked {
+			entryPath, err := displayPath(repository, untrackedPath)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\t%s\n", entryPath)
+		}
+		fmt.Println()
+	}
+
+	switch {
+	case len(staged) > 0 && untrackedMode == "no":
+		fmt.Println("Untracked files not listed")
+	case len(staged) > 0:
+	case len(unstaged) > 0 || len(unmerged) > 0:
+		fmt.Println("no changes added to commit")
+	case len(status.untracked) > 0:
+		fmt.Println("nothing added to commit but untracked files present")
+	case branch.head.Empty():
+		fmt.Println("nothing to commit")
+	case untrackedMode == "no":
+		fmt.Println("nothing to commit")
+	default:
+		fmt.Println("nothing to commit, working tree clean")
+	}
+	return nil
+}
+
+func trackingMessage(branch *statusBranch) string {
+	if branch.upstream == "" {
+		return ""
+	}
+	upstream := common.ShortRefName(branch.upstream)
+	switch {
+	case branch.upstreamGone:
+		return fmt.Sprintf("Your branch is based on '%s', but the upstream is gone.", upstream)
+	case branch.ahead > 0 && branch.behind > 0:
+		return fmt.Sprintf("Your branch and '%s' have diverged,\nand have %d and %d different commits each, respectively.", upstream, branch.ahead, branch.behind)
+	case branch.ahead > 0:
+		return fmt.Sprintf("Your branch is ahead of '%s' by %s.", upstream, pluralCommits(branch.ahead))
+	case branch.behind > 0:
+		return fmt.Sprintf("Your branch is behind '%s' by %s, and can be fast-forwarded.", upstream, pluralCommits(branch.behind))
+	}
+	return fmt.Sprintf("Your branch is up to date with '%s'.", upstream)
+}
+
+func pluralCommits(count int) string {
+	if count == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", count)
+}
+
+// Labels are padded so the paths line up within a section
+func changeLabel(code byte) string {
+	labels := map[byte]string{'A': "new file:", 'M': "modified:", 'D': "deleted:"}
+	return fmt.Sprintf("%-12s", labels[code])
+}
+
+func unmergedLabel(staged, unstaged byte) string {
+	labels := map[string]string{
+		"UU": "both modified:",
+		"AA": "both added:",
+		"DD": "both deleted:",
+		"AU": "added by us:",
+		"UA": "added by them:",
+		"UD": "deleted by them:",
+		"DU": "deleted by us:",
+	}
+	return fmt.Sprintf("%-17s", labels[string([]byte{staged, unstaged})])
+}
+
+// Short format is for people and shows paths relative to the current directory,
+// porcelain is the same layout for scripts with paths always relative to the top of the work tree
+func printShortStatus(repository *common.Repository, status *repositoryStatus, showBranch, porcelain bool) error {
+	if showBranch {
+		fmt.Println(shortBranchHeader(status.branch))
+	}
+	entryPath := func(path string) (string, error) {
+		if porcelain {
+			return path, nil
+		}
+		return displayPath(repository, path)
+	}
+	for _, entry := range status.entries {
+		path, err := entryPath(entry.path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%c%c %s\n", entry.staged, entry.unstaged, path)
+	}
+	for _, untrackedPath := range status.untracked {
+		path, err := entryPath(untrackedPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("?? %s\n", path)
+	}
+	return nil
+}
+
+func shortBranchHeader(branch *statusBranch) string {
+	switch {
+	case branch.name == "":
+		return "## HEAD (no branch)"
+	case branch.head.Empty():
+		return "## No commits yet on " + branch.name
+	case branch.upstream == "":
+		return "## " + branch.name
+	}
+	header := "## " + branch.name + "..." + common.ShortRefName(branch.upstream)
+	switch {
+	case branch.upstreamGone:
+		header += " [gone]"
+	case branch.ahead > 0 && branch.behind > 0:
+		header += fmt.Sprintf(" [ahead %d, behind %d]", branch.ahead, branch.behind)
+	case branch.ahead > 0:
+		header += fmt.Sprintf(" [ahead %d]", branch.ahead)
+	case branch.behind > 0:
+		header += fmt.Sprintf(" [behind %d]", branch.behind)
+	}
+	return header
+}
+
+// Version 2 spells out modes and hashes for HEAD, the index and the working tree
+// Unchanged halves of the code are "." instead of a space and unlike v1 paths follow the current directory
+func printPorcelainV2Status(repository *common.Repository, status *repositoryStatus, showBranch bool) error {
+	if showBranch {
+		branch := status.branch
+		if branch.head.Empty() {
+			fmt.Println("# branch.oid (initial)")
+		} else {
+			fmt.Printf("# branch.oid %s\n", branch.head)
+		}
+		if branch.name == "" {
+			fmt.Println("# branch.head (detached)")
+		} else {
+			fmt.Printf("# branch.head %s\n", branch.name)
+		}
+		if branch.upstream != "" {
+			fmt.Printf("# branch.upstream %s\n", common.ShortRefName(branch.upstream))
+			if !branch.upstreamGone {
+				fmt.Printf("# branch.ab +%d -%d\n", branch.ahead, branch.behind)
+			}
+		}
+	}
+
+	code := func(letter byte) byte {
+		if letter == ' ' {
+			return '.'
+		}
+		return letter
+	}
+	modeAndHash := func(entry *common.IndexEntry) (uint32, common.Hash) {
+		if entry == nil {
+			return 0, common.Hash{}
+		}
+		return entry.FileMode, entry.Hash
+	}
+	for _, entry := range status.entries {
+		path, err := displayPath(repository, entry.path)
+		if err != nil {
+			return err
+		}
+		if entry.unmerged() {
+			baseMode, baseHash := modeAndHash(entry.stages[0])
+			oursMode, oursHash := modeAndHash(entry.stages[1])
+			theirsMode, theirsHash := modeAndHash(entry.stages[2])
+			fmt.Printf("u %c%c N... %06o %06o %06o %06o %s %s %s %s\n", entry.staged, entry.unstaged,
+				baseMode, oursMode, theirsMode, entry.worktreeMode, baseHash, oursHash, theirsHash, path)
+			continue
+		}
+		headMode, headHash := modeAndHash(entry.headEntry)
+		indexMode, indexHash := modeAndHash(entry.indexEntry)
+		// Intent to add entries have nothing staged so their index side is blank too
+		if entry.indexEntry != nil && entry.indexEntry.IntentToAdd() {
+			indexMode, indexHash = 0, common.Hash{}
+		}
+		fmt.Printf("1 %c%c N... %06o %06o %06o %s %s %s\n", code(entry.staged), code(entry.unstaged),
+			headMode, indexMode, entry.worktreeMode, headHash, indexHash, path)
+	}
+	for _, untrackedPath := range status.untracked {
+		path, err := displayPath(repository, untrackedPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("? %s\n", path)
+	}
+	return nil
+}
+
+func printStatusUsage() {
+	fmt.Println("Usage: gitgood status [-s | --long | --porcelain[=v1|v2]] [-b] [-u<mode>]    Show staged, unstaged and untracked changes")
+	fmt.Println("       <mode> is one of no, normal (untracked directories are collapsed) or all")
+}
@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+func Switch(flags []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch {
+	case len(flags) >= 2 && len(flags) <= 3 && (flags[0] == "-c" || flags[0] == "--create"):
+		err = switchToNewBranch(repository, flags[1:])
+	case len(flags) == 2 && flags[0] == "--detach":
+		err = detachHead(repository, flags[1])
+	case len(flags) == 1 && flags[0][0] != '-':
+		if !repository.BranchExists(flags[0]) {
+			fmt.Printf("fatal: invalid reference: %s\n", flags[0])
+			return
+		}
+		err = switchBranch(repository, flags[0], false)
+	default:
+		printSwitchUsage()
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// Like switch but a commit that isn't a branch name detaches HEAD instead of being an error
+func Checkout(flags []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch {
+	case len(flags) >= 2 && len(flags) <= 3 && flags[0] == "-b":
+		err = switchToNewBranch(repository, flags[1:])
+	case len(flags) == 2 && flags[0] == "--detach":
+		err = detachHead(repository, flags[1])
+	case len(flags) == 1 && flags[0][0] != '-':
+		if repository.BranchExists(flags[0]) {
+			err = switchBranch(repository, flags[0], false)
+		} else {
+			err = detachHead(repository, flags[0])
+		}
+	default:
+		printCheckoutUsage()
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+// Create a branch at the start point (HEAD by default) and switch to it
+func switchToNewBranch(repository *common.Repository, arguments []string) error {
+	branch := arguments[0]
+	startPoint := "HEAD"
+	if len(arguments) == 2 {
+		startPoint = arguments[1]
+	}
+
+	// A new branch on an unborn HEAD just renames the branch HEAD points at
+	headHash, err := repository.ResolveHead()
+	if err != nil {
+		return err
+	}
+	if headHash.Empty() && len(arguments) == 1 {
+		if !common.IsValidBranchName(branch) {
+			return fmt.Errorf("fatal: '%s' is not a valid branch name", branch)
+		}
+		err = repository.SetHeadBranch(branch)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Switched to a new branch '%s'\n", branch)
+		return nil
+	}
+
+	err = createBranch(repository, branch, startPoint)
+	if err != nil {
+		return err
+	}
+	return switchBranch(repository, branch, true)
+}
+
+func switchBranch(repository *common.Repository, branch string, created bool) error {
+	currentBranch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	if branch == currentBranch && !created {
+		fmt.Printf("Already on '%s'\n", branch)
+		return nil
+	}
+
+	ref, err := repository.FindRef(branch)
+	if err != nil {
+		return err
+	}
+	previousHead, err := repository.ResolveHead()
+	if err != nil {
+		return err
+	}
+	err = updateWorkTreeTo(repository, ref.Hash)
+	if err != nil {
+		return err
+	}
+	err = repository.SetHeadBranch(branch)
+	if err != nil {
+		return err
+	}
+	err = logCheckout(repository, currentBranch, previousHead, ref.Hash, branch)
+	if err != nil {
+		return err
+	}
+
+	// Leaving a detached HEAD might strand the commits made on it
+	if currentBranch == "" {
+		err = warnLeftBehindCommits(repository, previousHead, ref.Hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	if created {
+		fmt.Printf("Switched to a new branch '%s'\n", branch)
+	} else {
+		fmt.Printf("Switched to branch '%s'\n", branch)
+	}
+	return nil
+}
+
+func detachHead(repository *common.Repository, commitish string) error {
+	hash, err := resolveCommit(repository, commitish)
+	if err != nil {
+		return err
+	}
+	currentBranch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	previousHead, err := repository.ResolveHead()
+	if err != nil {
+		return err
+	}
+	err = updateWorkTreeTo(repository, hash)
+	if err != nil {
+		return err
+	}
+	err = repository.SetHeadDetached(hash)
+	if err != nil {
+		return err
+	}
+	err = logCheckout(repository, currentBranch, previousHead, hash, commitish)
+	if err != nil {
+		return err
+	}
+
+	if currentBranch == "" && previousHead != hash {
+		previousCommit, err := objects.ReadCommit(repository, previousHead)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Previous HEAD position was %s %s\n", previousHead.String()[:7], previousCommit.Subject())
+		err = warnLeftBehindCommits(repository, previousHead, hash)
+		if err != nil {
+			return err
+		}
+	} else if currentBranch != "" {
+		fmt.Printf("Note: switching to '%s'.\n\n", commitish)
+		fmt.Println("You are in 'detached HEAD' state. You can look around, make experimental")
+		fmt.Println("changes and commit them, and you can discard any commits you make in this")
+		fmt.Println("state without impacting any branches by switching back to a branch.")
+		fmt.Println()
+		fmt.Println("If you want to create a new branch to retain commits you create, you may")
+		fmt.Println("do so (now or later) by using -c with the switch command. Example:")
+		fmt.Println()
+		fmt.Println("  gitgood switch -c <new-branch-name>")
+		fmt.Println()
+	}
+
+	commit, err := objects.ReadCommit(repository, hash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("HEAD is now at %s %s\n", hash.String()[:7], commit.Subject())
+	return nil
+}
+
+// Record the move in HEAD's reflog - a detached HEAD is named by its commit
+func logCheckout(repository *common.Repository, previousBranch string, previousHead, newHead common.Hash, target string) error {
+	from := previousBranch
+	if from == "" {
+		from = previousHead.String()
+	}
+	return repository.AppendReflog("HEAD", previousHead, newHead, fmt.Sprintf("checkout: moving from %s to %s", from, target))
+}
+
+// Warn about commits that were only reachable from the previous detached HEAD
+// since nothing will point at them once HEAD moves on
+func warnLeftBehindCommits(repository *common.Repository, previousHead, newHead common.Hash) error {
+	branches, err := repository.ListBranches()
+	if err != nil {
+		return err
+	}
+	tips := []common.Hash{newHead}
+	for _, branch := range branches {
+		tips = append(tips, branch.Hash)
+	}
+	reachable, err := objects.ReachableCommits(repository, tips)
+	if err != nil {
+		return err
+	}
+
+	var leftBehind []common.Hash
+	current := previousHead
+	for !current.Empty() && !reachable[current] {
+		leftBehind = append(leftBehind, current)
+		commit, err := objects.ReadCommit(repository, current)
+		if err != nil {
+			return err
+		}
+		if len(commit.Parents) == 0 {
+			break
+		}
+		current = commit.Parents[0]
+	}
+	if len(leftBehind) == 0 {
+		return nil
+	}
+
+	commitWord := "commit"
+	if len(leftBehind) > 1 {
+		commitWord = "commits"
+	}
+	fmt.Printf("Warning: you are leaving %d %s behind, not connected to\nany of your branches:\n\n", len(leftBehind), commitWord)
+	for _, hash := range leftBehind {
+		commit, err := objects.ReadCommit(repository, hash)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %s %s\n", hash.String()[:7], commit.Subject())
+	}
+	fmt.Println()
+	fmt.Println("If you want to keep them by creating a new branch, this may be a good time")
+	fmt.Println("to do so with:")
+	fmt.Println()
+	fmt.Printf(" gitgood branch <new-branch-name> %s\n\n", previousHead.String()[:7])
+	return nil
+}
+
+// Move the index and working tree from the current HEAD commit to the target commit
+// Local changes to paths the target doesn't touch are carried over, anything that would be
+// overwritten stops the switch before a single file is written
+func updateWorkTreeTo(repository *common.Repository, target common.Hash) error {
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return err
+	}
+	if index.HasConflicts() {
+		return fmt.Errorf("error: you need to resolve your current index first")
+	}
+
+	headHash, err := repository.ResolveHead()
+	if err != nil {
+		return err
+	}
+	headEntries := map[string]*common.IndexEntry{}
+	if !headHash.Empty() {
+		headEntries, err = readTreeEntries(repository, headHash.String(), "")
+		if err != nil {
+			return err
+		}
+	}
+	targetEntries, err := readTreeEntries(repository, target.String(), "")
+	if err != nil {
+		return err
+	}
+
+	mergedEntries, err := twoWayMerge(repository, index, headEntries, targetEntries)
+	if err != nil {
+		return fmt.Errorf("error: %v\nPlease commit your changes before you switch branches.\nAborting", err)
+	}
+
+	mergedPaths := make(map[string]bool)
+	for _, entry := range mergedEntries {
+		mergedPaths[entry.EntryPath] = true
+	}
+
+	// Files git doesn't know about yet would be silently replaced by files from the target
+	var overwrittenUntracked []string
+	for _, entry := range mergedEntries {
+		if index.FindEntry(entry.EntryPath) != nil || headEntries[entry.EntryPath] != nil {
+			continue
+		}
+		overwritten, err := untrackedFileDiffers(repository, index, mergedPaths, entry)
+		if err != nil {
+			return err
+		}
+		if overwritten {
+			overwrittenUntracked = append(overwrittenUntracked, entry.EntryPath)
+		}
+	}
+	if len(overwrittenUntracked) > 0 {
+		message := "error: The following untracked working tree files would be overwritten by checkout:\n"
+		for _, entryPath := range overwrittenUntracked {
+			message += "\t" + entryPath + "\n"
+		}
+		return fmt.Errorf("%sPlease move or remove them before you switch branches.\nAborting", message)
+	}
+
+	sparse, err := repository.ActiveSparseCheckout()
+	if err != nil {
+		return err
+	}
+
+	// Entries carried over from the index already match the working tree - everything else came
+	// from the target and has to be written unless the sparse patterns leave it out
+	keptEntries := make(map[*common.IndexEntry]bool)
+	for _, entry := range index.Entries {
+		if !mergedPaths[entry.EntryPath] {
+			if !entry.SkipWorktree() {
+				err = removeFromWorkTree(repository, entry.EntryPath)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		keptEntries[entry] = true
+	}
+	for _, entry := range mergedEntries {
+		if keptEntries[entry] {
+			continue
+		}
+		if sparse != nil && !sparse.Includes(entry.EntryPath) {
+			entry.SetSkipWorktree(true)
+			continue
+		}
+		err = checkoutEntry(repository, entry, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	index.Entries = nil
+	index.AddEntries(mergedEntries)
+	return common.WriteIndex(repository, index)
+}
+
+// Reports whether a file the index doesn't track exists with content other than the entry's
+// A file where one of the entry's directories goes, or a directory where the entry goes, only
+// counts when it holds something other than clean tracked files the switch removes anyway
+func untrackedFileDiffers(repository *common.Repository, index *common.Index, mergedPaths map[string]bool, entry *common.IndexEntry) (bool, error) {
+	filePath := filepath.Join(repository.WorkTree, entry.EntryPath)
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if !errors.Is(err, syscall.ENOTDIR) {
+			return false, err
+		}
+		// Find the file sitting where one of the entry's parent directories needs to go
+		parent := entry.EntryPath
+		for {
+			parent = path.Dir(parent)
+			parentInfo, err := os.Lstat(filepath.Join(repository.WorkTree, parent))
+			if err == nil && !parentInfo.IsDir() {
+				removed, err := removedBySwitch(repository, index, mergedPaths, parent)
+				return !removed, err
+			}
+			if parent == "." {
+				return true, nil
+			}
+		}
+	}
+
+	if fileInfo.IsDir() {
+		blocked := false
+		err = filepath.WalkDir(filePath, func(walkPath string, dirEntry fs.DirEntry, err error) error {
+			if err != nil || blocked || dirEntry.IsDir() {
+				return err
+			}
+			relativePath, err := filepath.Rel(repository.WorkTree, walkPath)
+			if err != nil {
+				return err
+			}
+			removed, err := removedBySwitch(repository, index, mergedPaths, filepath.ToSlash(relativePath))
+			blocked = !removed
+			return err
+		})
+		return blocked, err
+	}
+	blob, err := objects.CreateBlobFromFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	return blob.Hash != entry.Hash, nil
+}
+
+// Tracked files the target doesn't have are deleted before anything is written, as long as they're clean
+func removedBySwitch(repository *common.Repository, index *common.Index, mergedPaths map[string]bool, entryPath string) (bool, error) {
+	entry := index.FindEntry(entryPath)
+	if entry == nil || mergedPaths[entryPath] || entry.SkipWorktree() {
+		return false, nil
+	}
+	return worktreeIsUpToDate(repository, index, entry)
+}
+
+func printSwitchUsage() {
+	fmt.Println("Usage: gitgood switch <branch>                          Switch to a branch")
+	fmt.Println("Usage: gitgood switch -c <new-branch> [<start-point>]   Create a branch and switch to it")
+	fmt.Println("Usage: gitgood switch --detach <commit>                 Detach HEAD at a commit")
+}
+
+func printCheckoutUsage() {
+	fmt.Println("Usage: gitgood checkout <branch>                         Switch to a branch")
+	fmt.Println("Usage: gitgood checkout <commit>                         Detach HEAD at a commit")
+	fmt.Println("Usage: gitgood checkout -b <new-branch> [<start-point>]  Create a branch and switch to it")
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// Branch A tracks a file "a" and branch B a directory "a" holding "a/b", with B checked out
+func createFileDirectoryBranches(t *testing.T) *common.Repository {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Init(nil)
+	writeTestFile(t, "a", "file\n")
+	Add([]string{"a"})
+	Commit([]string{"-m", "file"})
+	Branch([]string{"A"})
+	Switch([]string{"-c", "B"})
+
+	if err := os.Remove("a"); err != nil {
+		t.Fatalf("expected no error removing a, got %v", err)
+	}
+	UpdateIndex([]string{"-remove", "a"})
+	writeTestFile(t, "a/b", "nested\n")
+	Add([]string{"a/b"})
+	Commit([]string{"-m", "directory"})
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	return repository
+}
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatalf("expected no error creating directory for %s, got %v", name, err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatalf("expected no error writing %s, got %v", name, err)
+	}
+}
+
+func expectBranch(t *testing.T, repository *common.Repository, expected string) {
+	t.Helper()
+	branch, err := repository.GetBranch()
+	if err != nil || branch != expected {
+		t.Fatalf("expected to be on %s, got %q %v", expected, branch, err)
+	}
+}
+
+func TestSwitchBetweenFileAndDirectory(t *testing.T) {
+	repository := createFileDirectoryBranches(t)
+
+	// The clean directory goes and the file takes its place
+	Switch([]string{"A"})
+	expectBranch(t, repository, "A")
+	if data, err := os.ReadFile("a"); err != nil || string(data) != "file\n" {
+		t.Fatalf("expected a to be the file again, got %q %v", data, err)
+	}
+
+	// And the other way around
+	Switch([]string{"B"})
+	expectBranch(t, repository, "B")
+	if data, err := os.ReadFile("a/b"); err != nil || string(data) != "nested\n" {
+		t.Fatalf("expected a/b to be back, got %q %v", data, err)
+	}
+}
+
+func TestSwitchKeepsUntrackedFilesInTheWay(t *testing.T) {
+	repository := createFileDirectoryBranches(t)
+
+	// An untracked file in the directory would be lost
+	writeTestFile(t, "a/untracked", "keep me\n")
+	Switch([]string{"A"})
+	expectBranch(t, repository, "B")
+	if _, err := os.Stat("a/untracked"); err != nil {
+		t.Fatalf("expected the untracked file to be left alone, got %v", err)
+	}
+	if err := os.Remove("a/untracked"); err != nil {
+		t.Fatalf("expected no error removing a/untracked, got %v", err)
+	}
+
+	// So would an untracked file where the target needs a directory
+	Switch([]string{"A"})
+	expectBranch(t, repository, "A")
+	UpdateIndex([]string{"-remove", "a"})
+	Switch([]string{"B"})
+	expectBranch(t, repository, "A")
+	if data, err := os.ReadFile("a"); err != nil || string(data) != "file\n" {
+		t.Fatalf("expected the untracked file to be left alone, got %q %v", data, err)
+	}
+}
+
+func TestSwitchKeepsEditsBehindAssumeUnchangedAndSkipWorktree(t *testing.T) {
+	for _, flag := range []string{"--assume-unchanged", "--skip-worktree"} {
+		t.Run(flag, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			t.Setenv("HOME", t.TempDir())
+			Init(nil)
+			writeTestFile(t, "config.txt", "main\n")
+			Add([]string{"config.txt"})
+			Commit([]string{"-m", "main"})
+			Switch([]string{"-c", "other"})
+			writeTestFile(t, "config.txt", "other\n")
+			Add([]string{"config.txt"})
+			Commit([]string{"-m", "other"})
+			Switch([]string{"main"})
+			repository, err := common.FindRepository(".")
+			if err != nil {
+				t.Fatalf("expected a repository, got %v", err)
+			}
+
+			writeTestFile(t, "config.txt", "local edit\n")
+			UpdateIndex([]string{flag, "config.txt"})
+			Switch([]string{"other"})
+			expectBranch(t, repository, "main")
+			if data, err := os.ReadFile("config.txt"); err != nil || string(data) != "local edit\n" {
+				t.Fatalf("expected the local edit to be kept, got %q %v", data, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func SymbolicRef(flags []string) {
+	quiet := false
+	short := false
+	recurse := true
+	deleteRef := false
+	message := ""
+	var arguments []string
+	for i := 0; i < len(flags); i++ {
+		switch flags[i] {
+		case "-q", "--quiet":
+			quiet = true
+		case "--short":
+			short = true
+		case "--no-recurse":
+			recurse = false
+		case "--recurse":
+			recurse = true
+		case "-d", "--delete":
+			deleteRef = true
+		case "-m":
+			if i+1 >= len(flags) {
+				printSymbolicRefUsage()
+				return
+			}
+			message = flags[i+1]
+			i++
+		default:
+			if strings.HasPrefix(flags[i], "-") {
+				fmt.Println("Unsupported flag...")
+				printSymbolicRefUsage()
+				return
+			}
+			arguments = append(arguments, flags[i])
+		}
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch {
+	case deleteRef && len(arguments) == 1:
+		err = deleteSymbolicRef(repository, arguments[0], quiet)
+	case !deleteRef && len(arguments) == 1:
+		err = showSymbolicRef(repository, arguments[0], quiet, short, recurse)
+	case !deleteRef && len(arguments) == 2:
+		err = setSymbolicRef(repository, arguments[0], arguments[1], message)
+	default:
+		printSymbolicRefUsage()
+		return
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+func showSymbolicRef(repository *common.Repository, name string, quiet, short, recurse bool) error {
+	target, err := repository.ReadSymbolicRef(name)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		// Quiet mode is for scripts checking whether HEAD is detached
+		if quiet {
+			return nil
+		}
+		return fmt.Errorf("fatal: ref %s is not a symbolic ref", name)
+	}
+	if recurse {
+		target, err = repository.ResolveRefName(target)
+		if err != nil {
+			return err
+		}
+	}
+	if short {
+		target = common.ShortRefName(target)
+	}
+	fmt.Println(target)
+	return nil
+}
+
+// Point a symbolic ref at another ref - HEAD can only point at branches
+func setSymbolicRef(repository *common.Repository, name, target, message string) error {
+	if name != "HEAD" && !common.IsValidRefName(name) {
+		return fmt.Errorf("fatal: invalid symref name: %s", name)
+	}
+	if !common.IsValidRefName(target) {
+		return fmt.Errorf("fatal: refusing to point %s outside of refs/", name)
+	}
+	if name == "HEAD" && !strings.HasPrefix(target, "refs/heads/") {
+		return fmt.Errorf("fatal: refusing to point HEAD outside of refs/heads/")
+	}
+
+	previous, err := repository.ReadRef(name)
+	if err != nil {
+		return err
+	}
+	err = repository.WriteSymbolicRef(name, target)
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return nil
+	}
+	current, err := repository.ReadRef(name)
+	if err != nil {
+		return err
+	}
+	return repository.AppendReflog(name, previous.Hash, current.Hash, message)
+}
+
+func deleteSymbolicRef(repository *common.Repository, name string, quiet bool) error {
+	if name == "HEAD" {
+		return fmt.Errorf("fatal: deleting '%s' is not allowed", name)
+	}
+	err := repository.DeleteSymbolicRef(name)
+	if err != nil && quiet {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("fatal: %v", err)
+	}
+	return nil
+}
+
+func printSymbolicRefUsage() {
+	fmt.Println("Usage: gitgood symbolic-ref [-q] [--short] [--no-recurse] <name>    Show the ref a symbolic ref points at")
+	fmt.Println("Usage: gitgood symbolic-ref [-m <reason>] <name> <ref>              Point a symbolic ref at a ref")
+	fmt.Println("Usage: gitgood symbolic-ref -d [-q] <name>                          Delete a symbolic ref")
+}
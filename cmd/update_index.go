@@ -1,15 +1,50 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/CLBRITTON2/go-git-good/common"
 	"github.com/CLBRITTON2/go-git-good/objects"
 )
 
 func UpdateIndex(flags []string) {
+	if len(flags) == 1 && (flags[0] == "--refresh" || flags[0] == "--really-refresh") {
+		refreshIndex(flags[0] == "--really-refresh")
+		return
+	}
+	if len(flags) == 2 && flags[0] == "--index-version" {
+		setIndexVersion(flags[1])
+		return
+	}
+	if len(flags) >= 2 && isIndexBitFlag(flags[0]) {
+		markIndexEntries(flags[0], flags[1:])
+		return
+	}
+	if len(flags) >= 2 && flags[0] == "--cacheinfo" {
+		addCacheInfo(flags[1:])
+		return
+	}
+	if len(flags) == 1 && flags[0] == "--index-info" {
+		readIndexInfo(os.Stdin)
+		return
+	}
+	if len(flags) >= 2 && strings.HasPrefix(flags[0], "--chmod=") {
+		chmodEntries(strings.TrimPrefix(flags[0], "--chmod="), flags[1:])
+		return
+	}
+
+	// -add --chmod=+x <filename> stages the file and flips its mode in one go
+	chmod := ""
+	if len(flags) == 3 && flags[0] == "-add" && strings.HasPrefix(flags[1], "--chmod=") {
+		chmod = strings.TrimPrefix(flags[1], "--chmod=")
+		flags = []string{flags[0], flags[2]}
+	}
 	if len(flags) < 2 {
 		printUpdateIndexUsage()
 		return
@@ -27,8 +62,7 @@ func UpdateIndex(flags []string) {
 		return
 	}
 
-	// Find the repository, find the index, create the index entry path
-	// Which is the object's path relative to the work tree
+	// Find the repository and the index - entry paths are relative to the work tree
 	repository, err := common.FindRepository(".")
 	if err != nil {
 		fmt.Printf("%v\n", err)
@@ -39,15 +73,15 @@ func UpdateIndex(flags []string) {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	indexEntryRelativePath, err := filepath.Rel(repository.WorkTree, absolutePath)
-	if err != nil {
-		fmt.Printf("%v\n", err)
-		return
-	}
 
-	// We have the entry's path, if the -remove flag was passed we're safe to skip
-	// metadata gathering for writing files to the index and just remove the entry
+	// If the -remove flag was passed we're safe to skip metadata gathering
+	// for writing files to the index and just remove the entry
 	if flags[0] == "-remove" {
+		indexEntryRelativePath, err := filepath.Rel(repository.WorkTree, absolutePath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
 		currentIndex.RemoveEntry(indexEntryRelativePath)
 		err = common.WriteIndex(repository, currentIndex)
 		if err != nil {
@@ -57,63 +91,420 @@ func UpdateIndex(flags []string) {
 		return
 	}
 
-	// Create the blob and write it to the DB (Git does this by default)
-	blob, err := objects.CreateBlobFromFile(file)
+	indexEntry, err := createIndexEntry(repository, currentIndex, absolutePath)
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
-	serializedBlobData := blob.Serialize()
-	err = repository.WriteObject(blob.Hash.String(), serializedBlobData)
+	// Nil entry means the file hasn't changed since it was staged
+	if indexEntry == nil && chmod == "" {
+		return
+	}
+	if indexEntry != nil {
+		if conflict := currentIndex.DirectoryConflict(indexEntry); conflict != "" {
+			fmt.Printf("error: '%s' appears as both a file and as a directory\n", indexEntry.EntryPath)
+			return
+		}
+		currentIndex.AddEntry(indexEntry)
+	}
+	if chmod != "" {
+		err = setExecutableBit(repository, currentIndex, file, chmod)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+
+	err = common.WriteIndex(repository, currentIndex)
 	if err != nil {
 		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+// Hash the file into the object DB and build its index entry without touching the index itself
+// so callers staging many files can read and write the index once
+// Returns a nil entry if the file's stat data shows it hasn't changed since it was staged
+func createIndexEntry(repository *common.Repository, index *common.Index, absolutePath string) (*common.IndexEntry, error) {
+	indexEntryRelativePath, err := filepath.Rel(repository.WorkTree, absolutePath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Start getting metadata for the index file
 	fileInfo, err := os.Stat(absolutePath)
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		return
+		return nil, err
 	}
-	modifiedTime := fileInfo.ModTime()
-	fileSize := uint32(fileInfo.Size())
-	mode := fileInfo.Mode()
-	var fileModeInt uint32
-	// Keeping it simple for now - normal files and executable are the only accepted modes
-	if mode.IsRegular() {
-		if mode&0111 != 0 {
-			// Executable
-			fileModeInt = 0100755
-		} else {
-			// Regular file
-			fileModeInt = 0100644
-		}
+
+	// Skip re-hashing files whose stat data shows they haven't changed since they were staged
+	existingEntry := index.FindEntry(indexEntryRelativePath)
+	if existingEntry != nil && existingEntry.Stage() == 0 && index.IsUpToDate(existingEntry, fileInfo) {
+		return nil, nil
 	}
 
 	// Just file permissions 644/755
+	fileModeInt := common.IndexFileMode(fileInfo.Mode())
 	if fileModeInt != 0100644 && fileModeInt != 0100755 {
-		fmt.Println("Unsupported file mode discovered at update-index command")
-		return
+		return nil, fmt.Errorf("unsupported file mode discovered staging %s", indexEntryRelativePath)
+	}
+
+	// Create the blob and write it to the DB (Git does this by default)
+	blob, err := objects.CreateBlobFromFile(absolutePath)
+	if err != nil {
+		return nil, err
+	}
+	serializedBlobData := blob.Serialize()
+	err = repository.WriteObject(blob.Hash.String(), serializedBlobData)
+	if err != nil {
+		return nil, err
 	}
 
-	indexEntry := &common.IndexEntry{
-		ModifiedTime: modifiedTime,
+	return &common.IndexEntry{
+		ModifiedTime: fileInfo.ModTime(),
 		Hash:         blob.Hash,
-		FileSize:     fileSize,
+		FileSize:     uint32(fileInfo.Size()),
 		FileMode:     fileModeInt,
 		EntryPath:    indexEntryRelativePath,
+	}, nil
+}
+
+// Re-examine every index entry against the working tree and update the stat data of files whose
+// content is unchanged without rewriting their blobs - really refresh ignores the cached stat data
+func refreshIndex(reallyRefresh bool) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
 	}
 
-	currentIndex.AddEntry(indexEntry)
+	for _, entry := range index.Entries {
+		// Skip worktree entries aren't expected to exist on disk and assume unchanged entries are trusted
+		// unless the caller explicitly asked to look past them
+		if entry.SkipWorktree() || (!reallyRefresh && entry.AssumeUnchanged()) {
+			continue
+		}
+		fileInfo, err := os.Stat(filepath.Join(repository.WorkTree, entry.EntryPath))
+		if err != nil {
+			fmt.Printf("%s: needs update\n", entry.EntryPath)
+			continue
+		}
+		if !reallyRefresh && index.IsUpToDate(entry, fileInfo) {
+			continue
+		}
 
-	err = common.WriteIndex(repository, currentIndex)
+		blob, err := objects.CreateBlobFromFile(filepath.Join(repository.WorkTree, entry.EntryPath))
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+		if blob.Hash != entry.Hash || common.IndexFileMode(fileInfo.Mode()) != entry.FileMode {
+			fmt.Printf("%s: needs update\n", entry.EntryPath)
+			continue
+		}
+		entry.ModifiedTime = fileInfo.ModTime()
+		entry.FileSize = uint32(fileInfo.Size())
+	}
+
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+func setIndexVersion(versionString string) {
+	version, err := strconv.ParseUint(versionString, 10, 32)
+	if err != nil || version < 2 || version > 4 {
+		fmt.Printf("index-version %v not in range: 2..4\n", versionString)
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return
 	}
+	index.Version = uint32(version)
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+func isIndexBitFlag(flag string) bool {
+	switch flag {
+	case "--assume-unchanged", "--no-assume-unchanged", "--skip-worktree", "--no-skip-worktree":
+		return true
+	}
+	return false
+}
+
+// Set or clear the assume unchanged/skip worktree bit on each of the given paths
+func markIndexEntries(flag string, files []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	for _, file := range files {
+		absolutePath, err := filepath.Abs(file)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		indexEntryRelativePath, err := filepath.Rel(repository.WorkTree, absolutePath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		entry := index.FindEntry(indexEntryRelativePath)
+		if entry == nil {
+			fmt.Printf("Unable to mark file %s\n", file)
+			return
+		}
+
+		switch flag {
+		case "--assume-unchanged":
+			entry.SetAssumeUnchanged(true)
+		case "--no-assume-unchanged":
+			entry.SetAssumeUnchanged(false)
+		case "--skip-worktree":
+			entry.SetSkipWorktree(true)
+		case "--no-skip-worktree":
+			entry.SetSkipWorktree(false)
+		}
+	}
+
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+// Stage an object that's already in the DB without needing a file in the working tree
+// Accepts both <mode>,<hash>,<path> and the older three argument form
+func addCacheInfo(arguments []string) {
+	if len(arguments) == 1 {
+		arguments = strings.SplitN(arguments[0], ",", 3)
+	}
+	if len(arguments) != 3 {
+		printUpdateIndexUsage()
+		return
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	entry, err := parseIndexInfoEntry(arguments[0], arguments[1], arguments[2])
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	// Gitlinks point at a commit in another repository so there's nothing here to check
+	if entry.FileMode != 0160000 {
+		objectType, _, err := objects.ReadObject(repository, entry.Hash)
+		if err != nil || objectType != "blob" {
+			fmt.Printf("error: invalid object %s for '%s'\n", arguments[1], arguments[2])
+			return
+		}
+	}
+	if conflict := index.DirectoryConflict(entry); conflict != "" {
+		fmt.Printf("error: '%s' appears as both a file and as a directory\n", entry.EntryPath)
+		return
+	}
+
+	index.AddEntry(entry)
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+// Bulk load entries - each line is one of the formats printed by ls-tree, ls-files -s or plain
+// <mode> <hash>\t<path> and a mode of 0 removes the path from the index
+func readIndexInfo(input io.Reader) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields, entryPath, found := strings.Cut(line, "\t")
+		parts := strings.Fields(fields)
+		if !found || len(parts) < 2 || len(parts) > 3 {
+			fmt.Printf("malformed index info %s\n", line)
+			return
+		}
+
+		// <mode> <type> <hash> from ls-tree, <mode> <hash> <stage> from ls-files -s
+		mode, hash, stage := parts[0], parts[1], "0"
+		if len(parts) == 3 {
+			if len(parts[1]) == 40 {
+				stage = parts[2]
+			} else {
+				hash = parts[2]
+			}
+		}
+
+		if mode == "0" {
+			index.RemoveEntry(entryPath)
+			continue
+		}
+		entry, err := parseIndexInfoEntry(mode, hash, entryPath)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		stageNumber, err := strconv.Atoi(stage)
+		if err != nil || stageNumber < 0 || stageNumber > 3 {
+			fmt.Printf("malformed index info %s\n", line)
+			return
+		}
+		entry.SetStage(stageNumber)
+		if conflict := index.DirectoryConflict(entry); conflict != "" {
+			fmt.Printf("error: '%s' appears as both a file and as a directory\n", entry.EntryPath)
+			return
+		}
+		index.AddEntry(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+func parseIndexInfoEntry(mode, hashString, entryPath string) (*common.IndexEntry, error) {
+	// checkout-index would write whatever is staged so nothing outside the work tree or in .gitgood gets in
+	if !common.VerifyPath(entryPath) {
+		return nil, fmt.Errorf("error: Invalid path '%s'", entryPath)
+	}
+	fileMode, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported file mode %s for '%s'", mode, entryPath)
+	}
+	// Regular files only keep the executable bit, symlinks and gitlinks are taken as they are
+	// Ref https://github.com/git/git/blob/master/cache.h (canon_mode)
+	switch {
+	case fileMode&0170000 == 0100000 && fileMode&0100 != 0:
+		fileMode = 0100755
+	case fileMode&0170000 == 0100000:
+		fileMode = 0100644
+	case fileMode == 0120000 || fileMode == 0160000:
+	default:
+		return nil, fmt.Errorf("unsupported file mode %s for '%s'", mode, entryPath)
+	}
+	hash, err := common.ParseHash(hashString)
+	if err != nil {
+		return nil, err
+	}
+	return &common.IndexEntry{
+		Hash:      hash,
+		FileMode:  uint32(fileMode),
+		EntryPath: entryPath,
+	}, nil
+}
+
+func chmodEntries(chmod string, files []string) {
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, file := range files {
+		err = setExecutableBit(repository, index, file, chmod)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+	err = common.WriteIndex(repository, index)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+}
+
+// Flip the staged mode between regular and executable without touching the file on disk
+func setExecutableBit(repository *common.Repository, index *common.Index, file string, chmod string) error {
+	if chmod != "+x" && chmod != "-x" {
+		return fmt.Errorf("option 'chmod' expects \"+x\" or \"-x\"")
+	}
+	indexEntryRelativePath, err := indexPath(repository, file)
+	if err != nil {
+		return err
+	}
+	entry := index.FindEntry(indexEntryRelativePath)
+	// Symlinks and gitlinks have no executable bit to flip
+	if entry == nil || entry.Stage() != 0 || entry.FileMode&0170000 != 0100000 {
+		return fmt.Errorf("cannot chmod %s '%s'", chmod, file)
+	}
+	if chmod == "+x" {
+		entry.FileMode = 0100755
+	} else {
+		entry.FileMode = 0100644
+	}
+	return nil
 }
 
 func printUpdateIndexUsage() {
 	fmt.Println("Usage: gitgood update-index -add <filename>         Add a file to the staging area (index)")
 	fmt.Println("Usage: gitgood update-index -remove <filename>      Remove a file from the staging area (index)")
+	fmt.Println("Usage: gitgood update-index --refresh               Update stat data of unchanged files in the index")
+	fmt.Println("Usage: gitgood update-index --really-refresh        Like --refresh but ignore cached stat data and re-check every file")
+	fmt.Println("Usage: gitgood update-index --cacheinfo <mode>,<hash>,<path>          Stage an object already in the DB")
+	fmt.Println("Usage: gitgood update-index [-add] --chmod=(+|-)x <filename>...    Set or clear the executable bit of staged files")
+	fmt.Println("Usage: gitgood update-index --index-info                            Read index entries from stdin")
+	fmt.Println("Usage: gitgood update-index --[no-]assume-unchanged <filename>...  Set or clear the assume unchanged bit")
+	fmt.Println("Usage: gitgood update-index --[no-]skip-worktree <filename>...     Set or clear the skip worktree bit")
+	fmt.Println("Usage: gitgood update-index --index-version <n>     Rewrite the index in format version 2, 3 or 4")
 }
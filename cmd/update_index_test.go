@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+const emptyBlobHash = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+func createUpdateIndexRepository(t *testing.T) *common.Repository {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Init(nil)
+	writeTestFile(t, "empty", "")
+	HashObject([]string{"-w", "empty"})
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	return repository
+}
+
+func stagedPaths(t *testing.T, repository *common.Repository) []string {
+	t.Helper()
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		t.Fatalf("expected no error reading the index, got %v", err)
+	}
+	var paths []string
+	for _, entry := range index.Entries {
+		paths = append(paths, entry.EntryPath)
+	}
+	return paths
+}
+
+func TestUpdateIndexRejectsInvalidPaths(t *testing.T) {
+	repository := createUpdateIndexRepository(t)
+	for _, entryPath := range []string{"../evil", "/tmp/evil", "a//b", "a/./b", "a/../b", ".gitgood/hooks/x", "sub/.git/config"} {
+		UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + "," + entryPath})
+		readIndexInfo(strings.NewReader("100644 " + emptyBlobHash + "\t" + entryPath + "\n"))
+	}
+	if paths := stagedPaths(t, repository); len(paths) != 0 {
+		t.Fatalf("expected nothing staged, got %v", paths)
+	}
+}
+
+func TestUpdateIndexRejectsDirectoryFileConflicts(t *testing.T) {
+	repository := createUpdateIndexRepository(t)
+	UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + ",a"})
+	UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + ",a/b"})
+	readIndexInfo(strings.NewReader("100644 " + emptyBlobHash + "\ta/b/c\n"))
+	UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + ",d/e"})
+	UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + ",d"})
+	// Only entries at the same stage conflict
+	readIndexInfo(strings.NewReader("100644 " + emptyBlobHash + " 1\td\n"))
+
+	paths := stagedPaths(t, repository)
+	expected := []string{"a", "d", "d/e"}
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %v staged, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Fatalf("expected %v staged, got %v", expected, paths)
+		}
+	}
+}
+
+func TestUpdateIndexChmodOnlyChangesRegularFiles(t *testing.T) {
+	repository := createUpdateIndexRepository(t)
+	UpdateIndex([]string{"--cacheinfo", "120000," + emptyBlobHash + ",link"})
+	UpdateIndex([]string{"--cacheinfo", "160000," + emptyBlobHash + ",module"})
+	UpdateIndex([]string{"--cacheinfo", "100644," + emptyBlobHash + ",file"})
+	UpdateIndex([]string{"--chmod=+x", "link"})
+	UpdateIndex([]string{"--chmod=-x", "module"})
+	UpdateIndex([]string{"--chmod=+x", "file"})
+
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		t.Fatalf("expected no error reading the index, got %v", err)
+	}
+	for entryPath, mode := range map[string]uint32{"link": 0120000, "module": 0160000, "file": 0100755} {
+		entry := index.FindEntry(entryPath)
+		if entry == nil || entry.FileMode != mode {
+			t.Errorf("expected %s to have mode %o, got %v", entryPath, mode, entry)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+func UpdateRef(flags []string) {
+	message := ""
+	deleteRef := false
+	stdin := false
+	var arguments []string
+	for i := 0; i < len(flags); i++ {
+		switch flags[i] {
+		case "-m":
+			if i+1 >= len(flags) {
+				printUpdateRefUsage()
+				return
+			}
+			message = flags[i+1]
+			i++
+		case "-d":
+			deleteRef = true
+		case "--stdin":
+			stdin = true
+		default:
+			if strings.HasPrefix(flags[i], "-") {
+				fmt.Println("Unsupported flag...")
+				printUpdateRefUsage()
+				return
+			}
+			arguments = append(arguments, flags[i])
+		}
+	}
+
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	switch {
+	case stdin && len(arguments) == 0 && !deleteRef:
+		err = updateRefsFromStdin(repository, message)
+	case deleteRef && (len(arguments) == 1 || len(arguments) == 2):
+		err = updateRefs(repository, "delete", arguments, message)
+	case !stdin && !deleteRef && (len(arguments) == 2 || len(arguments) == 3):
+		err = updateRefs(repository, "update", arguments, message)
+	default:
+		printUpdateRefUsage()
+		return
+	}
+	if err != nil {
+		fmt.Printf("fatal: %v\n", err)
+	}
+}
+
+// A one ref transaction for the command line forms
+func updateRefs(repository *common.Repository, command string, arguments []string, message string) error {
+	update, err := parseRefUpdate(repository, command, arguments)
+	if err != nil {
+		return err
+	}
+	update.Message = message
+	transaction := repository.NewRefTransaction()
+	err = transaction.Add(update)
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+// Build an update from "<ref> <new> [<old>]" style arguments - a given old value turns on the
+// compare-and-swap check and the all zero hash stands for a ref that doesn't exist
+func parseRefUpdate(repository *common.Repository, command string, arguments []string) (*common.RefUpdate, error) {
+	update := &common.RefUpdate{Name: arguments[0]}
+	values := arguments[1:]
+
+	switch command {
+	case "update", "create":
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s %s: missing <new-oid>", command, update.Name)
+		}
+		newHash, err := resolveRefValue(repository, values[0])
+		if err != nil {
+			return nil, err
+		}
+		update.NewHash = newHash
+		values = values[1:]
+		if command == "create" {
+			if newHash.Empty() {
+				return nil, fmt.Errorf("create %s: zero <new-oid>", update.Name)
+			}
+			if len(values) > 0 {
+				return nil, fmt.Errorf("create %s: extra input: %s", update.Name, strings.Join(values, " "))
+			}
+			update.CheckOld = true
+		}
+	case "verify":
+		update.Verify = true
+		update.CheckOld = true
+	case "delete":
+	default:
+		return nil, fmt.Errorf("unknown command: %s", command)
+	}
+
+	if len(values) > 1 {
+		return nil, fmt.Errorf("%s %s: extra input: %s", command, update.Name, strings.Join(values[1:], " "))
+	}
+	if len(values) == 1 {
+		oldHash, err := resolveRefValue(repository, values[0])
+		if err != nil {
+			return nil, err
+		}
+		update.OldHash = oldHash
+		update.CheckOld = true
+	}
+	return update, nil
+}
+
+func resolveRefValue(repository *common.Repository, value string) (common.Hash, error) {
+	if value == strings.Repeat("0", 40) {
+		return common.Hash{}, nil
+	}
+	return objects.ResolveRevision(repository, value)
+}
+
+// Read commands from stdin, one per line
+// Without an explicit start everything is queued into one transaction committed at the end of input,
+// with start/prepare/commit/abort the caller drives the transaction and gets an acknowledgement for each step
+func updateRefsFromStdin(repository *common.Repository, message string) error {
+	transaction := repository.NewRefTransaction()
+	explicit := false
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		command := fields[0]
+
+		switch command {
+		case "start":
+			if explicit {
+				transaction.Abort()
+				return fmt.Errorf("start: transaction is already open")
+			}
+			explicit = true
+			transaction = repository.NewRefTransaction()
+			fmt.Println("start: ok")
+		case "prepare":
+			err := transaction.Prepare()
+			if err != nil {
+				return err
+			}
+			fmt.Println("prepare: ok")
+		case "commit":
+			err := transaction.Commit()
+			if err != nil {
+				return err
+			}
+			fmt.Println("commit: ok")
+			explicit = false
+			transaction = repository.NewRefTransaction()
+		case "abort":
+			transaction.Abort()
+			fmt.Println("abort: ok")
+			explicit = false
+			transaction = repository.NewRefTransaction()
+		case "option":
+			// Ignoring an option like no-deref would update a different ref than the one asked for
+			transaction.Abort()
+			return fmt.Errorf("option unknown: %s", strings.TrimSpace(strings.TrimPrefix(line, "option")))
+		case "update", "create", "delete", "verify":
+			if len(fields) < 2 {
+				transaction.Abort()
+				return fmt.Errorf("%s: missing <ref>", command)
+			}
+			update, err := parseRefUpdate(repository, command, fields[1:])
+			if err != nil {
+				transaction.Abort()
+				return err
+			}
+			update.Message = message
+			err = transaction.Add(update)
+			if err != nil {
+				transaction.Abort()
+				return err
+			}
+		default:
+			transaction.Abort()
+			return fmt.Errorf("unknown command: %s", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		transaction.Abort()
+		return fmt.Errorf("error reading stdin: %v", err)
+	}
+
+	// An explicit transaction that was never committed is thrown away like git does
+	if explicit {
+		transaction.Abort()
+		return nil
+	}
+	return transaction.Commit()
+}
+
+func printUpdateRefUsage() {
+	fmt.Println("Usage: gitgood update-ref [-m <reason>] <ref> <new-oid> [<old-oid>]    Point a ref at an object, checking its old value if given")
+	fmt.Println("Usage: gitgood update-ref [-m <reason>] -d <ref> [<old-oid>]           Delete a ref, checking its old value if given")
+	fmt.Println("Usage: gitgood update-ref [-m <reason>] --stdin                        Apply update/create/delete/verify commands from stdin atomically")
+}
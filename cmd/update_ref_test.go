@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func TestUpdateRefStdinRejectsOptions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Init(nil)
+	writeTestFile(t, "a", "a\n")
+	Add([]string{"a"})
+	Commit([]string{"-m", "first"})
+	writeTestFile(t, "a", "b\n")
+	Add([]string{"a"})
+	Commit([]string{"-m", "second"})
+	repository, err := common.FindRepository(".")
+	if err != nil {
+		t.Fatalf("expected a repository, got %v", err)
+	}
+	head, err := repository.ResolveHead()
+	if err != nil {
+		t.Fatalf("expected no error resolving HEAD, got %v", err)
+	}
+
+	input := filepath.Join(t.TempDir(), "stdin")
+	writeTestFile(t, input, "option no-deref\nupdate HEAD HEAD~1\n")
+	stdin, err := os.Open(input)
+	if err != nil {
+		t.Fatalf("expected no error opening %s, got %v", input, err)
+	}
+	defer stdin.Close()
+	originalStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = originalStdin }()
+
+	if err := updateRefsFromStdin(repository, ""); err == nil {
+		t.Fatalf("expected the unsupported option to be an error")
+	}
+	ref, err := repository.ReadRef("refs/heads/main")
+	if err != nil || ref.Hash != head {
+		t.Fatalf("expected main to stay at %s, got %v %v", head, ref, err)
+	}
+}
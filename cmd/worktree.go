@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+	"github.com/CLBRITTON2/go-git-good/objects"
+)
+
+// Reports whether the working tree file still holds the content staged in the entry
+// Stat data is trusted when it can be, otherwise the file is re-hashed
+func worktreeMatchesEntry(repository *common.Repository, index *common.Index, entry *common.IndexEntry) (bool, error) {
+	if entry.AssumeUnchanged() || entry.SkipWorktree() {
+		return true, nil
+	}
+	return compareWorktreeFile(repository, index, entry)
+}
+
+// Like worktreeMatchesEntry but without taking the assume unchanged and skip worktree bits' word
+// for it - anything about to overwrite or delete a file checks it again so local edits aren't lost
+// A skip worktree file that isn't there has nothing to lose
+// Ref https://github.com/git/git/blob/master/unpack-trees.c (verify_uptodate)
+func worktreeIsUpToDate(repository *common.Repository, index *common.Index, entry *common.IndexEntry) (bool, error) {
+	if entry.SkipWorktree() {
+		_, err := os.Lstat(filepath.Join(repository.WorkTree, entry.EntryPath))
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+	}
+	return compareWorktreeFile(repository, index, entry)
+}
+
+func compareWorktreeFile(repository *common.Repository, index *common.Index, entry *common.IndexEntry) (bool, error) {
+	if entry.IntentToAdd() {
+		return false, nil
+	}
+
+	filePath := filepath.Join(repository.WorkTree, entry.EntryPath)
+	if entry.FileMode == 0120000 || entry.FileMode == 0160000 {
+		return compareWorktreeLink(entry, filePath)
+	}
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if index.IsUpToDate(entry, fileInfo) {
+		return true, nil
+	}
+	if common.IndexFileMode(fileInfo.Mode()) != entry.FileMode {
+		return false, nil
+	}
+
+	blob, err := objects.CreateBlobFromFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	return blob.Hash == entry.Hash, nil
+}
+
+// Symlinks match when they point where the blob says and gitlinks when there's a directory for the
+// submodule - what's inside it isn't looked at
+func compareWorktreeLink(entry *common.IndexEntry, filePath string) (bool, error) {
+	fileInfo, err := os.Lstat(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if entry.FileMode == 0160000 {
+		return fileInfo.IsDir(), nil
+	}
+	if fileInfo.Mode()&os.ModeSymlink == 0 {
+		return false, nil
+	}
+	target, err := os.Readlink(filePath)
+	if err != nil {
+		return false, err
+	}
+	hash, err := blobHash([]byte(target))
+	return hash == entry.Hash, err
+}
+
+// Same content and mode - stat data doesn't matter when comparing entries from trees
+func sameEntry(a, b *common.IndexEntry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Hash == b.Hash && a.FileMode == b.FileMode
+}
+
+// Delete a tracked file and any parent directories left empty by its removal
+func removeFromWorkTree(repository *common.Repository, entryPath string) error {
+	filePath := filepath.Join(repository.WorkTree, entryPath)
+	err := os.Remove(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for directory := filepath.Dir(filePath); directory != repository.WorkTree; directory = filepath.Dir(directory) {
+		// Remove fails on directories that still have something in them which is where we stop
+		if os.Remove(directory) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Convert a path given on the command line into an index entry path relative to the work tree
+func indexPath(repository *common.Repository, file string) (string, error) {
+	absolutePath, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(repository.WorkTree, absolutePath)
+}
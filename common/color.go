@@ -0,0 +1,142 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const ColorReset = "\x1b[m"
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// Attribute names with the codes that turn them on and off
+var colorAttributes = []struct {
+	name   string
+	on     int
+	negate int
+}{
+	{"bold", 1, 22},
+	{"dim", 2, 22},
+	{"italic", 3, 23},
+	{"ul", 4, 24},
+	{"blink", 5, 25},
+	{"reverse", 7, 27},
+	{"strike", 9, 29},
+}
+
+// Turn a color config value like "bold red" or "#ff0000 ul" into its ANSI escape sequence
+// Like git it's [reset] [foreground [background]] [attribute]... in any order, and an empty value is no color at all
+// Ref https://git-scm.com/docs/git-config#Documentation/git-config.txt-color
+func ParseColor(value string) (string, error) {
+	reset := false
+	var attributes [30]bool
+	var foreground, background string
+	haveForeground, haveBackground := false, false
+	for _, word := range strings.Fields(value) {
+		if strings.EqualFold(word, "reset") {
+			reset = true
+			continue
+		}
+		if code, ok := parseColorName(word); ok {
+			switch {
+			case !haveForeground:
+				foreground, haveForeground = code, true
+			case !haveBackground:
+				background, haveBackground = code, true
+			default:
+				return "", fmt.Errorf("invalid color value: %s", value)
+			}
+			continue
+		}
+		code, ok := parseColorAttribute(word)
+		if !ok {
+			return "", fmt.Errorf("invalid color value: %s", value)
+		}
+		attributes[code] = true
+	}
+
+	var codes []string
+	for code, set := range attributes {
+		if set {
+			codes = append(codes, strconv.Itoa(code))
+		}
+	}
+	if foreground != "" {
+		codes = append(codes, foreground)
+	}
+	if background != "" {
+		// Backgrounds are the foreground codes moved up by ten
+		if strings.HasPrefix(background, "3") {
+			background = "4" + background[1:]
+		} else if code, err := strconv.Atoi(background); err == nil {
+			background = strconv.Itoa(code + 10)
+		}
+		codes = append(codes, background)
+	}
+	// normal on its own changes nothing so there's nothing to write
+	if !reset && len(codes) == 0 {
+		return "", nil
+	}
+	// A reset has no code of its own but still leaves a separator in front of the rest
+	if reset {
+		codes = append([]string{""}, codes...)
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m", nil
+}
+
+// Returns the foreground code, empty for "normal" which is a color that changes nothing
+func parseColorName(word string) (string, bool) {
+	if strings.EqualFold(word, "normal") {
+		return "", true
+	}
+	if strings.EqualFold(word, "default") {
+		return "39", true
+	}
+	if len(word) == 7 && word[0] == '#' {
+		if rgb, err := strconv.ParseUint(word[1:], 16, 32); err == nil {
+			return fmt.Sprintf("38;2;%d;%d;%d", rgb>>16, rgb>>8&0xff, rgb&0xff), true
+		}
+	}
+
+	name, offset := word, 30
+	if len(name) > 6 && strings.EqualFold(name[:6], "bright") {
+		name, offset = name[6:], 90
+	}
+	for i, colorName := range colorNames {
+		if strings.EqualFold(name, colorName) {
+			return strconv.Itoa(offset + i), true
+		}
+	}
+
+	// 256 color numbers with the first 16 written as the more portable codes
+	number, err := strconv.Atoi(word)
+	switch {
+	case err != nil || number < -1 || number > 255:
+		return "", false
+	case number == -1:
+		return "", true
+	case number < 8:
+		return strconv.Itoa(30 + number), true
+	case number < 16:
+		return strconv.Itoa(90 + number - 8), true
+	}
+	return fmt.Sprintf("38;5;%d", number), true
+}
+
+// Attributes can be turned off with a "no" or "no-" prefix
+func parseColorAttribute(word string) (int, bool) {
+	name, negate := word, false
+	if strings.HasPrefix(name, "no") {
+		name, negate = strings.TrimPrefix(name[2:], "-"), true
+	}
+	for _, attribute := range colorAttributes {
+		if name == attribute.name {
+			if negate {
+				return attribute.negate, true
+			}
+			return attribute.on, true
+		}
+	}
+	return 0, false
+}
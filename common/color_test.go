@@ -0,0 +1,29 @@
+package common
+
+import "testing"
+
+func TestParseColor(t *testing.T) {
+	for value, expected := range map[string]string{
+		"":                           "",
+		"normal":                     "",
+		"red":                        "\x1b[31m",
+		"bold red":                   "\x1b[1;31m",
+		"normal red":                 "\x1b[41m",
+		"brightblue ul":              "\x1b[4;94m",
+		"dim italic":                 "\x1b[2;3m",
+		"reset":                      "\x1b[m",
+		"bold reset #ff0000 blue ul": "\x1b[;1;4;38;2;255;0;0;44m",
+		"208 nobold":                 "\x1b[22;38;5;208m",
+		"green default":              "\x1b[32;49m",
+	} {
+		color, err := ParseColor(value)
+		if err != nil || color != expected {
+			t.Errorf("expected %q to parse as %q, got %q (%v)", value, expected, color, err)
+		}
+	}
+	for _, value := range []string{"purple", "red green blue", "256"} {
+		if _, err := ParseColor(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Simplified git config file: [section] or [section "subsection"] headers followed by key = value lines
+// Keys are addressed the same way git config does it ie core.bare or branch.main.remote
+// The lines of the file are kept so writing it back only touches the lines that were set or unset
+type Config struct {
+	lines    []string
+	sections []*configSection
+}
+
+type configSection struct {
+	name       string
+	subsection string
+	entries    []*configEntry
+	// Where new entries for the section go, after its last line
+	lastLine int
+}
+
+type configEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+func ReadConfig(path string) (*Config, error) {
+	config := &Config{}
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %v", path, err)
+	}
+
+	config.lines = strings.Split(strings.TrimSuffix(string(fileContent), "\n"), "\n")
+	lineNumber, err := config.parse()
+	if err != nil {
+		return nil, fmt.Errorf("bad config line %d in file %s", lineNumber+1, path)
+	}
+	return config, nil
+}
+
+// Build the sections from the lines - returns the line that couldn't be parsed on error
+func (config *Config) parse() (int, error) {
+	config.sections = nil
+	var currentSection *configSection
+	for lineNumber, line := range config.lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			closingIndex := strings.Index(line, "]")
+			if closingIndex == -1 {
+				return lineNumber, errors.New("bad section header")
+			}
+			header := line[1:closingIndex]
+			name, subsection, _ := strings.Cut(header, " ")
+			subsection = strings.Trim(strings.TrimSpace(subsection), `"`)
+			currentSection = config.findSection(name, subsection, true)
+			currentSection.lastLine = lineNumber
+			continue
+		}
+		if currentSection == nil {
+			return lineNumber, errors.New("entry outside a section")
+		}
+
+		// A key without a value is shorthand for true
+		key, value, found := strings.Cut(line, "=")
+		if found {
+			value = parseConfigValue(value)
+		} else {
+			key, _, _ = strings.Cut(key, "#")
+			key, _, _ = strings.Cut(key, ";")
+			value = "true"
+		}
+		currentSection.entries = append(currentSection.entries, &configEntry{
+			key:   strings.ToLower(strings.TrimSpace(key)),
+			value: value,
+			line:  lineNumber,
+		})
+		currentSection.lastLine = lineNumber
+	}
+	return 0, nil
+}
+
+// Whitespace around the value and unquoted ; or # comments are dropped, quotes are removed and
+// backslash escapes are expanded
+// Ref https://github.com/git/git/blob/master/config.c (parse_value)
+func parseConfigValue(raw string) string {
+	var builder strings.Builder
+	quoted := false
+	// Spaces are only kept once something follows them
+	spaces := ""
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case !quoted && (c == ';' || c == '#'):
+			return builder.String()
+		case !quoted && (c == ' ' || c == '\t'):
+			if builder.Len() > 0 {
+				spaces += string(c)
+			}
+			continue
+		}
+		builder.WriteString(spaces)
+		spaces = ""
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			case 'b':
+				builder.WriteByte('\b')
+			default:
+				builder.WriteByte(raw[i])
+			}
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// Values that wouldn't read back the same are quoted and escaped like git does
+func formatConfigValue(value string) string {
+	needsQuotes := strings.HasPrefix(value, " ") || strings.HasSuffix(value, " ") || strings.ContainsAny(value, ";#")
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\b", `\b`)
+	value = replacer.Replace(value)
+	if needsQuotes {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+func (config *Config) Write(path string) error {
+	content := ""
+	if len(config.lines) > 0 {
+		content = strings.Join(config.lines, "\n") + "\n"
+	}
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Returns the last value set for the key
+func (config *Config) Get(key string) (string, bool) {
+	name, subsection, variable := splitConfigKey(key)
+	section := config.findSection(name, subsection, false)
+	if section == nil {
+		return "", false
+	}
+	for i := len(section.entries) - 1; i >= 0; i-- {
+		if section.entries[i].key == variable {
+			return section.entries[i].value, true
+		}
+	}
+	return "", false
+}
+
+func (config *Config) GetBool(key string, defaultValue bool) bool {
+	value, found := config.Get(key)
+	if !found {
+		return defaultValue
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0", "":
+		return false
+	}
+	return defaultValue
+}
+
+// The last line setting the key is changed in place, otherwise the key goes at the end of its section
+// or a new section at the end of the file
+func (config *Config) Set(key, value string) {
+	name, subsection, variable := splitConfigKey(key)
+	// Names match case insensitively but are written the way they were given like git does
+	line := fmt.Sprintf("\t%s = %s", key[strings.LastIndex(key, ".")+1:], formatConfigValue(value))
+	section := config.findSection(name, subsection, false)
+	switch {
+	case section == nil:
+		header := fmt.Sprintf("[%s]", strings.ToLower(name))
+		if subsection != "" {
+			header = fmt.Sprintf("[%s \"%s\"]", strings.ToLower(name), subsection)
+		}
+		config.lines = append(config.lines, header, line)
+	case config.lastEntry(section, variable) != nil:
+		config.lines[config.lastEntry(section, variable).line] = line
+	default:
+		config.lines = slices.Insert(config.lines, section.lastLine+1, line)
+	}
+	config.parse()
+}
+
+func (config *Config) lastEntry(section *configSection, variable string) *configEntry {
+	for i := len(section.entries) - 1; i >= 0; i-- {
+		if section.entries[i].key == variable {
+			return section.entries[i]
+		}
+	}
+	return nil
+}
+
+// Every line setting the key is removed, the section header stays like git leaves it
+func (config *Config) Unset(key string) {
+	name, subsection, variable := splitConfigKey(key)
+	section := config.findSection(name, subsection, false)
+	if section == nil {
+		return
+	}
+	removed := map[int]bool{}
+	for _, entry := range section.entries {
+		if entry.key == variable {
+			removed[entry.line] = true
+		}
+	}
+	lines := config.lines[:0]
+	for i, line := range config.lines {
+		if !removed[i] {
+			lines = append(lines, line)
+		}
+	}
+	config.lines = lines
+	config.parse()
+}
+
+// Section and variable names are case insensitive, subsection names are not
+func (config *Config) findSection(name, subsection string, create bool) *configSection {
+	name = strings.ToLower(name)
+	for _, section := range config.sections {
+		if section.name == name && section.subsection == subsection {
+			return section
+		}
+	}
+	if !create {
+		return nil
+	}
+	section := &configSection{name: name, subsection: subsection}
+	config.sections = append(config.sections, section)
+	return section
+}
+
+// The subsection can contain dots so only the first and last dots separate the key
+func splitConfigKey(key string) (string, string, string) {
+	firstDot := strings.Index(key, ".")
+	lastDot := strings.LastIndex(key, ".")
+	if firstDot == -1 {
+		return key, "", ""
+	}
+	variable := strings.ToLower(key[lastDot+1:])
+	if firstDot == lastDot {
+		return key[:firstDot], "", variable
+	}
+	return key[:firstDot], key[firstDot+1 : lastDot], variable
+}
+
+func (repository *Repository) Config() (*Config, error) {
+	return ReadConfig(filepath.Join(repository.GitDirectory, "config"))
+}
+
+func (repository *Repository) WriteConfig(config *Config) error {
+	return config.Write(filepath.Join(repository.GitDirectory, "config"))
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	content := "[diff]\n" +
+		"\talgorithm = patience ; picked for the monorepo\n" +
+		"\twordRegex = \"[^;# ]+\" # quoted so the comment characters stay\n" +
+		"\tquoted = \"  padded\\tvalue \"\n" +
+		"[core]\n" +
+		"\tbare # no value means true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("expected no error writing config, got %v", err)
+	}
+	config, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error reading config, got %v", err)
+	}
+	for key, expected := range map[string]string{
+		"diff.algorithm": "patience",
+		"diff.wordregex": "[^;# ]+",
+		"diff.quoted":    "  padded\tvalue ",
+		"core.bare":      "true",
+	} {
+		if value, _ := config.Get(key); value != expected {
+			t.Errorf("expected %s to be %q, got %q", key, expected, value)
+		}
+	}
+}
+
+func TestConfigWriteKeepsTheRestOfTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	content := "# Settings for the monorepo\n" +
+		"[core]\n" +
+		"    bare = false ; never bare\n" +
+		"\tsparseCheckout = false\n" +
+		"\n" +
+		"[user]\n" +
+		"\tname = Someone\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("expected no error writing config, got %v", err)
+	}
+	config, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error reading config, got %v", err)
+	}
+	config.Set("core.sparseCheckout", "true")
+	config.Set("core.sparseCheckoutCone", "true")
+	config.Set("branch.main.remote", "origin")
+	config.Unset("user.name")
+	if err := config.Write(path); err != nil {
+		t.Fatalf("expected no error writing config, got %v", err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error reading config, got %v", err)
+	}
+	expected := "# Settings for the monorepo\n" +
+		"[core]\n" +
+		"    bare = false ; never bare\n" +
+		"\tsparseCheckout = true\n" +
+		"\tsparseCheckoutCone = true\n" +
+		"\n" +
+		"[user]\n" +
+		"[branch \"main\"]\n" +
+		"\tremote = origin\n"
+	if string(written) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, written)
+	}
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 )
 
 type Hash [20]byte
@@ -23,3 +24,14 @@ func (hash Hash) String() string {
 func (hash Hash) Empty() bool {
 	return hash == Hash{}
 }
+
+func ParseHash(hashString string) (Hash, error) {
+	if len(hashString) != 40 {
+		return Hash{}, fmt.Errorf("invalid hash length: expected length 40 got %v", len(hashString))
+	}
+	hashBytes, err := hex.DecodeString(hashString)
+	if err != nil {
+		return Hash{}, fmt.Errorf("invalid hash %s: %v", hashString, err)
+	}
+	return Hash(hashBytes), nil
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config files checked for user.name and user.email, most specific first
+func (repository *Repository) identityConfigPaths() []string {
+	paths := []string{filepath.Join(repository.GitDirectory, "config")}
+	userHomeDirectory, err := os.UserHomeDir()
+	if err == nil {
+		paths = append(paths,
+			filepath.Join(userHomeDirectory, ".gitconfig"),
+			filepath.Join(userHomeDirectory, ".config", "git", "config"),
+		)
+	}
+	return paths
+}
+
+// The "name <email>" used for commits and reflog entries
+// The repository config wins over the global config, falling back to a placeholder name
+func (repository *Repository) Identity() (string, error) {
+	name, email := "", ""
+	for _, path := range repository.identityConfigPaths() {
+		config, err := ReadConfig(path)
+		if err != nil {
+			return "", err
+		}
+		if value, found := config.Get("user.name"); found && name == "" {
+			name = value
+		}
+		if value, found := config.Get("user.email"); found && email == "" {
+			email = value
+		}
+	}
+	if name == "" {
+		name = "local user"
+	}
+	return fmt.Sprintf("%s <%s>", name, email), nil
+}
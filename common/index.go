@@ -2,26 +2,56 @@ package common
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Flag bits stored alongside each index entry
+const (
+	flagAssumeValid = 0x8000
+	flagExtended    = 0x4000
+	flagStageMask   = 0x3000
+	flagNameMask    = 0x0FFF
+
+	// Extended flags only exist in version 3 and later
+	extendedFlagSkipWorktree = 0x4000
+	extendedFlagIntentToAdd  = 0x2000
+)
+
+// The blob of an empty file
+var emptyBlobHash, _ = ParseHash("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+
 type Index struct {
+	Version         uint32
 	NumberOfEntries uint32
 	Entries         []*IndexEntry
+	// Modification time of the index file when it was read - used for racy git detection
+	Timestamp time.Time
 }
 
 type IndexEntry struct {
-	ModifiedTime time.Time
-	Hash         Hash
-	FileSize     uint32
-	FileMode     uint32
-	EntryPath    string
+	ChangedTime   time.Time
+	ModifiedTime  time.Time
+	Device        uint32
+	Inode         uint32
+	UserID        uint32
+	GroupID       uint32
+	Hash          Hash
+	FileSize      uint32
+	FileMode      uint32
+	Flags         uint16
+	ExtendedFlags uint16
+	EntryPath     string
 }
 
 func (index *Index) Exists(repository *Repository) (bool, error) {
@@ -42,6 +72,7 @@ func GetIndex(repository *Repository) (*Index, error) {
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return &Index{
+				Version:         2,
 				NumberOfEntries: 0,
 				Entries:         []*IndexEntry{},
 			}, nil
@@ -59,43 +90,79 @@ func ReadIndex(filePath string) (*Index, error) {
 		}
 		return nil, fmt.Errorf("%v", err)
 	}
+	indexFileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading index file info: %v", err)
+	}
 
+	if len(indexFileData) < 12 {
+		return nil, fmt.Errorf("error reading index: file too short")
+	}
 	if string(indexFileData[:4]) != "DIRC" {
 		return nil, fmt.Errorf("error reading index header: invalid signature")
 	}
 	indexVersion := binary.BigEndian.Uint32(indexFileData[4:8])
-	if indexVersion != 2 {
-		return nil, fmt.Errorf("error reading index version number: expected 2 got %v", indexVersion)
+	if indexVersion < 2 || indexVersion > 4 {
+		return nil, fmt.Errorf("error reading index version number: expected 2, 3 or 4 got %v", indexVersion)
 	}
 	indexEntryCount := binary.BigEndian.Uint32(indexFileData[8:12])
 
+	// 12 byte header + 20 byte SHA-1 checksum trailer
+	checksumOffset := max(len(indexFileData)-20, 12)
+	checksum := sha1.Sum(indexFileData[:checksumOffset])
+	if !bytes.Equal(checksum[:], indexFileData[checksumOffset:]) {
+		// Indexes from before entries were written like git's have no checksum, the next write upgrades them
+		if indexVersion == 2 {
+			if index, err := readLegacyIndex(indexFileData[12:], indexEntryCount); err == nil {
+				index.Timestamp = indexFileInfo.ModTime()
+				return index, nil
+			}
+		}
+		return nil, fmt.Errorf("error reading index: checksum mismatch")
+	}
+
 	index := &Index{
+		Version:         indexVersion,
 		NumberOfEntries: indexEntryCount,
 		Entries:         make([]*IndexEntry, 0, indexEntryCount),
+		Timestamp:       indexFileInfo.ModTime(),
 	}
 
 	// Entries will live 12 bytes past the header
-	buffer := bytes.NewReader(indexFileData[12:])
+	buffer := bytes.NewReader(indexFileData[12:checksumOffset])
+	previousPath := ""
 	for i := uint32(0); i < indexEntryCount; i++ {
-		// 36 bytes are required minimum (mod time 8, file mode 4, file size 4, hash 20)
-		// If we only have 36 bytes we're missing something
-		// Entry path is variable
-		if buffer.Len() <= 36 {
-			return nil, fmt.Errorf("error reading index entry: content less than 36 bytes for required fields")
+		entryStart := buffer.Len()
+		var header indexEntryHeader
+		err := binary.Read(buffer, binary.BigEndian, &header)
+		if err != nil {
+			return nil, fmt.Errorf("error reading index entry: %v", err)
 		}
-		var modifiedTimeSeconds, modifiedTimeNano uint32
-		binary.Read(buffer, binary.BigEndian, &modifiedTimeSeconds)
-		binary.Read(buffer, binary.BigEndian, &modifiedTimeNano)
-		modifiedTime := time.Unix(int64(modifiedTimeSeconds), int64(modifiedTimeNano))
-
-		var fileMode uint32
-		binary.Read(buffer, binary.BigEndian, &fileMode)
 
-		var fileSize uint32
-		binary.Read(buffer, binary.BigEndian, &fileSize)
+		var extendedFlags uint16
+		if header.Flags&flagExtended != 0 {
+			if indexVersion < 3 {
+				return nil, fmt.Errorf("error reading index entry: extended flags in a version %v index", indexVersion)
+			}
+			err = binary.Read(buffer, binary.BigEndian, &extendedFlags)
+			if err != nil {
+				return nil, fmt.Errorf("error reading index entry extended flags: %v", err)
+			}
+		}
 
-		var hash [20]byte
-		binary.Read(buffer, binary.BigEndian, &hash)
+		// Version 4 paths are prefix compressed against the previous entry's path:
+		// a varint with the number of bytes to strip from the end of the previous path followed by the new suffix
+		prefix := ""
+		if indexVersion == 4 {
+			stripLength, err := readOffsetVarint(buffer)
+			if err != nil {
+				return nil, fmt.Errorf("error reading index entry path: %v", err)
+			}
+			if stripLength > uint64(len(previousPath)) {
+				return nil, fmt.Errorf("error reading index entry path: invalid prefix length")
+			}
+			prefix = previousPath[:len(previousPath)-int(stripLength)]
+		}
 
 		var pathBytes []byte
 		for {
@@ -109,44 +176,190 @@ func ReadIndex(filePath string) (*Index, error) {
 			}
 			pathBytes = append(pathBytes, pathBuffer)
 		}
-		indexEntryPath := string(pathBytes)
+		indexEntryPath := prefix + string(pathBytes)
+
+		// Versions 2 and 3 pad each entry with 1-8 null bytes to a multiple of 8 - one was consumed above
+		if indexVersion < 4 {
+			entryLength := entryStart - buffer.Len()
+			padding := (8 - entryLength%8) % 8
+			_, err = buffer.Seek(int64(padding), io.SeekCurrent)
+			if err != nil {
+				return nil, fmt.Errorf("error reading index entry padding: %v", err)
+			}
+		}
 
 		indexEntry := &IndexEntry{
-			ModifiedTime: modifiedTime,
-			FileMode:     fileMode,
-			FileSize:     fileSize,
-			Hash:         hash,
-			EntryPath:    indexEntryPath,
+			ChangedTime:   time.Unix(int64(header.ChangedTimeSeconds), int64(header.ChangedTimeNano)),
+			ModifiedTime:  time.Unix(int64(header.ModifiedTimeSeconds), int64(header.ModifiedTimeNano)),
+			Device:        header.Device,
+			Inode:         header.Inode,
+			FileMode:      header.FileMode,
+			UserID:        header.UserID,
+			GroupID:       header.GroupID,
+			FileSize:      header.FileSize,
+			Hash:          header.Hash,
+			Flags:         header.Flags &^ (flagExtended | flagNameMask),
+			ExtendedFlags: extendedFlags,
+			EntryPath:     indexEntryPath,
 		}
 
 		index.Entries = append(index.Entries, indexEntry)
+		previousPath = indexEntryPath
+	}
+
+	// Indexes written before entries were kept sorted are in insertion order
+	if !slices.IsSortedFunc(index.Entries, compareIndexEntries) {
+		slices.SortStableFunc(index.Entries, compareIndexEntries)
+	}
+
+	// Whatever is left before the checksum is extensions: 4 byte signature, 4 byte size, data
+	// Extensions starting with an upper case letter are optional caches (tree cache etc) and are safe to drop
+	for buffer.Len() > 0 {
+		var signature [4]byte
+		var size uint32
+		binary.Read(buffer, binary.BigEndian, &signature)
+		err = binary.Read(buffer, binary.BigEndian, &size)
+		if err != nil {
+			return nil, fmt.Errorf("error reading index extension: %v", err)
+		}
+		if signature[0] < 'A' || signature[0] > 'Z' {
+			return nil, fmt.Errorf("error reading index: unsupported required extension %q", string(signature[:]))
+		}
+		_, err = buffer.Seek(int64(size), io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("error reading index extension %q: %v", string(signature[:]), err)
+		}
+	}
+	return index, nil
+}
+
+// The original layout: modified time, mode, size, hash and the null terminated path with no padding,
+// other stat data or trailing checksum - it only counts if the entries use up the data exactly
+func readLegacyIndex(data []byte, entryCount uint32) (*Index, error) {
+	index := &Index{Version: 2, NumberOfEntries: entryCount}
+	buffer := bytes.NewReader(data)
+	for i := uint32(0); i < entryCount; i++ {
+		var header struct {
+			ModifiedTimeSeconds uint32
+			ModifiedTimeNano    uint32
+			FileMode            uint32
+			FileSize            uint32
+			Hash                Hash
+		}
+		err := binary.Read(buffer, binary.BigEndian, &header)
+		if err != nil {
+			return nil, fmt.Errorf("error reading index entry: %v", err)
+		}
+		var pathBytes []byte
+		for {
+			pathBuffer, err := buffer.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("error reading index entry path: %v", err)
+			}
+			if pathBuffer == 0 {
+				break
+			}
+			pathBytes = append(pathBytes, pathBuffer)
+		}
+		index.Entries = append(index.Entries, &IndexEntry{
+			ModifiedTime: time.Unix(int64(header.ModifiedTimeSeconds), int64(header.ModifiedTimeNano)),
+			FileMode:     header.FileMode,
+			FileSize:     header.FileSize,
+			Hash:         header.Hash,
+			EntryPath:    string(pathBytes),
+		})
+	}
+	if buffer.Len() != 0 {
+		return nil, fmt.Errorf("error reading index: unexpected data after entries")
+	}
+	if !slices.IsSortedFunc(index.Entries, compareIndexEntries) {
+		slices.SortStableFunc(index.Entries, compareIndexEntries)
 	}
 	return index, nil
 }
 
 func WriteIndex(repository *Repository, index *Index) error {
+	// Git only bumps to version 3 when an entry actually needs extended flags
+	version := index.Version
+	if version == 0 {
+		version = 2
+	}
+	if version == 2 {
+		for _, entry := range index.Entries {
+			if entry.ExtendedFlags != 0 {
+				version = 3
+				break
+			}
+		}
+	}
+
 	// DIRC = dircache in normal Git index files
-	// This is a dumbed down version but may as well keep the header consistent for now
 	header := [4]byte{'D', 'I', 'R', 'C'}
-	version := uint32(2)
 	var buffer bytes.Buffer
 	binary.Write(&buffer, binary.BigEndian, header)
 	binary.Write(&buffer, binary.BigEndian, version)
-	binary.Write(&buffer, binary.BigEndian, index.NumberOfEntries)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(index.Entries)))
+
+	// Any file modified in the same second the index is written could still change without its
+	// mtime or size moving, so smudge the stored size to force a content check next time it's compared
+	writeTime := time.Now()
 
-	// Mimic git index format as close as possible excluding some metadata ie dev/ino/uid etc
+	previousPath := ""
 	for _, entry := range index.Entries {
-		modifiedTimeSeconds := uint32(entry.ModifiedTime.Unix())
-		modifiedTimeNano := uint32(entry.ModifiedTime.Nanosecond())
-		binary.Write(&buffer, binary.BigEndian, modifiedTimeSeconds)
-		binary.Write(&buffer, binary.BigEndian, modifiedTimeNano)
-		binary.Write(&buffer, binary.BigEndian, entry.FileMode)
-		binary.Write(&buffer, binary.BigEndian, entry.FileSize)
-		binary.Write(&buffer, binary.BigEndian, entry.Hash)
-		buffer.WriteString(entry.EntryPath)
-		buffer.WriteByte(0)
+		fileSize := entry.FileSize
+		if entry.ModifiedTime.Unix() >= writeTime.Unix() {
+			fileSize = 0
+		}
+
+		// Name length is capped at 0xFFF - longer paths are still written in full and found by their null terminator
+		flags := entry.Flags &^ (flagExtended | flagNameMask)
+		flags |= uint16(min(len(entry.EntryPath), flagNameMask))
+		if entry.ExtendedFlags != 0 {
+			flags |= flagExtended
+		}
+
+		changedTimeSeconds, changedTimeNano := indexTime(entry.ChangedTime)
+		modifiedTimeSeconds, modifiedTimeNano := indexTime(entry.ModifiedTime)
+		entryHeader := indexEntryHeader{
+			ChangedTimeSeconds:  changedTimeSeconds,
+			ChangedTimeNano:     changedTimeNano,
+			ModifiedTimeSeconds: modifiedTimeSeconds,
+			ModifiedTimeNano:    modifiedTimeNano,
+			Device:              entry.Device,
+			Inode:               entry.Inode,
+			FileMode:            entry.FileMode,
+			UserID:              entry.UserID,
+			GroupID:             entry.GroupID,
+			FileSize:            fileSize,
+			Hash:                entry.Hash,
+			Flags:               flags,
+		}
+		entryStart := buffer.Len()
+		binary.Write(&buffer, binary.BigEndian, entryHeader)
+		if entry.ExtendedFlags != 0 {
+			binary.Write(&buffer, binary.BigEndian, entry.ExtendedFlags)
+		}
+
+		if version == 4 {
+			commonLength := 0
+			for commonLength < len(previousPath) && commonLength < len(entry.EntryPath) &&
+				previousPath[commonLength] == entry.EntryPath[commonLength] {
+				commonLength++
+			}
+			buffer.Write(encodeOffsetVarint(uint64(len(previousPath) - commonLength)))
+			buffer.WriteString(entry.EntryPath[commonLength:])
+			buffer.WriteByte(0)
+		} else {
+			buffer.WriteString(entry.EntryPath)
+			entryLength := buffer.Len() - entryStart
+			buffer.Write(make([]byte, 8-entryLength%8))
+		}
+		previousPath = entry.EntryPath
 	}
 
+	checksum := sha1.Sum(buffer.Bytes())
+	buffer.Write(checksum[:])
+
 	indexPath := filepath.Join(repository.GitDirectory, "index")
 	err := os.WriteFile(indexPath, buffer.Bytes(), 0644)
 	if err != nil {
@@ -155,24 +368,284 @@ func WriteIndex(repository *Repository, index *Index) error {
 	return nil
 }
 
+// Fixed size portion of an on disk index entry
+type indexEntryHeader struct {
+	ChangedTimeSeconds  uint32
+	ChangedTimeNano     uint32
+	ModifiedTimeSeconds uint32
+	ModifiedTimeNano    uint32
+	Device              uint32
+	Inode               uint32
+	FileMode            uint32
+	UserID              uint32
+	GroupID             uint32
+	FileSize            uint32
+	Hash                [20]byte
+	Flags               uint16
+}
+
+func indexTime(timestamp time.Time) (uint32, uint32) {
+	if timestamp.IsZero() {
+		return 0, 0
+	}
+	return uint32(timestamp.Unix()), uint32(timestamp.Nanosecond())
+}
+
+// Git's offset varint: each continuation byte implicitly adds one so there is exactly one encoding per value
+func encodeOffsetVarint(value uint64) []byte {
+	var varint [16]byte
+	position := len(varint) - 1
+	varint[position] = byte(value & 127)
+	for value >>= 7; value != 0; value >>= 7 {
+		value--
+		position--
+		varint[position] = 128 | byte(value&127)
+	}
+	return varint[position:]
+}
+
+func readOffsetVarint(reader io.ByteReader) (uint64, error) {
+	current, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	value := uint64(current & 127)
+	for current&128 != 0 {
+		current, err = reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value = ((value + 1) << 7) | uint64(current&127)
+	}
+	return value, nil
+}
+
+// Entries are kept sorted the way Git sorts them: by path bytes, then by stage
+func compareIndexEntries(a, b *IndexEntry) int {
+	if a.EntryPath != b.EntryPath {
+		return strings.Compare(a.EntryPath, b.EntryPath)
+	}
+	return a.Stage() - b.Stage()
+}
+
+// Binary search for the run of entries (one per stage) that share a path
+func (index *Index) pathRange(entryPath string) (int, int) {
+	start := sort.Search(len(index.Entries), func(i int) bool {
+		return index.Entries[i].EntryPath >= entryPath
+	})
+	end := start
+	for end < len(index.Entries) && index.Entries[end].EntryPath == entryPath {
+		end++
+	}
+	return start, end
+}
+
 func (index *Index) AddEntry(entry *IndexEntry) {
-	for i, existingEntry := range index.Entries {
-		if existingEntry.EntryPath == entry.EntryPath {
-			index.Entries[i] = entry
-			return
+	start, end := index.pathRange(entry.EntryPath)
+
+	// A resolved (stage 0) entry replaces every conflict stage of its path and a conflict stage
+	// replaces the resolved entry, so only other conflict stages survive
+	replacement := []*IndexEntry{}
+	for _, existingEntry := range index.Entries[start:end] {
+		if existingEntry.Stage() != 0 && entry.Stage() != 0 && existingEntry.Stage() != entry.Stage() {
+			replacement = append(replacement, existingEntry)
 		}
 	}
+	replacement = append(replacement, entry)
+	slices.SortFunc(replacement, compareIndexEntries)
 
-	index.Entries = append(index.Entries, entry)
+	index.Entries = slices.Replace(index.Entries, start, end, replacement...)
 	index.NumberOfEntries = uint32(len(index.Entries))
 }
 
+// Batch version of AddEntry so large adds only sort once - each path in the batch replaces
+// every existing stage of that path and later entries in the batch win over earlier ones
+func (index *Index) AddEntries(entries []*IndexEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	replacedPaths := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		replacedPaths[entry.EntryPath] = true
+	}
+
+	combined := make([]*IndexEntry, 0, len(index.Entries)+len(entries))
+	for _, entry := range index.Entries {
+		if !replacedPaths[entry.EntryPath] {
+			combined = append(combined, entry)
+		}
+	}
+	combined = append(combined, entries...)
+	slices.SortStableFunc(combined, compareIndexEntries)
+
+	// The sort is stable so the last of any duplicates is the one added most recently
+	deduplicated := combined[:0]
+	for i, entry := range combined {
+		if i+1 < len(combined) && compareIndexEntries(entry, combined[i+1]) == 0 {
+			continue
+		}
+		deduplicated = append(deduplicated, entry)
+	}
+
+	index.Entries = deduplicated
+	index.NumberOfEntries = uint32(len(index.Entries))
+}
+
+// Removes every stage of the path
 func (index *Index) RemoveEntry(entryPath string) {
-	for i, entry := range index.Entries {
-		if entry.EntryPath == entryPath {
-			index.Entries = slices.Delete(index.Entries, i, i+1)
-			index.NumberOfEntries = uint32(len(index.Entries))
-			return
+	start, end := index.pathRange(entryPath)
+	index.Entries = slices.Delete(index.Entries, start, end)
+	index.NumberOfEntries = uint32(len(index.Entries))
+}
+
+// Returns the path of an entry at the same stage that conflicts with this one - either a file where
+// one of its leading directories goes or something inside it when it's meant to be a file
+// Ref https://github.com/git/git/blob/master/read-cache.c (check_file_directory_conflict)
+func (index *Index) DirectoryConflict(entry *IndexEntry) string {
+	for directory := path.Dir(entry.EntryPath); directory != "."; directory = path.Dir(directory) {
+		start, end := index.pathRange(directory)
+		for _, existingEntry := range index.Entries[start:end] {
+			if existingEntry.Stage() == entry.Stage() {
+				return existingEntry.EntryPath
+			}
 		}
 	}
+
+	// Everything under the path sorts together right after it
+	prefix := entry.EntryPath + "/"
+	start, _ := index.pathRange(prefix)
+	for _, existingEntry := range index.Entries[start:] {
+		if !strings.HasPrefix(existingEntry.EntryPath, prefix) {
+			break
+		}
+		if existingEntry.Stage() == entry.Stage() {
+			return existingEntry.EntryPath
+		}
+	}
+	return ""
+}
+
+// Returns the lowest stage entry for the path - for paths without conflicts that's the only entry
+func (index *Index) FindEntry(entryPath string) *IndexEntry {
+	start, end := index.pathRange(entryPath)
+	if start == end {
+		return nil
+	}
+	return index.Entries[start]
+}
+
+// 0 for normal entries, 1-3 for the base/ours/theirs versions of a conflicted path
+func (entry *IndexEntry) Stage() int {
+	return int(entry.Flags&flagStageMask) >> 12
+}
+
+func (entry *IndexEntry) SetStage(stage int) {
+	entry.Flags = entry.Flags&^flagStageMask | uint16(stage<<12)&flagStageMask
+}
+
+// Reports whether any path has unresolved conflict stages
+func (index *Index) HasConflicts() bool {
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// An entry modified at or after the time the index was written is "racily clean" - the file could
+// have been changed again within the same timestamp so its stat data can't be trusted
+// Ref https://git-scm.com/docs/racy-git
+func (index *Index) IsRacilyClean(entry *IndexEntry) bool {
+	if index.Timestamp.IsZero() {
+		return false
+	}
+	return entry.ModifiedTime.Unix() >= index.Timestamp.Unix()
+}
+
+// Reports whether the file on disk can be assumed identical to the entry without re-hashing it
+func (index *Index) IsUpToDate(entry *IndexEntry, fileInfo os.FileInfo) bool {
+	return entry.MatchesStat(fileInfo) && !index.IsRacilyClean(entry)
+}
+
+// Compare the cached stat data of an entry to the current state of the file
+// A size of 0 may have been smudged by a racy write so it only counts for entries that really are empty
+// Ref https://github.com/git/git/blob/master/read-cache.c (ce_match_stat_basic)
+func (entry *IndexEntry) MatchesStat(fileInfo os.FileInfo) bool {
+	if entry.FileSize == 0 && entry.Hash != emptyBlobHash {
+		return false
+	}
+	return entry.ModifiedTime.Equal(fileInfo.ModTime()) &&
+		entry.FileSize == uint32(fileInfo.Size()) &&
+		entry.FileMode == IndexFileMode(fileInfo.Mode())
+}
+
+// Assume unchanged entries are never compared against the working tree
+func (entry *IndexEntry) AssumeUnchanged() bool {
+	return entry.Flags&flagAssumeValid != 0
+}
+
+func (entry *IndexEntry) SetAssumeUnchanged(assumeUnchanged bool) {
+	if assumeUnchanged {
+		entry.Flags |= flagAssumeValid
+	} else {
+		entry.Flags &^= flagAssumeValid
+	}
+}
+
+// Skip worktree entries are tracked in the index but not expected to exist in the working tree
+func (entry *IndexEntry) SkipWorktree() bool {
+	return entry.ExtendedFlags&extendedFlagSkipWorktree != 0
+}
+
+func (entry *IndexEntry) SetSkipWorktree(skipWorktree bool) {
+	if skipWorktree {
+		entry.ExtendedFlags |= extendedFlagSkipWorktree
+	} else {
+		entry.ExtendedFlags &^= extendedFlagSkipWorktree
+	}
+}
+
+// Intent to add entries record that a path will be added later - they're left out of trees
+// but show up as new files when comparing the index against the working tree
+func (entry *IndexEntry) IntentToAdd() bool {
+	return entry.ExtendedFlags&extendedFlagIntentToAdd != 0
+}
+
+func (entry *IndexEntry) SetIntentToAdd(intentToAdd bool) {
+	if intentToAdd {
+		entry.ExtendedFlags |= extendedFlagIntentToAdd
+	} else {
+		entry.ExtendedFlags &^= extendedFlagIntentToAdd
+	}
+}
+
+// Paths that can be stored in the index: relative, no empty, "." or ".." components and nothing
+// inside a repository directory, so writing them out can't land outside the work tree or in .gitgood
+// Ref https://github.com/git/git/blob/master/read-cache.c (verify_path)
+func VerifyPath(entryPath string) bool {
+	if entryPath == "" || strings.HasPrefix(entryPath, "/") {
+		return false
+	}
+	for _, component := range strings.Split(entryPath, "/") {
+		switch strings.ToLower(component) {
+		case "", ".", "..", ".git", ".gitgood":
+			return false
+		}
+	}
+	return true
+}
+
+// Keeping it simple for now - normal files and executable are the only accepted modes
+// Returns 0 for anything else
+func IndexFileMode(mode os.FileMode) uint32 {
+	if !mode.IsRegular() {
+		return 0
+	}
+	if mode&0111 != 0 {
+		// Executable
+		return 0100755
+	}
+	// Regular file
+	return 0100644
 }
@@ -0,0 +1,233 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func createTestIndex(t *testing.T, version uint32) (*Repository, *Index) {
+	t.Helper()
+	repository := &Repository{
+		WorkTree:     t.TempDir(),
+		GitDirectory: t.TempDir(),
+	}
+	modifiedTime := time.Unix(1700000000, 123)
+	index := &Index{Version: version}
+	for _, entryPath := range []string{"README.md", "cmd/add.go", "cmd/commit.go", "common/index.go"} {
+		index.Entries = append(index.Entries, &IndexEntry{
+			ModifiedTime: modifiedTime,
+			FileSize:     uint32(len(entryPath)),
+			FileMode:     0100644,
+			EntryPath:    entryPath,
+		})
+	}
+	return repository, index
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	for _, version := range []uint32{2, 3, 4} {
+		repository, index := createTestIndex(t, version)
+		if err := WriteIndex(repository, index); err != nil {
+			t.Fatalf("version %d: expected no error writing index, got %v", version, err)
+		}
+		readIndex, err := ReadIndex(filepath.Join(repository.GitDirectory, "index"))
+		if err != nil {
+			t.Fatalf("version %d: expected no error reading index, got %v", version, err)
+		}
+		if readIndex.Version != version {
+			t.Errorf("expected version %d, got %d", version, readIndex.Version)
+		}
+		if len(readIndex.Entries) != len(index.Entries) {
+			t.Fatalf("version %d: expected %d entries, got %d", version, len(index.Entries), len(readIndex.Entries))
+		}
+		for i, entry := range readIndex.Entries {
+			expected := index.Entries[i]
+			if entry.EntryPath != expected.EntryPath {
+				t.Errorf("version %d: expected path %q, got %q", version, expected.EntryPath, entry.EntryPath)
+			}
+			if entry.FileSize != expected.FileSize || !entry.ModifiedTime.Equal(expected.ModifiedTime) {
+				t.Errorf("version %d: stat data for %q did not round trip", version, entry.EntryPath)
+			}
+		}
+	}
+}
+
+func TestIndexExtendedFlagsUpgradeVersion(t *testing.T) {
+	repository, index := createTestIndex(t, 2)
+	index.Entries[1].ExtendedFlags = extendedFlagSkipWorktree
+	if err := WriteIndex(repository, index); err != nil {
+		t.Fatalf("expected no error writing index, got %v", err)
+	}
+	readIndex, err := ReadIndex(filepath.Join(repository.GitDirectory, "index"))
+	if err != nil {
+		t.Fatalf("expected no error reading index, got %v", err)
+	}
+	if readIndex.Version != 3 {
+		t.Errorf("expected extended flags to upgrade the index to version 3, got %d", readIndex.Version)
+	}
+	if readIndex.Entries[1].ExtendedFlags != extendedFlagSkipWorktree {
+		t.Errorf("expected extended flags %#x, got %#x", extendedFlagSkipWorktree, readIndex.Entries[1].ExtendedFlags)
+	}
+}
+
+func TestOffsetVarint(t *testing.T) {
+	for _, value := range []uint64{0, 1, 127, 128, 255, 16511, 16512, 1 << 40} {
+		encoded := encodeOffsetVarint(value)
+		decoded, err := readOffsetVarint(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("expected no error decoding %d, got %v", value, err)
+		}
+		if decoded != value {
+			t.Errorf("expected %d, got %d", value, decoded)
+		}
+	}
+}
+
+func TestIndexEntriesStaySorted(t *testing.T) {
+	index := &Index{}
+	for _, entryPath := range []string{"b", "a/z", "a.txt", "a/b", "c"} {
+		index.AddEntry(&IndexEntry{EntryPath: entryPath})
+	}
+	index.AddEntries([]*IndexEntry{
+		{EntryPath: "d"},
+		{EntryPath: "a/b", FileSize: 1},
+		{EntryPath: "a/b", FileSize: 2},
+	})
+	index.RemoveEntry("c")
+
+	expectedPaths := []string{"a.txt", "a/b", "a/z", "b", "d"}
+	if len(index.Entries) != len(expectedPaths) {
+		t.Fatalf("expected %d entries, got %d", len(expectedPaths), len(index.Entries))
+	}
+	for i, entry := range index.Entries {
+		if entry.EntryPath != expectedPaths[i] {
+			t.Errorf("expected entry %d to be %q, got %q", i, expectedPaths[i], entry.EntryPath)
+		}
+	}
+	if index.NumberOfEntries != uint32(len(expectedPaths)) {
+		t.Errorf("expected NumberOfEntries %d, got %d", len(expectedPaths), index.NumberOfEntries)
+	}
+	if entry := index.FindEntry("a/b"); entry == nil || entry.FileSize != 2 {
+		t.Errorf("expected the last batch entry for a/b to win, got %v", entry)
+	}
+	if entry := index.FindEntry("c"); entry != nil {
+		t.Errorf("expected removed entry to be missing, got %v", entry)
+	}
+}
+
+func TestReadLegacyIndex(t *testing.T) {
+	// The layout written before the index matched git's: no padding, stat data or checksum
+	var buffer bytes.Buffer
+	buffer.WriteString("DIRC")
+	binary.Write(&buffer, binary.BigEndian, []uint32{2, 2})
+	for _, entryPath := range []string{"b.txt", "a.txt"} {
+		binary.Write(&buffer, binary.BigEndian, []uint32{1700000000, 0, 0100644, uint32(len(entryPath))})
+		buffer.Write(make([]byte, 20))
+		buffer.WriteString(entryPath + "\x00")
+	}
+	repository := &Repository{WorkTree: t.TempDir(), GitDirectory: t.TempDir()}
+	indexPath := filepath.Join(repository.GitDirectory, "index")
+	if err := os.WriteFile(indexPath, buffer.Bytes(), 0644); err != nil {
+		t.Fatalf("expected no error writing index, got %v", err)
+	}
+
+	index, err := ReadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("expected the old layout to be read, got %v", err)
+	}
+	if len(index.Entries) != 2 || index.Entries[0].EntryPath != "a.txt" || index.Entries[1].FileSize != 5 {
+		t.Fatalf("expected both entries sorted, got %+v", index.Entries)
+	}
+
+	// Writing it back upgrades it to the current layout
+	if err := WriteIndex(repository, index); err != nil {
+		t.Fatalf("expected no error writing index, got %v", err)
+	}
+	if _, err := ReadIndex(indexPath); err != nil {
+		t.Fatalf("expected the rewritten index to be read, got %v", err)
+	}
+
+	// Anything else without a valid checksum is still an error
+	corrupt := append(buffer.Bytes(), 1)
+	if err := os.WriteFile(indexPath, corrupt, 0644); err != nil {
+		t.Fatalf("expected no error writing index, got %v", err)
+	}
+	if _, err := ReadIndex(indexPath); err == nil {
+		t.Errorf("expected trailing garbage to be rejected")
+	}
+}
+
+func TestVerifyPath(t *testing.T) {
+	cases := map[string]bool{
+		"README.md":            true,
+		"cmd/add.go":           true,
+		".gitignore":           true,
+		"docs/.gitgoodrc":      true,
+		"":                     false,
+		"/etc/passwd":          false,
+		"../evil":              false,
+		"a/../../evil":         false,
+		"a//b":                 false,
+		"a/./b":                false,
+		"a/":                   false,
+		".gitgood/HEAD":        false,
+		".GitGood/hooks/x":     false,
+		"sub/.git/config":      false,
+		"sub/.gitgood/objects": false,
+	}
+	for entryPath, expected := range cases {
+		if got := VerifyPath(entryPath); got != expected {
+			t.Errorf("VerifyPath(%q) = %v, expected %v", entryPath, got, expected)
+		}
+	}
+}
+
+func TestDirectoryConflict(t *testing.T) {
+	index := &Index{}
+	for _, entryPath := range []string{"a", "b/c", "b0"} {
+		index.AddEntry(&IndexEntry{EntryPath: entryPath})
+	}
+	cases := map[string]string{
+		"a/b":   "a",
+		"a/b/c": "a",
+		"b":     "b/c",
+		"b/d":   "",
+		"a0":    "",
+		"c":     "",
+	}
+	for entryPath, expected := range cases {
+		if got := index.DirectoryConflict(&IndexEntry{EntryPath: entryPath}); got != expected {
+			t.Errorf("DirectoryConflict(%q) = %q, expected %q", entryPath, got, expected)
+		}
+	}
+	stageOne := &IndexEntry{EntryPath: "a/b"}
+	stageOne.SetStage(1)
+	if got := index.DirectoryConflict(stageOne); got != "" {
+		t.Errorf("expected entries at other stages not to conflict, got %q", got)
+	}
+}
+
+func TestSmudgedEntriesNeverMatchStat(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filePath, nil, 0644); err != nil {
+		t.Fatalf("expected no error writing file, got %v", err)
+	}
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("expected no error reading file info, got %v", err)
+	}
+	// Written in the second the index was, then emptied without the mtime moving
+	smudged := &IndexEntry{ModifiedTime: fileInfo.ModTime(), FileMode: 0100644, Hash: Hash{1}}
+	index := &Index{Timestamp: fileInfo.ModTime().Add(time.Hour)}
+	if index.IsUpToDate(smudged, fileInfo) {
+		t.Errorf("expected a smudged entry to need its content checked")
+	}
+	empty := &IndexEntry{ModifiedTime: fileInfo.ModTime(), FileMode: 0100644, Hash: emptyBlobHash}
+	if !index.IsUpToDate(empty, fileInfo) {
+		t.Errorf("expected an empty file to match its stat data")
+	}
+}
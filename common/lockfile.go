@@ -0,0 +1,66 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Git's lock protocol: new contents go to <path>.lock which is created exclusively so only one
+// writer can hold it, then the lock is renamed over the real file
+type lockFile struct {
+	path     string
+	file     *os.File
+	released bool
+}
+
+func acquireLock(path string) (*lockFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create directory for '%s': %v", path, err)
+	}
+	file, err := os.OpenFile(path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("unable to create '%s.lock': file exists - another process seems to be updating it", path)
+		}
+		return nil, fmt.Errorf("unable to create '%s.lock': %v", path, err)
+	}
+	return &lockFile{path: path, file: file}, nil
+}
+
+func (lock *lockFile) write(data []byte) error {
+	_, err := lock.file.Write(data)
+	if err != nil {
+		return fmt.Errorf("error writing '%s.lock': %v", lock.path, err)
+	}
+	return nil
+}
+
+// Replace the locked file with what was written to the lock
+func (lock *lockFile) commit() error {
+	err := lock.file.Close()
+	if err != nil {
+		lock.rollback()
+		return fmt.Errorf("error writing '%s.lock': %v", lock.path, err)
+	}
+	err = os.Rename(lock.path+".lock", lock.path)
+	if err != nil {
+		lock.rollback()
+		return fmt.Errorf("error renaming '%s.lock': %v", lock.path, err)
+	}
+	lock.released = true
+	return nil
+}
+
+// Give the lock up without touching the locked file - safe to call after commit
+// since by then the lock file may already belong to another writer
+func (lock *lockFile) rollback() {
+	if lock.released {
+		return
+	}
+	lock.released = true
+	lock.file.Close()
+	os.Remove(lock.path + ".lock")
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Same header git writes - peeled lines follow every annotated tag and the refs are sorted by name
+const packedRefsHeader = "# pack-refs with: peeled fully-peeled sorted \n"
+
+func packedRefsPath(repository *Repository) string {
+	return filepath.Join(repository.GitDirectory, "packed-refs")
+}
+
+// Parse the packed-refs file: "<hash> <refname>" lines, each optionally followed by a
+// "^<hash>" line holding the object an annotated tag peels to
+func (repository *Repository) ReadPackedRefs() ([]*Ref, error) {
+	fileContent, err := os.ReadFile(packedRefsPath(repository))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading packed-refs: %v", err)
+	}
+
+	var refs []*Ref
+	scanner := bufio.NewScanner(bytes.NewReader(fileContent))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "^") {
+			if len(refs) == 0 {
+				return nil, fmt.Errorf("error reading packed-refs: peeled line %d without a ref", lineNumber)
+			}
+			peeled, err := ParseHash(line[1:])
+			if err != nil {
+				return nil, fmt.Errorf("error reading packed-refs line %d: %v", lineNumber, err)
+			}
+			refs[len(refs)-1].Peeled = peeled
+			continue
+		}
+
+		hashString, name, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("error reading packed-refs: bad line %d", lineNumber)
+		}
+		hash, err := ParseHash(hashString)
+		if err != nil {
+			return nil, fmt.Errorf("error reading packed-refs line %d: %v", lineNumber, err)
+		}
+		refs = append(refs, &Ref{Name: name, Hash: hash})
+	}
+	return refs, nil
+}
+
+// Replace packed-refs with the given refs - an empty list removes the file altogether
+// Callers that read packed-refs first should hold LockPackedRefs across both instead
+func (repository *Repository) WritePackedRefs(refs []*Ref) error {
+	lock, err := repository.LockPackedRefs()
+	if err != nil {
+		return err
+	}
+	return lock.Write(refs)
+}
+
+// Held across a read-modify-write of packed-refs so two writers can't drop each other's changes
+type PackedRefsLock struct {
+	repository *Repository
+	lock       *lockFile
+}
+
+func (repository *Repository) LockPackedRefs() (*PackedRefsLock, error) {
+	lock, err := acquireLock(packedRefsPath(repository))
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock packed-refs: %v", err)
+	}
+	return &PackedRefsLock{repository: repository, lock: lock}, nil
+}
+
+// Write the refs and release the lock - the new contents go to packed-refs.lock first so readers
+// never see a half written file
+func (packedRefsLock *PackedRefsLock) Write(refs []*Ref) error {
+	lock := packedRefsLock.lock
+	if len(refs) == 0 {
+		defer lock.rollback()
+		err := os.Remove(packedRefsPath(packedRefsLock.repository))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing packed-refs: %v", err)
+		}
+		return nil
+	}
+
+	sorted := slices.Clone(refs)
+	slices.SortFunc(sorted, func(a, b *Ref) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	var buffer bytes.Buffer
+	buffer.WriteString(packedRefsHeader)
+	for _, ref := range sorted {
+		fmt.Fprintf(&buffer, "%s %s\n", ref.Hash, ref.Name)
+		if !ref.Peeled.Empty() {
+			fmt.Fprintf(&buffer, "^%s\n", ref.Peeled)
+		}
+	}
+	err := lock.write(buffer.Bytes())
+	if err != nil {
+		lock.rollback()
+		return err
+	}
+	return lock.commit()
+}
+
+// Give the lock up without writing - safe to call after Write
+func (packedRefsLock *PackedRefsLock) Rollback() {
+	packedRefsLock.lock.rollback()
+}
@@ -0,0 +1,301 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A single change queued in a ref transaction
+type RefUpdate struct {
+	// Full ref name like refs/heads/main, symbolic refs like HEAD update the ref they point at
+	Name string
+	// An empty new hash deletes the ref
+	NewHash Hash
+	// Only checked when CheckOld is set - an empty old hash means the ref must not exist yet
+	OldHash  Hash
+	CheckOld bool
+	// Verify only checks the old value and leaves the ref alone
+	Verify  bool
+	Message string
+
+	lock         *lockFile
+	previousHash Hash
+}
+
+// Updates to several refs that either all happen or none do
+// Every ref is locked and checked against its expected old value before any of them is written
+type RefTransaction struct {
+	repository *Repository
+	updates    []*RefUpdate
+	prepared   bool
+	closed     bool
+}
+
+func (repository *Repository) NewRefTransaction() *RefTransaction {
+	return &RefTransaction{repository: repository}
+}
+
+func (transaction *RefTransaction) Add(update *RefUpdate) error {
+	if transaction.prepared || transaction.closed {
+		return fmt.Errorf("ref transaction is already %s", transaction.state())
+	}
+	transaction.updates = append(transaction.updates, update)
+	return nil
+}
+
+func (transaction *RefTransaction) state() string {
+	if transaction.closed {
+		return "closed"
+	}
+	return "prepared"
+}
+
+// Lock every ref and verify the old values - nothing is written yet
+// Locks are taken in name order so two transactions touching the same refs can't deadlock
+func (transaction *RefTransaction) Prepare() error {
+	if transaction.prepared || transaction.closed {
+		return fmt.Errorf("ref transaction is already %s", transaction.state())
+	}
+	repository := transaction.repository
+
+	for _, update := range transaction.updates {
+		name, err := repository.resolveUpdateName(update.Name)
+		if err != nil {
+			transaction.Abort()
+			return err
+		}
+		if name == "HEAD" && update.NewHash.Empty() && !update.Verify {
+			transaction.Abort()
+			return fmt.Errorf("refusing to delete a detached HEAD")
+		}
+		update.Name = name
+	}
+	slices.SortFunc(transaction.updates, func(a, b *RefUpdate) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for i := 1; i < len(transaction.updates); i++ {
+		if transaction.updates[i].Name == transaction.updates[i-1].Name {
+			transaction.Abort()
+			return fmt.Errorf("multiple updates for ref '%s' not allowed", transaction.updates[i].Name)
+		}
+	}
+	// Caught here rather than when the lock is renamed so a clash can't leave the transaction half done
+	err := repository.verifyRefNamesAvailable(transaction.updates)
+	if err != nil {
+		transaction.Abort()
+		return err
+	}
+
+	for _, update := range transaction.updates {
+		lock, err := acquireLock(filepath.Join(repository.GitDirectory, filepath.FromSlash(update.Name)))
+		if err != nil {
+			transaction.Abort()
+			return fmt.Errorf("cannot lock ref '%s': %v", update.Name, err)
+		}
+		update.lock = lock
+
+		// Nobody else can move the ref while we hold the lock so this is the value we'll replace
+		current, err := repository.ReadRef(update.Name)
+		if err != nil {
+			transaction.Abort()
+			return err
+		}
+		update.previousHash = current.Hash
+		if update.CheckOld && current.Hash != update.OldHash {
+			transaction.Abort()
+			switch {
+			case update.OldHash.Empty():
+				return fmt.Errorf("cannot lock ref '%s': reference already exists", update.Name)
+			case current.Hash.Empty():
+				return fmt.Errorf("cannot lock ref '%s': unable to resolve reference '%s'", update.Name, update.Name)
+			}
+			return fmt.Errorf("cannot lock ref '%s': is at %s but expected %s", update.Name, current.Hash, update.OldHash)
+		}
+	}
+	transaction.prepared = true
+	return nil
+}
+
+// Write every update, preparing the transaction first if that hasn't happened yet
+func (transaction *RefTransaction) Commit() error {
+	if !transaction.prepared {
+		err := transaction.Prepare()
+		if err != nil {
+			return err
+		}
+	}
+	defer transaction.Abort()
+	repository := transaction.repository
+
+	var deletedNames []string
+	for _, update := range transaction.updates {
+		switch {
+		case update.Verify:
+			continue
+		case update.NewHash.Empty():
+			deletedNames = append(deletedNames, update.Name)
+		default:
+			err := update.lock.write([]byte(update.NewHash.String() + "\n"))
+			if err == nil {
+				err = update.lock.commit()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := repository.deleteRefs(deletedNames)
+	if err != nil {
+		return err
+	}
+
+	for _, update := range transaction.updates {
+		if update.Verify {
+			continue
+		}
+		err = repository.logRefUpdate(update)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release every lock without writing anything - safe to call more than once
+func (transaction *RefTransaction) Abort() {
+	for _, update := range transaction.updates {
+		if update.lock != nil {
+			update.lock.rollback()
+		}
+	}
+	transaction.closed = true
+}
+
+// Symbolic refs are updated through the ref they point at, ie HEAD through the checked out branch
+// A detached HEAD is updated in place
+func (repository *Repository) resolveUpdateName(name string) (string, error) {
+	if name != "HEAD" && !IsValidRefName(name) {
+		return "", fmt.Errorf("refusing to update ref with bad name '%s'", name)
+	}
+	resolvedName, err := repository.ResolveRefName(name)
+	if err != nil {
+		return "", err
+	}
+	if resolvedName != "HEAD" && !IsValidRefName(resolvedName) {
+		return "", fmt.Errorf("refusing to update ref with bad name '%s'", resolvedName)
+	}
+	return resolvedName, nil
+}
+
+// Refs are files so a new ref can't go where an existing ref needs its name as a directory or below
+// a ref that's a file, and like git no two refs in one transaction may clash even when one is deleted
+// Ref https://github.com/git/git/blob/master/refs.c (refs_verify_refname_available)
+func (repository *Repository) verifyRefNamesAvailable(updates []*RefUpdate) error {
+	updateNames := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		updateNames[update.Name] = true
+	}
+	for _, update := range updates {
+		for directory := path.Dir(update.Name); directory != "."; directory = path.Dir(directory) {
+			if updateNames[directory] {
+				return fmt.Errorf("cannot lock ref '%s': cannot process '%s' and '%s' at the same time", update.Name, directory, update.Name)
+			}
+		}
+	}
+
+	refs, err := repository.ListRefs("refs/")
+	if err != nil {
+		return err
+	}
+	existingNames := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		existingNames[ref.Name] = true
+	}
+	for _, update := range updates {
+		name := update.Name
+		if update.Verify || update.NewHash.Empty() || existingNames[name] {
+			continue
+		}
+		for directory := path.Dir(name); directory != "."; directory = path.Dir(directory) {
+			if existingNames[directory] {
+				return fmt.Errorf("cannot lock ref '%s': '%s' exists; cannot create '%s'", name, directory, name)
+			}
+		}
+		for _, ref := range refs {
+			if strings.HasPrefix(ref.Name, name+"/") {
+				return fmt.Errorf("cannot lock ref '%s': '%s' exists; cannot create '%s'", name, ref.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Full ref names live under refs/ and follow the same rules as branch names
+func IsValidRefName(name string) bool {
+	return strings.HasPrefix(name, "refs/") && IsValidBranchName(strings.TrimPrefix(name, "refs/"))
+}
+
+// Rewrite packed-refs once for the whole batch and then remove the loose files - packed-refs stays
+// locked while it's rewritten and goes first so an old packed value can't show through
+func (repository *Repository) deleteRefs(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	lock, err := repository.LockPackedRefs()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	packedRefs, err := repository.ReadPackedRefs()
+	if err != nil {
+		return err
+	}
+	remainingRefs := slices.DeleteFunc(slices.Clone(packedRefs), func(ref *Ref) bool {
+		return slices.Contains(names, ref.Name)
+	})
+	if len(remainingRefs) != len(packedRefs) {
+		err = lock.Write(remainingRefs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		_, err := repository.RemoveLooseRef(name)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		err = repository.DeleteReflog(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Branches and HEAD always get a reflog entry, other refs only when they already have a log
+// Moving the checked out branch also moves HEAD so it's logged there too
+func (repository *Repository) logRefUpdate(update *RefUpdate) error {
+	if update.NewHash.Empty() {
+		return nil
+	}
+	if update.Name == "HEAD" || strings.HasPrefix(update.Name, "refs/heads/") || repository.ReflogExists(update.Name) {
+		err := repository.AppendReflog(update.Name, update.previousHash, update.NewHash, update.Message)
+		if err != nil {
+			return err
+		}
+	}
+	currentBranch, err := repository.GetBranch()
+	if err != nil {
+		return err
+	}
+	if currentBranch != "" && update.Name == "refs/heads/"+currentBranch {
+		return repository.AppendReflog("HEAD", update.previousHash, update.NewHash, update.Message)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// One line of a reflog: "<old> <new> <name> <<email>> <unix time> <zone>\t<message>"
+// Ref https://git-scm.com/docs/git-reflog
+type ReflogEntry struct {
+	OldHash   Hash
+	NewHash   Hash
+	Identity  string
+	Timestamp time.Time
+	Message   string
+}
+
+func reflogPath(repository *Repository, refName string) string {
+	return filepath.Join(repository.GitDirectory, "logs", filepath.FromSlash(refName))
+}
+
+func (entry *ReflogEntry) String() string {
+	return fmt.Sprintf("%s %s %s %d %s\t%s\n", entry.OldHash, entry.NewHash, entry.Identity,
+		entry.Timestamp.Unix(), entry.Timestamp.Format("-0700"), entry.Message)
+}
+
+// Record a ref moving from oldHash to newHash - an empty old hash means the ref was just created
+func (repository *Repository) AppendReflog(refName string, oldHash, newHash Hash, message string) error {
+	identity, err := repository.Identity()
+	if err != nil {
+		return err
+	}
+	entry := &ReflogEntry{
+		OldHash:   oldHash,
+		NewHash:   newHash,
+		Identity:  identity,
+		Timestamp: time.Now(),
+		// Everything after the tab is the message so it has to stay on one line
+		Message: strings.ReplaceAll(message, "\n", " "),
+	}
+
+	// Expiring and deleting entries rewrite the log under the same lock so an append can't land
+	// between their read and their write and get lost
+	path := reflogPath(repository, refName)
+	lock, err := acquireLock(path)
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening reflog for %s: %v", refName, err)
+	}
+	defer file.Close()
+	_, err = file.WriteString(entry.String())
+	if err != nil {
+		return fmt.Errorf("error writing reflog for %s: %v", refName, err)
+	}
+	return nil
+}
+
+// Entries oldest first the way they're stored - a missing log is just empty
+func (repository *Repository) ReadReflog(refName string) ([]*ReflogEntry, error) {
+	fileContent, err := os.ReadFile(reflogPath(repository, refName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading reflog for %s: %v", refName, err)
+	}
+
+	var entries []*ReflogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(fileContent))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if scanner.Text() == "" {
+			continue
+		}
+		entry, err := parseReflogEntry(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("error reading reflog for %s line %d: %v", refName, lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseReflogEntry(line string) (*ReflogEntry, error) {
+	header, message, _ := strings.Cut(line, "\t")
+	if len(header) < 82 || header[40] != ' ' || header[81] != ' ' {
+		return nil, fmt.Errorf("malformed reflog entry")
+	}
+	oldHash, err := ParseHash(header[:40])
+	if err != nil {
+		return nil, err
+	}
+	newHash, err := ParseHash(header[41:81])
+	if err != nil {
+		return nil, err
+	}
+
+	// The identity can contain spaces so the time and zone are taken from the end
+	emailEnd := strings.LastIndex(header, ">")
+	if emailEnd == -1 {
+		return nil, fmt.Errorf("malformed reflog identity")
+	}
+	timeFields := strings.Fields(header[emailEnd+1:])
+	if len(timeFields) != 2 {
+		return nil, fmt.Errorf("malformed reflog timestamp")
+	}
+	seconds, err := strconv.ParseInt(timeFields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed reflog timestamp: %v", err)
+	}
+	timestamp := time.Unix(seconds, 0)
+	zone, err := time.Parse("-0700", timeFields[1])
+	if err == nil {
+		timestamp = timestamp.In(zone.Location())
+	}
+
+	return &ReflogEntry{
+		OldHash:   oldHash,
+		NewHash:   newHash,
+		Identity:  header[82 : emailEnd+1],
+		Timestamp: timestamp,
+		Message:   message,
+	}, nil
+}
+
+// Replace the log with whatever rewrite keeps of its entries, used when expiring or deleting entries
+// The log stays locked from the read to the write and is left alone when nothing was dropped
+func (repository *Repository) RewriteReflog(refName string, rewrite func([]*ReflogEntry) ([]*ReflogEntry, error)) error {
+	lock, err := acquireLock(reflogPath(repository, refName))
+	if err != nil {
+		return err
+	}
+	entries, err := repository.ReadReflog(refName)
+	if err != nil {
+		lock.rollback()
+		return err
+	}
+	keptEntries, err := rewrite(entries)
+	if err != nil || len(keptEntries) == len(entries) {
+		lock.rollback()
+		return err
+	}
+
+	var buffer bytes.Buffer
+	for _, entry := range keptEntries {
+		buffer.WriteString(entry.String())
+	}
+	err = lock.write(buffer.Bytes())
+	if err != nil {
+		lock.rollback()
+		return err
+	}
+	return lock.commit()
+}
+
+func (repository *Repository) ReflogExists(refName string) bool {
+	info, err := os.Stat(reflogPath(repository, refName))
+	return err == nil && !info.IsDir()
+}
+
+// Remove a ref's log along with any directories its name left empty
+func (repository *Repository) DeleteReflog(refName string) error {
+	path := reflogPath(repository, refName)
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting reflog for %s: %v", refName, err)
+	}
+	logsDirectory := filepath.Join(repository.GitDirectory, "logs")
+	for directory := filepath.Dir(path); directory != logsDirectory; directory = filepath.Dir(directory) {
+		if os.Remove(directory) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Move a log along with its ref, ie when a branch is renamed
+func (repository *Repository) RenameReflog(oldRefName, newRefName string) error {
+	if !repository.ReflogExists(oldRefName) {
+		return nil
+	}
+	newPath := reflogPath(repository, newRefName)
+	err := os.MkdirAll(filepath.Dir(newPath), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating reflog directory: %v", err)
+	}
+	err = os.Rename(reflogPath(repository, oldRefName), newPath)
+	if err != nil {
+		return fmt.Errorf("error renaming reflog for %s: %v", oldRefName, err)
+	}
+	return repository.DeleteReflog(oldRefName)
+}
+
+// The names of every ref with a log, ie HEAD and refs/heads/main
+func (repository *Repository) ListReflogs() ([]string, error) {
+	logsDirectory := filepath.Join(repository.GitDirectory, "logs")
+	var refNames []string
+	err := filepath.WalkDir(logsDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		refName, err := filepath.Rel(logsDirectory, path)
+		if err != nil {
+			return err
+		}
+		refNames = append(refNames, filepath.ToSlash(refName))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing reflogs: %v", err)
+	}
+	return refNames, nil
+}
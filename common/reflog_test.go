@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestReflogAppendAndRead(t *testing.T) {
+	repository := createTestRefs(t)
+	firstHash := Hash{1}
+	secondHash := Hash{2}
+	if err := repository.AppendReflog("refs/heads/feature/x", Hash{}, firstHash, "branch: Created from HEAD"); err != nil {
+		t.Fatalf("expected no error appending reflog, got %v", err)
+	}
+	if err := repository.AppendReflog("refs/heads/feature/x", firstHash, secondHash, "commit: two\nbody"); err != nil {
+		t.Fatalf("expected no error appending reflog, got %v", err)
+	}
+
+	entries, err := repository.ReadReflog("refs/heads/feature/x")
+	if err != nil {
+		t.Fatalf("expected no error reading reflog, got %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 reflog entries, got %d", len(entries))
+	}
+	if !entries[0].OldHash.Empty() || entries[0].NewHash != firstHash {
+		t.Errorf("expected first entry to create the ref at %s, got %s -> %s", firstHash, entries[0].OldHash, entries[0].NewHash)
+	}
+	if entries[1].OldHash != firstHash || entries[1].NewHash != secondHash {
+		t.Errorf("expected second entry to move %s -> %s, got %s -> %s", firstHash, secondHash, entries[1].OldHash, entries[1].NewHash)
+	}
+	if entries[1].Message != "commit: two body" {
+		t.Errorf("expected message on a single line, got %q", entries[1].Message)
+	}
+
+	refNames, err := repository.ListReflogs()
+	if err != nil || len(refNames) != 1 || refNames[0] != "refs/heads/feature/x" {
+		t.Errorf("expected a single reflog for refs/heads/feature/x, got %v (error %v)", refNames, err)
+	}
+}
+
+func TestReflogAppendWaitsForRewrites(t *testing.T) {
+	repository := createTestRefs(t)
+	for i := byte(1); i <= 3; i++ {
+		if err := repository.AppendReflog("HEAD", Hash{i - 1}, Hash{i}, "commit"); err != nil {
+			t.Fatalf("expected no error appending reflog, got %v", err)
+		}
+	}
+
+	// An append racing the rewrite can't go in the file the rewrite is about to replace
+	err := repository.RewriteReflog("HEAD", func(entries []*ReflogEntry) ([]*ReflogEntry, error) {
+		if err := repository.AppendReflog("HEAD", Hash{3}, Hash{4}, "commit"); err == nil {
+			t.Errorf("expected the append to fail while the reflog is locked")
+		}
+		return entries[1:], nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error rewriting reflog, got %v", err)
+	}
+	if err := repository.AppendReflog("HEAD", Hash{3}, Hash{4}, "commit"); err != nil {
+		t.Fatalf("expected no error appending reflog, got %v", err)
+	}
+
+	entries, err := repository.ReadReflog("HEAD")
+	if err != nil || len(entries) != 3 || entries[0].NewHash != (Hash{2}) || entries[2].NewHash != (Hash{4}) {
+		t.Fatalf("expected the second to fourth entries, got %v (error %v)", entries, err)
+	}
+}
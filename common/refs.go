@@ -0,0 +1,328 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Look up a full ref name like refs/tags/v1.0 following symbolic refs to the ref that holds the hash
+// Loose ref files take priority over packed-refs and an empty ref is returned if the ref doesn't exist
+func (repository *Repository) ReadRef(name string) (*Ref, error) {
+	resolvedName, err := repository.ResolveRefName(name)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := repository.readLooseRef(resolvedName)
+	if err != nil {
+		return nil, err
+	}
+	if ref != nil {
+		return &Ref{Name: name, Hash: ref.Hash}, nil
+	}
+	packedRefs, err := repository.ReadPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+	for _, packedRef := range packedRefs {
+		if packedRef.Name == resolvedName {
+			return &Ref{Name: name, Hash: packedRef.Hash, Peeled: packedRef.Peeled}, nil
+		}
+	}
+	return &Ref{Name: name}, nil
+}
+
+// Returns nil without an error when there's no loose file for the ref
+func (repository *Repository) readLooseRef(name string) (*Ref, error) {
+	fileContent, err := os.ReadFile(filepath.Join(repository.GitDirectory, filepath.FromSlash(name)))
+	if err != nil {
+		// A directory in the way just means refs like name/x exist but name itself doesn't
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EISDIR) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading ref %s: %v", name, err)
+	}
+	content := strings.TrimSpace(string(fileContent))
+	if strings.HasPrefix(content, symbolicRefPrefix) {
+		return &Ref{Name: name, Target: strings.TrimPrefix(content, symbolicRefPrefix)}, nil
+	}
+	hash, err := ParseHash(content)
+	if err != nil {
+		return nil, fmt.Errorf("error reading ref %s: %v", name, err)
+	}
+	return &Ref{Name: name, Hash: hash}, nil
+}
+
+// Every ref stored as a file under refs/ whose full name starts with the prefix
+// Symbolic refs are returned unresolved with their target set
+func (repository *Repository) LooseRefs(prefix string) ([]*Ref, error) {
+	refsDirectory := filepath.Join(repository.GitDirectory, "refs")
+	var refs []*Ref
+	err := filepath.WalkDir(refsDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		relativePath, err := filepath.Rel(repository.GitDirectory, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relativePath)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		ref, err := repository.readLooseRef(name)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing refs: %v", err)
+	}
+	return refs, nil
+}
+
+// Loose and packed refs starting with the prefix sorted by full name
+// A ref that exists in both places is reported once with the loose value
+func (repository *Repository) ListRefs(prefix string) ([]*Ref, error) {
+	refsByName := make(map[string]*Ref)
+	packedRefs, err := repository.ReadPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range packedRefs {
+		if strings.HasPrefix(ref.Name, prefix) {
+			refsByName[ref.Name] = ref
+		}
+	}
+	looseRefs, err := repository.LooseRefs(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range looseRefs {
+		if ref.Target != "" {
+			// Listed with the hash of the ref they point at, dangling ones aren't listed at all
+			ref, err = repository.ReadRef(ref.Name)
+			if err != nil {
+				return nil, err
+			}
+			if ref.Hash.Empty() {
+				continue
+			}
+		}
+		refsByName[ref.Name] = ref
+	}
+
+	refs := make([]*Ref, 0, len(refsByName))
+	for _, ref := range refsByName {
+		refs = append(refs, ref)
+	}
+	slices.SortFunc(refs, func(a, b *Ref) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return refs, nil
+}
+
+// Remove the loose file for a ref along with any directories its name left empty
+// The top level directories like refs/heads are always kept
+func (repository *Repository) RemoveLooseRef(name string) (bool, error) {
+	refPath := filepath.Join(repository.GitDirectory, filepath.FromSlash(name))
+	err := os.Remove(refPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error deleting ref %s: %v", name, err)
+	}
+	repository.removeEmptyRefDirectories(refPath)
+	return true, nil
+}
+
+func (repository *Repository) removeEmptyRefDirectories(refPath string) {
+	refsDirectory := filepath.Join(repository.GitDirectory, "refs")
+	for directory := filepath.Dir(refPath); strings.HasPrefix(filepath.Dir(directory), refsDirectory+string(filepath.Separator)); directory = filepath.Dir(directory) {
+		if os.Remove(directory) != nil {
+			break
+		}
+	}
+}
+
+// Remove the loose file of a ref that's just been packed as long as it still holds the packed value
+// The ref is locked while it's checked so an update landing in the meantime isn't thrown away, and a
+// ref someone else has locked is left loose
+// Ref https://github.com/git/git/blob/master/refs/files-backend.c (prune_ref)
+func (repository *Repository) PruneLooseRef(packedRef *Ref) error {
+	refPath := filepath.Join(repository.GitDirectory, filepath.FromSlash(packedRef.Name))
+	lock, err := acquireLock(refPath)
+	if err != nil {
+		return nil
+	}
+	ref, err := repository.readLooseRef(packedRef.Name)
+	if err != nil || ref == nil || ref.Target != "" || ref.Hash != packedRef.Hash {
+		lock.rollback()
+		return err
+	}
+	err = os.Remove(refPath)
+	lock.rollback()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting ref %s: %v", packedRef.Name, err)
+	}
+	repository.removeEmptyRefDirectories(refPath)
+	return nil
+}
+
+// Delete a ref from both the loose refs and packed-refs
+// packed-refs is locked for the whole rewrite and goes first so the old packed value can't show
+// through once the loose file is gone
+func (repository *Repository) DeleteRef(name string) error {
+	lock, err := repository.LockPackedRefs()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	packedRefs, err := repository.ReadPackedRefs()
+	if err != nil {
+		return err
+	}
+	remainingRefs := slices.DeleteFunc(slices.Clone(packedRefs), func(ref *Ref) bool {
+		return ref.Name == name
+	})
+	removed := false
+	if len(remainingRefs) != len(packedRefs) {
+		err = lock.Write(remainingRefs)
+		if err != nil {
+			return err
+		}
+		removed = true
+	}
+
+	removedLoose, err := repository.RemoveLooseRef(name)
+	if err != nil {
+		return err
+	}
+	if !removed && !removedLoose {
+		return fmt.Errorf("error deleting ref %s: ref not found", name)
+	}
+	return nil
+}
+
+// Every branch including nested names like feature/x, sorted by name
+func (repository *Repository) ListBranches() ([]*Ref, error) {
+	refs, err := repository.ListRefs("refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		ref.Name = strings.TrimPrefix(ref.Name, "refs/heads/")
+	}
+	return refs, nil
+}
+
+func (repository *Repository) BranchExists(branch string) bool {
+	ref, err := repository.FindRef(branch)
+	return err == nil && !ref.Hash.Empty()
+}
+
+// A deleted branch takes its reflog with it
+func (repository *Repository) DeleteBranch(branch string) error {
+	err := repository.DeleteRef("refs/heads/" + branch)
+	if err != nil {
+		return err
+	}
+	return repository.DeleteReflog("refs/heads/" + branch)
+}
+
+// Point HEAD at a branch - the branch doesn't need to exist yet
+func (repository *Repository) SetHeadBranch(branch string) error {
+	return repository.WriteSymbolicRef("HEAD", "refs/heads/"+branch)
+}
+
+// Simplified version of git check-ref-format for branch names
+func IsValidBranchName(branch string) bool {
+	if branch == "" || branch == "HEAD" || strings.HasPrefix(branch, "-") {
+		return false
+	}
+	if strings.ContainsAny(branch, " ~^:?*[\\\x7f") || strings.Contains(branch, "..") || strings.Contains(branch, "@{") {
+		return false
+	}
+	for _, component := range strings.Split(branch, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	for _, character := range branch {
+		if character < 0x20 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(branch, ".")
+}
+
+// Detach HEAD by pointing it straight at a commit
+func (repository *Repository) SetHeadDetached(hash Hash) error {
+	lock, err := acquireLock(filepath.Join(repository.GitDirectory, "HEAD"))
+	if err != nil {
+		return fmt.Errorf("cannot lock ref 'HEAD': %v", err)
+	}
+	err = lock.write([]byte(hash.String() + "\n"))
+	if err != nil {
+		lock.rollback()
+		return err
+	}
+	return lock.commit()
+}
+
+// The commit HEAD points at either through the current branch or directly when detached
+// Returns an empty hash if the current branch doesn't have any commits yet
+func (repository *Repository) ResolveHead() (Hash, error) {
+	ref, err := repository.ReadRef("HEAD")
+	if err != nil {
+		return Hash{}, err
+	}
+	return ref.Hash, nil
+}
+
+// Move a branch from oldHash (empty when the branch is new) to newHash and record it in the reflogs
+// Fails without touching the branch if somebody else moved it first
+func (repository *Repository) UpdateBranch(branch string, oldHash, newHash Hash, message string) error {
+	transaction := repository.NewRefTransaction()
+	err := transaction.Add(&RefUpdate{
+		Name:     "refs/heads/" + branch,
+		NewHash:  newHash,
+		OldHash:  oldHash,
+		CheckOld: true,
+		Message:  message,
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+// The ref a branch merges from according to branch.<name>.remote and branch.<name>.merge
+// Returns an empty string when the branch has no upstream configured
+func (repository *Repository) BranchUpstream(branch string) (string, error) {
+	config, err := repository.Config()
+	if err != nil {
+		return "", err
+	}
+	remote, hasRemote := config.Get("branch." + branch + ".remote")
+	merge, hasMerge := config.Get("branch." + branch + ".merge")
+	if !hasRemote || !hasMerge {
+		return "", nil
+	}
+	// A remote of "." means the upstream is another local branch
+	if remote == "." {
+		return merge, nil
+	}
+	return "refs/remotes/" + remote + "/" + strings.TrimPrefix(merge, "refs/heads/"), nil
+}
@@ -0,0 +1,245 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createTestRefs(t *testing.T) *Repository {
+	t.Helper()
+	repository := &Repository{
+		WorkTree:     t.TempDir(),
+		GitDirectory: t.TempDir(),
+	}
+	if err := os.MkdirAll(filepath.Join(repository.GitDirectory, "refs", "heads"), 0755); err != nil {
+		t.Fatalf("expected no error creating refs directory, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repository.GitDirectory, "HEAD"), []byte("ref: refs/heads/main\n"), 0644); err != nil {
+		t.Fatalf("expected no error writing HEAD, got %v", err)
+	}
+	return repository
+}
+
+func TestPackedRefsRoundTrip(t *testing.T) {
+	repository := createTestRefs(t)
+	tagHash := Hash{1}
+	commitHash := Hash{2}
+	refs := []*Ref{
+		{Name: "refs/tags/v1.0", Hash: tagHash, Peeled: commitHash},
+		{Name: "refs/heads/main", Hash: commitHash},
+	}
+	if err := repository.WritePackedRefs(refs); err != nil {
+		t.Fatalf("expected no error writing packed-refs, got %v", err)
+	}
+
+	readRefs, err := repository.ReadPackedRefs()
+	if err != nil {
+		t.Fatalf("expected no error reading packed-refs, got %v", err)
+	}
+	if len(readRefs) != 2 {
+		t.Fatalf("expected 2 packed refs, got %d", len(readRefs))
+	}
+	if readRefs[0].Name != "refs/heads/main" || readRefs[1].Name != "refs/tags/v1.0" {
+		t.Errorf("expected packed refs sorted by name, got %s and %s", readRefs[0].Name, readRefs[1].Name)
+	}
+	if readRefs[1].Hash != tagHash || readRefs[1].Peeled != commitHash {
+		t.Errorf("expected tag %s peeled to %s, got %s peeled to %s", tagHash, commitHash, readRefs[1].Hash, readRefs[1].Peeled)
+	}
+	if !readRefs[0].Peeled.Empty() {
+		t.Errorf("expected branch to have no peeled hash, got %s", readRefs[0].Peeled)
+	}
+}
+
+func TestLooseRefsOverridePackedRefs(t *testing.T) {
+	repository := createTestRefs(t)
+	packedHash := Hash{1}
+	looseHash := Hash{2}
+	if err := repository.WritePackedRefs([]*Ref{{Name: "refs/heads/main", Hash: packedHash}}); err != nil {
+		t.Fatalf("expected no error writing packed-refs, got %v", err)
+	}
+
+	ref, err := repository.FindRef("main")
+	if err != nil || ref.Hash != packedHash {
+		t.Fatalf("expected packed hash %s, got %v (error %v)", packedHash, ref, err)
+	}
+	if err := repository.WriteRef(&Ref{Name: "main", Hash: looseHash}, "main"); err != nil {
+		t.Fatalf("expected no error writing ref, got %v", err)
+	}
+	ref, err = repository.FindRef("main")
+	if err != nil || ref.Hash != looseHash {
+		t.Fatalf("expected loose hash %s, got %v (error %v)", looseHash, ref, err)
+	}
+	branches, err := repository.ListBranches()
+	if err != nil || len(branches) != 1 || branches[0].Hash != looseHash {
+		t.Fatalf("expected a single branch at the loose hash, got %v (error %v)", branches, err)
+	}
+
+	// Deleting has to remove the ref from both places or the packed value would resurface
+	if err := repository.DeleteBranch("main"); err != nil {
+		t.Fatalf("expected no error deleting branch, got %v", err)
+	}
+	if repository.BranchExists("main") {
+		t.Errorf("expected main to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(repository.GitDirectory, "packed-refs")); !os.IsNotExist(err) {
+		t.Errorf("expected empty packed-refs to be removed, got %v", err)
+	}
+}
+
+func TestRefTransactionIsAtomic(t *testing.T) {
+	repository := createTestRefs(t)
+	firstHash := Hash{1}
+	secondHash := Hash{2}
+	if err := repository.UpdateBranch("main", Hash{}, firstHash, "branch: Created from HEAD"); err != nil {
+		t.Fatalf("expected no error creating branch, got %v", err)
+	}
+
+	// The stale old value for main has to stop the creation of other as well
+	transaction := repository.NewRefTransaction()
+	transaction.Add(&RefUpdate{Name: "refs/heads/other", NewHash: secondHash, CheckOld: true})
+	transaction.Add(&RefUpdate{Name: "refs/heads/main", NewHash: secondHash, OldHash: secondHash, CheckOld: true})
+	if err := transaction.Commit(); err == nil {
+		t.Fatalf("expected the stale old value to fail the transaction")
+	}
+	if repository.BranchExists("other") {
+		t.Errorf("expected other not to be created by a failed transaction")
+	}
+	if ref, _ := repository.FindRef("main"); ref.Hash != firstHash {
+		t.Errorf("expected main to stay at %s, got %s", firstHash, ref.Hash)
+	}
+	if _, err := os.Stat(filepath.Join(repository.GitDirectory, "refs", "heads", "main.lock")); !os.IsNotExist(err) {
+		t.Errorf("expected the lock on main to be released, got %v", err)
+	}
+
+	if err := repository.UpdateBranch("main", firstHash, secondHash, "commit: second"); err != nil {
+		t.Fatalf("expected no error moving main from its current value, got %v", err)
+	}
+	if err := repository.UpdateBranch("main", firstHash, secondHash, "commit: second"); err == nil {
+		t.Errorf("expected moving main from a stale value to fail")
+	}
+}
+
+func TestRefTransactionChecksDirectoryFileConflictsUpFront(t *testing.T) {
+	repository := createTestRefs(t)
+	hash := Hash{1}
+	if err := repository.UpdateBranch("a/b", Hash{}, hash, "branch: Created from HEAD"); err != nil {
+		t.Fatalf("expected no error creating a/b, got %v", err)
+	}
+	if err := repository.WritePackedRefs([]*Ref{{Name: "refs/heads/c", Hash: hash}}); err != nil {
+		t.Fatalf("expected no error writing packed-refs, got %v", err)
+	}
+
+	// "first" sorts ahead of the clashing refs so it would be written before the rename failed
+	for _, clashing := range [][]string{{"refs/heads/a"}, {"refs/heads/c/d"}, {"refs/heads/e", "refs/heads/e/f"}} {
+		transaction := repository.NewRefTransaction()
+		transaction.Add(&RefUpdate{Name: "refs/heads/first", NewHash: hash})
+		for _, name := range clashing {
+			transaction.Add(&RefUpdate{Name: name, NewHash: hash})
+		}
+		if err := transaction.Commit(); err == nil {
+			t.Fatalf("expected %v to conflict", clashing)
+		}
+		if repository.BranchExists("first") {
+			t.Fatalf("expected nothing written when %v conflicts", clashing)
+		}
+	}
+
+	// Like git a ref can't be deleted to make room for another in the same transaction
+	transaction := repository.NewRefTransaction()
+	transaction.Add(&RefUpdate{Name: "refs/heads/a/b", CheckOld: true, OldHash: hash})
+	transaction.Add(&RefUpdate{Name: "refs/heads/a", NewHash: hash})
+	if err := transaction.Commit(); err == nil {
+		t.Fatalf("expected deleting a/b and creating a together to conflict")
+	}
+	if !repository.BranchExists("a/b") {
+		t.Errorf("expected a/b to be left alone")
+	}
+}
+
+func TestPruneLooseRefKeepsRefsThatMoved(t *testing.T) {
+	repository := createTestRefs(t)
+	packedHash := Hash{1}
+	for _, branch := range []string{"kept", "moved"} {
+		if err := repository.UpdateBranch(branch, Hash{}, packedHash, "branch: Created from HEAD"); err != nil {
+			t.Fatalf("expected no error creating %s, got %v", branch, err)
+		}
+	}
+	// A commit landing on moved after it was packed
+	if err := repository.UpdateBranch("moved", packedHash, Hash{2}, "commit: second"); err != nil {
+		t.Fatalf("expected no error moving the branch, got %v", err)
+	}
+
+	for _, branch := range []string{"kept", "moved"} {
+		if err := repository.PruneLooseRef(&Ref{Name: "refs/heads/" + branch, Hash: packedHash}); err != nil {
+			t.Fatalf("expected no error pruning %s, got %v", branch, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repository.GitDirectory, "refs", "heads", "kept")); !os.IsNotExist(err) {
+		t.Errorf("expected the loose file of kept to be pruned, got %v", err)
+	}
+	if ref, err := repository.ReadRef("refs/heads/moved"); err != nil || ref.Hash != (Hash{2}) {
+		t.Errorf("expected moved to keep its new value, got %v %v", ref, err)
+	}
+}
+
+func TestDeleteRefHoldsThePackedRefsLock(t *testing.T) {
+	repository := createTestRefs(t)
+	hash := Hash{1}
+	if err := repository.WritePackedRefs([]*Ref{{Name: "refs/heads/main", Hash: hash}}); err != nil {
+		t.Fatalf("expected no error writing packed-refs, got %v", err)
+	}
+	if err := repository.UpdateBranch("main", hash, Hash{2}, "commit: second"); err != nil {
+		t.Fatalf("expected no error moving main, got %v", err)
+	}
+
+	lock, err := repository.LockPackedRefs()
+	if err != nil {
+		t.Fatalf("expected no error locking packed-refs, got %v", err)
+	}
+	if err := repository.DeleteRef("refs/heads/main"); err == nil {
+		t.Fatalf("expected deleting while packed-refs is locked to fail")
+	}
+	lock.Rollback()
+	// Nothing may be removed when packed-refs can't be rewritten or the packed value would show
+	if ref, err := repository.ReadRef("refs/heads/main"); err != nil || ref.Hash != (Hash{2}) {
+		t.Fatalf("expected main to be left at its loose value, got %v %v", ref, err)
+	}
+
+	if err := repository.DeleteRef("refs/heads/main"); err != nil {
+		t.Fatalf("expected no error deleting main, got %v", err)
+	}
+	if ref, err := repository.ReadRef("refs/heads/main"); err != nil || !ref.Hash.Empty() {
+		t.Fatalf("expected main to be gone, got %v %v", ref, err)
+	}
+}
+
+func TestSymbolicRefs(t *testing.T) {
+	repository := createTestRefs(t)
+	hash := Hash{1}
+	if err := repository.UpdateBranch("main", Hash{}, hash, "branch: Created from HEAD"); err != nil {
+		t.Fatalf("expected no error creating branch, got %v", err)
+	}
+	if err := repository.WriteSymbolicRef("refs/remotes/origin/HEAD", "HEAD"); err != nil {
+		t.Fatalf("expected no error writing symbolic ref, got %v", err)
+	}
+
+	ref, err := repository.ReadRef("refs/remotes/origin/HEAD")
+	if err != nil || ref.Hash != hash {
+		t.Fatalf("expected symbolic ref chain to resolve to %s, got %v (error %v)", hash, ref, err)
+	}
+	resolvedName, err := repository.ResolveRefName("refs/remotes/origin/HEAD")
+	if err != nil || resolvedName != "refs/heads/main" {
+		t.Errorf("expected chain to end at refs/heads/main, got %q (error %v)", resolvedName, err)
+	}
+	if branch, err := repository.GetBranch(); err != nil || branch != "main" {
+		t.Errorf("expected current branch main, got %q (error %v)", branch, err)
+	}
+
+	// A cycle has to fail instead of looping forever
+	repository.WriteSymbolicRef("refs/a", "refs/b")
+	repository.WriteSymbolicRef("refs/b", "refs/a")
+	if _, err := repository.ReadRef("refs/a"); err == nil {
+		t.Errorf("expected an error resolving a symbolic ref cycle")
+	}
+}
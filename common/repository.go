@@ -3,7 +3,6 @@ package common
 import (
 	"bytes"
 	"compress/zlib"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -20,6 +19,10 @@ type Repository struct {
 type Ref struct {
 	Name string
 	Hash Hash
+	// Only set for annotated tags read from packed-refs: the object the tag ultimately points at
+	Peeled Hash
+	// Only set for symbolic refs read without resolving them: the name of the ref this one points at
+	Target string
 }
 
 func CreateRepository(path string) (*Repository, error) {
@@ -166,63 +169,69 @@ func (repository *Repository) ReadObject(objectHash string) ([]byte, error) {
 	return decompressedData.Bytes(), nil
 }
 
-// Initial implementation will just support main branch
-// We'll read the HEAD file to find the current branch then use that to search
-// the .gitgood/refs/heads/ directory to find a ref if it exists, otherwise create one
-func (repository *Repository) FindRef(branch string) (*Ref, error) {
-	refPath := filepath.Join(repository.GitDirectory, "refs", "heads", branch)
-	refInfo, err := os.ReadFile(refPath)
+// Every object whose hash starts with the hex prefix, used to expand abbreviated hashes
+func (repository *Repository) FindObjects(prefix string) ([]Hash, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 2 {
+		return nil, fmt.Errorf("object prefix %s is too short", prefix)
+	}
+	objectDirectory := filepath.Join(repository.GitDirectory, "objects", prefix[:2])
+	files, err := os.ReadDir(objectDirectory)
 	if err != nil {
-		// Return an empty ref if one does't exist
 		if errors.Is(err, os.ErrNotExist) {
-			return &Ref{
-				Name: branch,
-				Hash: Hash{},
-			}, nil
+			return nil, nil
 		}
-		return nil, err
+		return nil, fmt.Errorf("error reading object directory: %v", err)
 	}
 
-	hashString := strings.TrimSpace(string(refInfo))
-	if len(hashString) != 40 {
-		return nil, fmt.Errorf("invalid hash length reading ref %v", branch)
+	var hashes []Hash
+	for _, file := range files {
+		objectHash := prefix[:2] + file.Name()
+		if !strings.HasPrefix(objectHash, prefix) {
+			continue
+		}
+		hash, err := ParseHash(objectHash)
+		if err != nil {
+			// Not an object file, ie a leftover temporary file
+			continue
+		}
+		hashes = append(hashes, hash)
 	}
-	hashBytes, err := hex.DecodeString(hashString)
+	return hashes, nil
+}
+
+// Look up a branch by its short name - an empty ref is returned if the branch doesn't have any commits yet
+func (repository *Repository) FindRef(branch string) (*Ref, error) {
+	ref, err := repository.ReadRef("refs/heads/" + branch)
 	if err != nil {
 		return nil, err
 	}
-	hash := [20]byte(hashBytes)
-	return &Ref{
-		Name: branch,
-		Hash: hash,
-	}, nil
+	ref.Name = branch
+	return ref, nil
 }
 
+// Point a branch at a hash without checking its old value - see UpdateBranch for the checked version
 func (repository *Repository) WriteRef(ref *Ref, branch string) error {
-	refPath := filepath.Join(repository.GitDirectory, "refs", "heads", branch)
-	file, err := os.Create(refPath)
+	lock, err := acquireLock(filepath.Join(repository.GitDirectory, "refs", "heads", branch))
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot lock ref 'refs/heads/%s': %v", branch, err)
 	}
-	_, err = file.Write([]byte(ref.Hash.String() + "\n"))
+	err = lock.write([]byte(ref.Hash.String() + "\n"))
 	if err != nil {
+		lock.rollback()
 		return err
 	}
-	return nil
+	return lock.commit()
 }
 
-// This won't support a detached HEAD yet - that would just contain a commit SHA
-// Until branching is implemented this should always read the default branch from HEAD which is main
+// Returns an empty branch when HEAD is detached - HEAD then holds a commit SHA instead of a ref
 func (repository *Repository) GetBranch() (string, error) {
-	path := filepath.Join(repository.GitDirectory, "HEAD")
-	fileInfo, err := os.ReadFile(path)
+	target, err := repository.ReadSymbolicRef("HEAD")
 	if err != nil {
 		return "", err
 	}
-	content := strings.TrimSpace(string(fileInfo))
-	if strings.HasPrefix(content, "ref: refs/heads/") {
-		branch := strings.TrimPrefix(content, "ref: refs/heads/")
-		return branch, nil
+	if !strings.HasPrefix(target, "refs/heads/") {
+		return "", nil
 	}
-	return "", nil
+	return strings.TrimPrefix(target, "refs/heads/"), nil
 }
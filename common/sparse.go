@@ -0,0 +1,145 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Cone mode sparse checkout: a set of directories included recursively
+// Files in the root directory and directly inside the parents of included directories are always included
+type SparseCheckout struct {
+	Directories []string
+}
+
+func sparseCheckoutPath(repository *Repository) string {
+	return filepath.Join(repository.GitDirectory, "info", "sparse-checkout")
+}
+
+func (repository *Repository) SparseCheckoutEnabled() (bool, error) {
+	config, err := repository.Config()
+	if err != nil {
+		return false, err
+	}
+	return config.GetBool("core.sparseCheckout", false), nil
+}
+
+// Returns nil when sparse checkout is disabled so callers can treat every path as included
+func (repository *Repository) ActiveSparseCheckout() (*SparseCheckout, error) {
+	enabled, err := repository.SparseCheckoutEnabled()
+	if err != nil || !enabled {
+		return nil, err
+	}
+	return ReadSparseCheckout(repository)
+}
+
+// Parse the cone patterns git writes - included directories are the "/dir/" lines that aren't
+// followed by a "!/dir/*/" line excluding their subdirectories
+func ReadSparseCheckout(repository *Repository) (*SparseCheckout, error) {
+	fileContent, err := os.ReadFile(sparseCheckoutPath(repository))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &SparseCheckout{}, nil
+		}
+		return nil, fmt.Errorf("error reading sparse-checkout file: %v", err)
+	}
+
+	var included []string
+	excluded := make(map[string]bool)
+	for _, line := range strings.Split(string(fileContent), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "/*" || line == "!/*/" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			excluded[strings.TrimSuffix(strings.Trim(line[1:], "/"), "/*")] = true
+			continue
+		}
+		included = append(included, strings.Trim(line, "/"))
+	}
+
+	sparse := &SparseCheckout{}
+	for _, directory := range included {
+		if !excluded[directory] {
+			sparse.Directories = append(sparse.Directories, directory)
+		}
+	}
+	return sparse, nil
+}
+
+func WriteSparseCheckout(repository *Repository, sparse *SparseCheckout) error {
+	directories := NormalizeSparseDirectories(sparse.Directories)
+
+	// Every parent of an included directory needs its files included without its other subdirectories
+	parents := make(map[string]bool)
+	for _, directory := range directories {
+		for parent := filepath.Dir(directory); parent != "."; parent = filepath.Dir(parent) {
+			parents[parent] = true
+		}
+	}
+	sortedParents := make([]string, 0, len(parents))
+	for parent := range parents {
+		sortedParents = append(sortedParents, parent)
+	}
+	slices.Sort(sortedParents)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("/*\n!/*/\n")
+	for _, parent := range sortedParents {
+		fmt.Fprintf(&buffer, "/%s/\n!/%s/*/\n", parent, parent)
+	}
+	for _, directory := range directories {
+		fmt.Fprintf(&buffer, "/%s/\n", directory)
+	}
+
+	err := os.MkdirAll(filepath.Dir(sparseCheckoutPath(repository)), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating info directory: %v", err)
+	}
+	err = os.WriteFile(sparseCheckoutPath(repository), buffer.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing sparse-checkout file: %v", err)
+	}
+	return nil
+}
+
+// Clean up user supplied directories: no surrounding slashes, no duplicates,
+// and nothing that's already covered by an included parent
+func NormalizeSparseDirectories(directories []string) []string {
+	var cleaned []string
+	for _, directory := range directories {
+		directory = strings.Trim(filepath.ToSlash(filepath.Clean(directory)), "/")
+		if directory == "" || directory == "." {
+			continue
+		}
+		cleaned = append(cleaned, directory)
+	}
+	slices.Sort(cleaned)
+	cleaned = slices.Compact(cleaned)
+
+	var normalized []string
+	for _, directory := range cleaned {
+		if len(normalized) > 0 && strings.HasPrefix(directory, normalized[len(normalized)-1]+"/") {
+			continue
+		}
+		normalized = append(normalized, directory)
+	}
+	return normalized
+}
+
+func (sparse *SparseCheckout) Includes(entryPath string) bool {
+	directory := filepath.Dir(entryPath)
+	if directory == "." {
+		return true
+	}
+	for _, included := range sparse.Directories {
+		if strings.HasPrefix(entryPath, included+"/") || strings.HasPrefix(included, directory+"/") {
+			return true
+		}
+	}
+	return false
+}
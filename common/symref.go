@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const symbolicRefPrefix = "ref: "
+
+// Same limit git uses so a cycle of symbolic refs can't loop forever
+const maxSymbolicRefDepth = 5
+
+// The ref a symbolic ref points at directly, or an empty string if the ref isn't symbolic
+func (repository *Repository) ReadSymbolicRef(name string) (string, error) {
+	ref, err := repository.readLooseRef(name)
+	if err != nil || ref == nil {
+		return "", err
+	}
+	return ref.Target, nil
+}
+
+// Follow symbolic refs until reaching a ref that holds a hash (or doesn't exist yet),
+// ie HEAD -> refs/heads/main or refs/remotes/origin/HEAD -> refs/remotes/origin/main
+func (repository *Repository) ResolveRefName(name string) (string, error) {
+	for depth := 0; depth <= maxSymbolicRefDepth; depth++ {
+		target, err := repository.ReadSymbolicRef(name)
+		if err != nil {
+			return "", err
+		}
+		if target == "" {
+			return name, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("ref %s: too many levels of symbolic refs", name)
+}
+
+func (repository *Repository) WriteSymbolicRef(name, target string) error {
+	lock, err := acquireLock(filepath.Join(repository.GitDirectory, filepath.FromSlash(name)))
+	if err != nil {
+		return fmt.Errorf("cannot lock ref '%s': %v", name, err)
+	}
+	err = lock.write([]byte(symbolicRefPrefix + target + "\n"))
+	if err != nil {
+		lock.rollback()
+		return err
+	}
+	return lock.commit()
+}
+
+// Remove a symbolic ref itself, the ref it points at is left alone
+func (repository *Repository) DeleteSymbolicRef(name string) error {
+	target, err := repository.ReadSymbolicRef(name)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return fmt.Errorf("cannot delete %s, not a symbolic ref", name)
+	}
+	_, err = repository.RemoveLooseRef(name)
+	return err
+}
+
+// The name git shows for a ref, ie main for refs/heads/main and origin/main for refs/remotes/origin/main
+func ShortRefName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return strings.TrimPrefix(name, "refs/")
+}
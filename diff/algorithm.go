@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Which algorithm lines up the two sides - they all find a valid edit script but differ in which
+// lines they prefer to keep when there's more than one way to do it
+type Algorithm int
+
+const (
+	MyersAlgorithm Algorithm = iota
+	// Myers without the shortcuts that trade a slightly longer edit script for speed
+	MinimalAlgorithm
+	PatienceAlgorithm
+	HistogramAlgorithm
+)
+
+var algorithmNames = []string{"myers", "minimal", "patience", "histogram"}
+
+// Names are the ones git accepts for --diff-algorithm and diff.algorithm, case insensitively
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for i, algorithmName := range algorithmNames {
+		if strings.EqualFold(name, algorithmName) {
+			return Algorithm(i), nil
+		}
+	}
+	// git's default is called both names
+	if strings.EqualFold(name, "default") {
+		return MyersAlgorithm, nil
+	}
+	return MyersAlgorithm, fmt.Errorf("unknown diff algorithm: %s", name)
+}
+
+func (algorithm Algorithm) String() string {
+	return algorithmNames[algorithm]
+}
+
+// The edit script turning oldLines into newLines
+func (algorithm Algorithm) Diff(oldLines, newLines []string) []Edit {
+	switch algorithm {
+	case MinimalAlgorithm:
+		return myers(oldLines, newLines, true)
+	case PatienceAlgorithm:
+		return Patience(oldLines, newLines)
+	case HistogramAlgorithm:
+		return Histogram(oldLines, newLines)
+	}
+	return Myers(oldLines, newLines)
+}
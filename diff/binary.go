@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// One half of a "GIT binary patch": the whole new content or a delta against the old content
+type BinaryHunk struct {
+	Delta bool
+	// Size of the data once inflated
+	Size int
+	Data []byte
+}
+
+// Git's base85 alphabet, which leaves out characters that cause trouble in email
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+// The forward hunk and then optionally the reverse one, each ending at an empty line
+func parseBinaryPatch(lines []string, i int, patch *FilePatch) (int, error) {
+	hunk, i, err := parseBinaryHunk(lines, i)
+	if err != nil {
+		return i, err
+	}
+	patch.Binary = hunk
+	if i < len(lines) && (strings.HasPrefix(lines[i], "literal ") || strings.HasPrefix(lines[i], "delta ")) {
+		patch.ReverseBinary, i, err = parseBinaryHunk(lines, i)
+	}
+	return i, err
+}
+
+// "literal <size>" or "delta <size>" and then base85 lines of deflated data, each starting with
+// a letter for how many bytes it holds: A-Z for 1-26 and a-z for 27-52
+// Ref https://github.com/git/git/blob/master/apply.c (parse_binary_hunk)
+func parseBinaryHunk(lines []string, i int) (*BinaryHunk, int, error) {
+	if i >= len(lines) {
+		return nil, i, fmt.Errorf("unrecognized binary patch at line %d", i+1)
+	}
+	kind, sizeText, _ := strings.Cut(strings.TrimSuffix(lines[i], "\n"), " ")
+	size, err := strconv.Atoi(sizeText)
+	if (kind != "literal" && kind != "delta") || err != nil {
+		return nil, i, fmt.Errorf("unrecognized binary patch at line %d", i+1)
+	}
+
+	var deflated []byte
+	for i++; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\n")
+		if line == "" {
+			i++
+			break
+		}
+		length := 0
+		switch c := line[0]; {
+		case 'A' <= c && c <= 'Z':
+			length = int(c-'A') + 1
+		case 'a' <= c && c <= 'z':
+			length = int(c-'a') + 27
+		}
+		decoded, err := decodeBase85(line[1:], length)
+		if length == 0 || err != nil {
+			return nil, i, fmt.Errorf("corrupt binary patch at line %d: %s", i+1, line)
+		}
+		deflated = append(deflated, decoded...)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(deflated))
+	if err != nil {
+		return nil, i, fmt.Errorf("corrupt binary patch at line %d", i)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil || len(data) != size {
+		return nil, i, fmt.Errorf("corrupt binary patch at line %d", i)
+	}
+	return &BinaryHunk{Delta: kind == "delta", Size: size, Data: data}, i, nil
+}
+
+// Every 5 characters are a big endian 32 bit number holding 4 bytes
+func decodeBase85(text string, length int) ([]byte, error) {
+	if len(text) != (length+3)/4*5 {
+		return nil, fmt.Errorf("bad base85 line length")
+	}
+	decoded := make([]byte, 0, len(text)/5*4)
+	for start := 0; start < len(text); start += 5 {
+		var value uint64
+		for _, c := range []byte(text[start : start+5]) {
+			digit := strings.IndexByte(base85Alphabet, c)
+			if digit == -1 {
+				return nil, fmt.Errorf("invalid base85 character %q", c)
+			}
+			value = value*85 + uint64(digit)
+		}
+		if value > 0xffffffff {
+			return nil, fmt.Errorf("base85 overflow")
+		}
+		decoded = append(decoded, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+	}
+	return decoded[:length], nil
+}
+
+// A "literal" hunk holding the whole of data for a "GIT binary patch", ending with its empty line
+// Ref https://github.com/git/git/blob/master/diff.c (emit_binary_diff_body)
+func EncodeBinaryLiteral(data []byte) string {
+	// Git deflates these for speed rather than size
+	var deflated bytes.Buffer
+	writer, _ := zlib.NewWriterLevel(&deflated, zlib.BestSpeed)
+	writer.Write(data)
+	writer.Close()
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "literal %d\n", len(data))
+	for chunk := deflated.Bytes(); len(chunk) > 0; {
+		length := min(len(chunk), 52)
+		if length <= 26 {
+			builder.WriteByte(byte('A' + length - 1))
+		} else {
+			builder.WriteByte(byte('a' + length - 27))
+		}
+		builder.WriteString(encodeBase85(chunk[:length]))
+		builder.WriteByte('\n')
+		chunk = chunk[length:]
+	}
+	builder.WriteByte('\n')
+	return builder.String()
+}
+
+// Zero padded out to a multiple of 4 bytes
+func encodeBase85(data []byte) string {
+	encoded := make([]byte, 0, (len(data)+3)/4*5)
+	for start := 0; start < len(data); start += 4 {
+		var value uint32
+		for i := 0; i < 4; i++ {
+			value <<= 8
+			if start+i < len(data) {
+				value |= uint32(data[start+i])
+			}
+		}
+		var group [5]byte
+		for i := 4; i >= 0; i-- {
+			group[i] = base85Alphabet[value%85]
+			value /= 85
+		}
+		encoded = append(encoded, group[:]...)
+	}
+	return string(encoded)
+}
+
+// The new content from the old
+func (hunk *BinaryHunk) Apply(old []byte) ([]byte, error) {
+	if !hunk.Delta {
+		return hunk.Data, nil
+	}
+	return applyDelta(old, hunk.Data)
+}
+
+// Git's delta format: the source and result sizes as varints, then instructions that either copy a
+// range of the source or insert the bytes that follow them
+// Ref https://github.com/git/git/blob/master/patch-delta.c
+func applyDelta(source, delta []byte) ([]byte, error) {
+	errCorrupt := fmt.Errorf("corrupt delta")
+	varint := func() (int, bool) {
+		value, shift := 0, 0
+		for len(delta) > 0 {
+			c := delta[0]
+			delta = delta[1:]
+			value |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return value, true
+			}
+		}
+		return 0, false
+	}
+	sourceSize, ok := varint()
+	if !ok || sourceSize != len(source) {
+		return nil, errCorrupt
+	}
+	resultSize, ok := varint()
+	if !ok {
+		return nil, errCorrupt
+	}
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		command := delta[0]
+		delta = delta[1:]
+		switch {
+		case command&0x80 != 0:
+			// Which offset and size bytes follow is given by the low bits
+			offset, size := 0, 0
+			for bit := 0; bit < 7; bit++ {
+				if command&(1<<bit) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errCorrupt
+				}
+				if bit < 4 {
+					offset |= int(delta[0]) << (8 * bit)
+				} else {
+					size |= int(delta[0]) << (8 * (bit - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(source) {
+				return nil, errCorrupt
+			}
+			result = append(result, source[offset:offset+size]...)
+		case command != 0:
+			if int(command) > len(delta) {
+				return nil, errCorrupt
+			}
+			result = append(result, delta[:command]...)
+			delta = delta[command:]
+		default:
+			return nil, errCorrupt
+		}
+	}
+	if len(result) != resultSize {
+		return nil, errCorrupt
+	}
+	return result, nil
+}
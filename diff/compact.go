@@ -0,0 +1,305 @@
+package diff
+
+// A run of changes can often sit in more than one place, ie deleting one of two identical blank lines
+// Like git, every run is slid as far as it goes, lined up with a change on the other side when there is
+// one and otherwise placed where the indentation suggests a natural boundary
+// Ref https://github.com/git/git/blob/master/xdiff/xdiffi.c
+
+// A run of changed lines [start, end) on one side - empty runs mark where the other side changed
+type changeGroup struct {
+	start int
+	end   int
+}
+
+// One side of the diff as seen by the compaction pass
+type compactSide struct {
+	lines   []string
+	changed []bool
+	// Without it runs are left as far down as they slide
+	indentHeuristic bool
+}
+
+func (side *compactSide) isChanged(line int) bool {
+	return line >= 0 && line < len(side.changed) && side.changed[line]
+}
+
+func (side *compactSide) firstGroup() changeGroup {
+	group := changeGroup{}
+	for side.isChanged(group.end) {
+		group.end++
+	}
+	return group
+}
+
+// Unchanged lines pair up between the sides so the nth group on one side matches the nth on the other
+func (side *compactSide) nextGroup(group *changeGroup) bool {
+	if group.end == len(side.changed) {
+		return false
+	}
+	group.start = group.end + 1
+	group.end = group.start
+	for side.isChanged(group.end) {
+		group.end++
+	}
+	return true
+}
+
+func (side *compactSide) previousGroup(group *changeGroup) bool {
+	if group.start == 0 {
+		return false
+	}
+	group.end = group.start - 1
+	group.start = group.end
+	for side.isChanged(group.start - 1) {
+		group.start--
+	}
+	return true
+}
+
+// Moving a group down swaps its first line with the identical unchanged line after it
+func (side *compactSide) slideDown(group *changeGroup) bool {
+	if group.end >= len(side.changed) || side.lines[group.start] != side.lines[group.end] {
+		return false
+	}
+	side.changed[group.start] = false
+	side.changed[group.end] = true
+	group.start++
+	group.end++
+	for side.isChanged(group.end) {
+		group.end++
+	}
+	return true
+}
+
+func (side *compactSide) slideUp(group *changeGroup) bool {
+	if group.start == 0 || side.lines[group.start-1] != side.lines[group.end-1] {
+		return false
+	}
+	group.start--
+	group.end--
+	side.changed[group.start] = true
+	side.changed[group.end] = false
+	for side.isChanged(group.start - 1) {
+		group.start--
+	}
+	return true
+}
+
+func (changes *changeMarks) compact() {
+	oldSide := &compactSide{lines: changes.oldLines, changed: changes.old, indentHeuristic: !changes.noIndentHeuristic}
+	newSide := &compactSide{lines: changes.newLines, changed: changes.new, indentHeuristic: !changes.noIndentHeuristic}
+	oldSide.compactAgainst(newSide)
+	newSide.compactAgainst(oldSide)
+}
+
+func (side *compactSide) compactAgainst(other *compactSide) {
+	group := side.firstGroup()
+	otherGroup := other.firstGroup()
+	for {
+		if group.end != group.start {
+			side.slideGroup(other, &group, &otherGroup)
+		}
+		if !side.nextGroup(&group) {
+			return
+		}
+		other.nextGroup(&otherGroup)
+	}
+}
+
+func (side *compactSide) slideGroup(other *compactSide, group, otherGroup *changeGroup) {
+	var groupSize, earliestEnd int
+	endMatchingOther := -1
+	// Sliding can merge the group with its neighbours so keep going until its size settles
+	for {
+		groupSize = group.end - group.start
+		endMatchingOther = -1
+		for side.slideUp(group) {
+			other.previousGroup(otherGroup)
+		}
+		earliestEnd = group.end
+		if otherGroup.end > otherGroup.start {
+			endMatchingOther = group.end
+		}
+		for side.slideDown(group) {
+			other.nextGroup(otherGroup)
+			if otherGroup.end > otherGroup.start {
+				endMatchingOther = group.end
+			}
+		}
+		if groupSize == group.end-group.start {
+			break
+		}
+	}
+
+	switch {
+	case group.end == earliestEnd:
+		// Nowhere else to go
+	case endMatchingOther != -1:
+		// Line the group up with the change on the other side so they end up in one hunk
+		for otherGroup.end == otherGroup.start {
+			side.slideUp(group)
+			other.previousGroup(otherGroup)
+		}
+	case side.indentHeuristic:
+		bestShift := side.bestIndentShift(group.end, groupSize, earliestEnd)
+		for group.end > bestShift {
+			side.slideUp(group)
+			other.previousGroup(otherGroup)
+		}
+	}
+}
+
+const (
+	maxIndent  = 200
+	maxBlanks  = 20
+	maxSliding = 100
+
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+)
+
+// Try every position the group can end at and keep the one whose boundaries look most like
+// the edges of a block - ties go to the lowest position
+func (side *compactSide) bestIndentShift(end, groupSize, earliestEnd int) int {
+	shift := max(earliestEnd, end-groupSize-1, end-maxSliding)
+	bestShift := -1
+	var bestScore splitScore
+	for ; shift <= end; shift++ {
+		score := splitScore{}
+		score.add(side.measureSplit(shift))
+		score.add(side.measureSplit(shift - groupSize))
+		if bestShift == -1 || score.compare(bestScore) <= 0 {
+			bestScore = score
+			bestShift = shift
+		}
+	}
+	return bestShift
+}
+
+// Columns of leading whitespace with tabs to multiples of 8, -1 for blank lines
+func lineIndent(line string) int {
+	indent := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 8 - indent%8
+		case '\n', '\r', '\f', '\v':
+		default:
+			return indent
+		}
+		if indent >= maxIndent {
+			return maxIndent
+		}
+	}
+	return -1
+}
+
+// What the lines around a split just before the given line look like
+type splitMeasurement struct {
+	endOfFile  bool
+	indent     int
+	preBlank   int
+	preIndent  int
+	postBlank  int
+	postIndent int
+}
+
+func (side *compactSide) measureSplit(split int) splitMeasurement {
+	measurement := splitMeasurement{indent: -1, preIndent: -1, postIndent: -1}
+	if split >= len(side.lines) {
+		measurement.endOfFile = true
+	} else {
+		measurement.indent = lineIndent(side.lines[split])
+	}
+	for i := split - 1; i >= 0; i-- {
+		measurement.preIndent = lineIndent(side.lines[i])
+		if measurement.preIndent != -1 {
+			break
+		}
+		measurement.preBlank++
+		if measurement.preBlank == maxBlanks {
+			measurement.preIndent = 0
+			break
+		}
+	}
+	for i := split + 1; i < len(side.lines); i++ {
+		measurement.postIndent = lineIndent(side.lines[i])
+		if measurement.postIndent != -1 {
+			break
+		}
+		measurement.postBlank++
+		if measurement.postBlank == maxBlanks {
+			measurement.postIndent = 0
+			break
+		}
+	}
+	return measurement
+}
+
+type splitScore struct {
+	effectiveIndent int
+	penalty         int
+}
+
+func (score *splitScore) add(measurement splitMeasurement) {
+	if measurement.preIndent == -1 && measurement.preBlank == 0 {
+		score.penalty += startOfFilePenalty
+	}
+	if measurement.endOfFile {
+		score.penalty += endOfFilePenalty
+	}
+	postBlank := 0
+	if measurement.indent == -1 {
+		postBlank = 1 + measurement.postBlank
+	}
+	totalBlank := measurement.preBlank + postBlank
+	score.penalty += totalBlankWeight * totalBlank
+	score.penalty += postBlankWeight * postBlank
+
+	indent := measurement.indent
+	if indent == -1 {
+		indent = measurement.postIndent
+	}
+	anyBlanks := totalBlank != 0
+	score.effectiveIndent += indent
+
+	switch {
+	case indent == -1 || measurement.preIndent == -1 || indent == measurement.preIndent:
+	case indent > measurement.preIndent:
+		score.penalty += pick(anyBlanks, relativeIndentWithBlankPenalty, relativeIndentPenalty)
+	case measurement.postIndent != -1 && measurement.postIndent > indent:
+		score.penalty += pick(anyBlanks, relativeOutdentWithBlankPenalty, relativeOutdentPenalty)
+	default:
+		score.penalty += pick(anyBlanks, relativeDedentWithBlankPenalty, relativeDedentPenalty)
+	}
+}
+
+// Lower is better - indentation outweighs the penalties
+func (score splitScore) compare(other splitScore) int {
+	indentComparison := 0
+	switch {
+	case score.effectiveIndent > other.effectiveIndent:
+		indentComparison = 1
+	case score.effectiveIndent < other.effectiveIndent:
+		indentComparison = -1
+	}
+	return indentWeight*indentComparison + score.penalty - other.penalty
+}
+
+func pick(condition bool, ifTrue, ifFalse int) int {
+	if condition {
+		return ifTrue
+	}
+	return ifFalse
+}
@@ -0,0 +1,348 @@
+package diff
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Rebuild both files from an edit script to check it accounts for every line exactly once
+func applyEdits(t *testing.T, oldLines, newLines []string, edits []Edit) {
+	t.Helper()
+	var rebuiltOld, rebuiltNew []string
+	for _, edit := range edits {
+		switch edit.Operation {
+		case Equal:
+			if oldLines[edit.OldLine] != newLines[edit.NewLine] {
+				t.Fatalf("equal edit pairs %q with %q", oldLines[edit.OldLine], newLines[edit.NewLine])
+			}
+			rebuiltOld = append(rebuiltOld, oldLines[edit.OldLine])
+			rebuiltNew = append(rebuiltNew, newLines[edit.NewLine])
+		case Delete:
+			rebuiltOld = append(rebuiltOld, oldLines[edit.OldLine])
+		case Insert:
+			rebuiltNew = append(rebuiltNew, newLines[edit.NewLine])
+		}
+	}
+	if strings.Join(rebuiltOld, "") != strings.Join(oldLines, "") {
+		t.Fatalf("edits rebuild old as %q, expected %q", rebuiltOld, oldLines)
+	}
+	if strings.Join(rebuiltNew, "") != strings.Join(newLines, "") {
+		t.Fatalf("edits rebuild new as %q, expected %q", rebuiltNew, newLines)
+	}
+}
+
+func countChanges(edits []Edit) int {
+	changes := 0
+	for _, edit := range edits {
+		if edit.Operation != Equal {
+			changes++
+		}
+	}
+	return changes
+}
+
+func TestMyersProducesValidEditScripts(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		oldLines := make([]string, random.Intn(40))
+		for j := range oldLines {
+			oldLines[j] = string(rune('a'+random.Intn(4))) + "\n"
+		}
+		newLines := append([]string{}, oldLines...)
+		for j := random.Intn(8); j >= 0; j-- {
+			position := random.Intn(len(newLines) + 1)
+			if random.Intn(2) == 0 && position < len(newLines) {
+				newLines = append(newLines[:position], newLines[position+1:]...)
+			} else {
+				newLines = append(newLines[:position], append([]string{string(rune('a'+random.Intn(4))) + "\n"}, newLines[position:]...)...)
+			}
+		}
+		applyEdits(t, oldLines, newLines, Myers(oldLines, newLines))
+	}
+}
+
+func TestMyersFindsShortestEditScript(t *testing.T) {
+	// The classic example from Myers' paper has a shortest edit script of 5
+	oldLines := SplitLines([]byte("a\nb\nc\na\nb\nb\na\n"))
+	newLines := SplitLines([]byte("c\nb\na\nb\na\nc\n"))
+	edits := Myers(oldLines, newLines)
+	applyEdits(t, oldLines, newLines, edits)
+	if changes := countChanges(edits); changes != 5 {
+		t.Errorf("expected 5 changed lines, got %d", changes)
+	}
+}
+
+func TestUnifiedHunks(t *testing.T) {
+	oldText := "package main\n\nfunc main() {\n\tone()\n\ttwo()\n\tthree()\n}\n"
+	newText := "package main\n\nfunc main() {\n\tone()\n\t2()\n\tthree()\n}"
+	oldLines := SplitLines([]byte(oldText))
+	newLines := SplitLines([]byte(newText))
+
+	expected := "@@ -2,6 +2,6 @@ package main\n" +
+		" \n func main() {\n \tone()\n-\ttwo()\n+\t2()\n \tthree()\n-}\n+}\n\\ No newline at end of file\n"
+	got := FormatHunks(BuildHunks(oldLines, newLines, Myers(oldLines, newLines), 3))
+	if got != expected {
+		t.Errorf("unexpected hunks:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	// Without context the two changes are separate hunks and the function line comes from above each
+	expected = "@@ -5 +5 @@ func main() {\n-\ttwo()\n+\t2()\n" +
+		"@@ -7 +7 @@ func main() {\n-}\n+}\n\\ No newline at end of file\n"
+	got = FormatHunks(BuildHunks(oldLines, newLines, Myers(oldLines, newLines), 0))
+	if got != expected {
+		t.Errorf("unexpected hunks without context:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestChangesSlideToBlockBoundaries(t *testing.T) {
+	// A new function inserted between two others could be shown starting at any of the blank lines
+	// or closing braces - like git it should be the whole function followed by its blank line
+	oldText := "func a() {\n}\n\nfunc c() {\n}\n"
+	newText := "func a() {\n}\n\nfunc b() {\n}\n\nfunc c() {\n}\n"
+	oldLines := SplitLines([]byte(oldText))
+	newLines := SplitLines([]byte(newText))
+	expected := "@@ -3,0 +4,3 @@ func a() {\n+func b() {\n+}\n+\n"
+	got := FormatHunks(BuildHunks(oldLines, newLines, Myers(oldLines, newLines), 0))
+	if got != expected {
+		t.Errorf("unexpected hunks:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestAlgorithmsProduceValidEditScripts(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	for _, algorithm := range []Algorithm{MinimalAlgorithm, PatienceAlgorithm, HistogramAlgorithm} {
+		for i := 0; i < 300; i++ {
+			oldLines := make([]string, random.Intn(60))
+			for j := range oldLines {
+				oldLines[j] = string(rune('a'+random.Intn(6))) + "\n"
+			}
+			newLines := append([]string{}, oldLines...)
+			for j := random.Intn(10); j >= 0; j-- {
+				position := random.Intn(len(newLines) + 1)
+				if random.Intn(2) == 0 && position < len(newLines) {
+					newLines = append(newLines[:position], newLines[position+1:]...)
+				} else {
+					newLines = append(newLines[:position], append([]string{string(rune('a'+random.Intn(6))) + "\n"}, newLines[position:]...)...)
+				}
+			}
+			applyEdits(t, oldLines, newLines, algorithm.Diff(oldLines, newLines))
+		}
+	}
+}
+
+func TestPatienceAndHistogramPreferUniqueLines(t *testing.T) {
+	oldLines := SplitLines([]byte("\n{\n}\n}\nc()\nb()\n"))
+	newLines := SplitLines([]byte("\n{\n\n}\n}\n}\n\nc()\nb()\n"))
+
+	// Myers pairs the first closing braces while the others anchor on the unique opening one
+	expected := "@@ -2,0 +3,2 @@\n+\n+}\n@@ -4,0 +7 @@\n+\n"
+	if got := FormatHunks(BuildHunks(oldLines, newLines, MyersAlgorithm.Diff(oldLines, newLines), 0)); got != expected {
+		t.Errorf("unexpected myers hunks:\n%s\nexpected:\n%s", got, expected)
+	}
+	expected = "@@ -2,0 +3 @@\n+\n@@ -4,0 +6,2 @@\n+}\n+\n"
+	for _, algorithm := range []Algorithm{PatienceAlgorithm, HistogramAlgorithm} {
+		if got := FormatHunks(BuildHunks(oldLines, newLines, algorithm.Diff(oldLines, newLines), 0)); got != expected {
+			t.Errorf("unexpected %s hunks:\n%s\nexpected:\n%s", algorithm, got, expected)
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for name, expected := range map[string]Algorithm{"myers": MyersAlgorithm, "default": MyersAlgorithm, "Minimal": MinimalAlgorithm, "patience": PatienceAlgorithm, "HISTOGRAM": HistogramAlgorithm} {
+		algorithm, err := ParseAlgorithm(name)
+		if err != nil || algorithm != expected {
+			t.Errorf("expected %q to parse as %s, got %s (%v)", name, expected, algorithm, err)
+		}
+	}
+	if _, err := ParseAlgorithm("fast"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	segments := WordDiff("hello world\n", "hello there world\n", nil)
+	expected := []WordSegment{{Equal, "hello "}, {Insert, "there"}, {Equal, " world\n"}}
+	if !slices.Equal(segments, expected) {
+		t.Errorf("expected %v, got %v", expected, segments)
+	}
+
+	pattern, err := CompileWordPattern("[a-z]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments = WordDiff("cat\n", "cut\n", pattern)
+	expected = []WordSegment{{Equal, "c"}, {Delete, "a"}, {Insert, "u"}, {Equal, "t\n"}}
+	if !slices.Equal(segments, expected) {
+		t.Errorf("expected %v, got %v", expected, segments)
+	}
+
+	segments = WordDiff("gone\n", "", nil)
+	if len(segments) != 1 || segments[0] != (WordSegment{Delete, "gone\n"}) {
+		t.Errorf("expected the removed text as one segment, got %v", segments)
+	}
+}
+
+func TestMarkMoved(t *testing.T) {
+	block := []string{"func moved() {\n", "\treturn somethingLong()\n", "}\n"}
+	var lines []Line
+	for _, text := range block {
+		lines = append(lines, Line{Operation: Delete, Text: text})
+	}
+	lines = append(lines, Line{Operation: Equal, Text: "@@ -10 +10 @@\n"}, Line{Operation: Insert, Text: "new\n"})
+	for _, text := range block {
+		lines = append(lines, Line{Operation: Insert, Text: text})
+	}
+
+	marks := MarkMoved(lines, ZebraMoved)
+	for i, mark := range marks {
+		moved := lines[i].Operation != Equal && lines[i].Text != "new\n"
+		if (mark&Moved != 0) != moved {
+			t.Errorf("line %d %q: expected moved %v, got mark %b", i, lines[i].Text, moved, mark)
+		}
+	}
+
+	// A block without enough alphanumerics doesn't count unless every line does
+	short := []Line{{Operation: Delete, Text: "}\n"}, {Operation: Equal, Text: "x\n"}, {Operation: Insert, Text: "}\n"}}
+	if marks := MarkMoved(short, BlocksMoved); marks[0]|marks[2] != 0 {
+		t.Errorf("expected a short block to be left alone, got %v", marks)
+	}
+	if marks := MarkMoved(short, PlainMoved); marks[0]&Moved == 0 || marks[2]&Moved == 0 {
+		t.Errorf("expected plain mode to mark every moved line, got %v", marks)
+	}
+
+	// Only edges where two blocks meet stay bright so a lone block is dimmed all the way through
+	for i, mark := range MarkMoved(lines, DimmedZebraMoved)[:3] {
+		if mark&MovedUninteresting == 0 {
+			t.Errorf("line %d: expected the lone block to be dimmed, got mark %b", i, mark)
+		}
+	}
+}
+
+func TestParseAndApplyPatch(t *testing.T) {
+	patchText := "diff --git a/old b/new\n" +
+		"similarity index 80%\n" +
+		"rename from old\n" +
+		"rename to new\n" +
+		"old mode 100644\n" +
+		"new mode 100755\n" +
+		"--- a/old\n" +
+		"+++ b/new\n" +
+		"@@ -2,3 +2,3 @@ section\n" +
+		" 2\n" +
+		"-3\n" +
+		"+three\n" +
+		" 4\n"
+	patches, err := ParsePatch([]byte("From: someone\n\n" + patchText + "-- \n2.39.5\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("expected 1 patch, got %d", len(patches))
+	}
+	patch := patches[0]
+	if patch.OldName != "old" || patch.NewName != "new" || !patch.IsRename || patch.OldMode != 0100644 || patch.NewMode != 0100755 {
+		t.Errorf("unexpected headers: %+v", patch)
+	}
+	if got := patch.Hunks[0].String(); got != patchText[strings.Index(patchText, "@@"):] {
+		t.Errorf("expected the hunk to print as it was read, got %q", got)
+	}
+
+	// Two lines added above the hunk move it down but its context still has to match exactly
+	lines := SplitLines([]byte("0\n0\n1\n2\n3\n4\n5\n"))
+	applied, results := ApplyHunks(lines, patch.Hunks)
+	if strings.Join(applied, "") != "0\n0\n1\n2\nthree\n4\n5\n" || results[0].Line != 4 || results[0].Offset != 2 {
+		t.Errorf("unexpected result %q %+v", strings.Join(applied, ""), results)
+	}
+	if _, results := ApplyHunks(SplitLines([]byte("1\n2\nx\n4\n")), patch.Hunks); results[0].Applied() {
+		t.Errorf("expected a hunk with different context to be rejected, got %+v", results)
+	}
+
+	patch.Reverse()
+	if patch.OldName != "new" || patch.NewMode != 0100644 {
+		t.Errorf("unexpected reversed headers: %+v", patch)
+	}
+	if reverted, _ := ApplyHunks(applied, patch.Hunks); !slices.Equal(reverted, lines) {
+		t.Errorf("expected reversing to undo the patch, got %q", reverted)
+	}
+}
+
+func TestBinaryPatch(t *testing.T) {
+	patchText := "diff --git a/b.bin b/b.bin\n" +
+		"new file mode 100644\n" +
+		"index 0000000000000000000000000000000000000000..87ae6b695deceaf160611414f7dcd5c7366b2e79\n" +
+		"GIT binary patch\n" +
+		"literal 7\n" +
+		"OcmYew%wtF_sssQD(E^45\n" +
+		"\n" +
+		"literal 0\n" +
+		"HcmV?d00001\n" +
+		"\n"
+	patches, err := ParsePatch([]byte(patchText))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	patch := patches[0]
+	data, err := patch.Binary.Apply(nil)
+	if err != nil || string(data) != "bin\x00ary" {
+		t.Errorf("expected the literal content, got %q %v", data, err)
+	}
+	if patch.ReverseBinary == nil || patch.ReverseBinary.Size != 0 {
+		t.Errorf("expected an empty reverse hunk, got %+v", patch.ReverseBinary)
+	}
+
+	// Copy "hello " from the source and insert "there"
+	delta := []byte{11, 11, 0x90, 6, 5, 't', 'h', 'e', 'r', 'e'}
+	if result, err := applyDelta([]byte("hello world"), delta); err != nil || string(result) != "hello there" {
+		t.Errorf("expected the delta to apply, got %q %v", result, err)
+	}
+	if _, err := applyDelta([]byte("short"), delta); err == nil {
+		t.Errorf("expected a delta for a different source size to fail")
+	}
+}
+
+func TestEncodeBinaryLiteral(t *testing.T) {
+	// Long enough to need a few lines and with a short last one
+	data := make([]byte, 300)
+	random := rand.New(rand.NewSource(1))
+	random.Read(data)
+	encoded := EncodeBinaryLiteral(data)
+	if !strings.HasPrefix(encoded, "literal 300\n") || !strings.HasSuffix(encoded, "\n\n") {
+		t.Fatalf("expected a literal hunk, got %q", encoded)
+	}
+
+	patchText := "diff --git a/b.bin b/b.bin\n" +
+		"index 0000000000000000000000000000000000000000..1111111111111111111111111111111111111111\n" +
+		"GIT binary patch\n" + encoded + EncodeBinaryLiteral(nil)
+	patches, err := ParsePatch([]byte(patchText))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, err := patches[0].Binary.Apply(nil)
+	if err != nil || !slices.Equal(decoded, data) {
+		t.Errorf("expected the data back, got %d bytes %v", len(decoded), err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := SplitLines([]byte("1\n2\n3\n4\n5\n6\n"))
+	ours := SplitLines([]byte("one\n2\n3\n4\n5\n6\n"))
+	theirs := SplitLines([]byte("1\n2\n3\n4\n5\nsix\n"))
+	merged, conflicts := Merge(base, ours, theirs, "ours", "theirs")
+	if conflicts != 0 || strings.Join(merged, "") != "one\n2\n3\n4\n5\nsix\n" {
+		t.Errorf("expected a clean merge, got %d conflicts %q", conflicts, strings.Join(merged, ""))
+	}
+
+	theirs = SplitLines([]byte("1\ntwo\n3\n4\n5\n6\n"))
+	merged, conflicts = Merge(base, ours, theirs, "ours", "theirs")
+	expected := "<<<<<<< ours\none\n2\n=======\n1\ntwo\n>>>>>>> theirs\n3\n4\n5\n6\n"
+	if conflicts != 1 || strings.Join(merged, "") != expected {
+		t.Errorf("expected touching changes to conflict, got %d conflicts %q", conflicts, strings.Join(merged, ""))
+	}
+
+	// The same change on both sides isn't a conflict
+	if merged, conflicts := Merge(base, ours, ours, "ours", "theirs"); conflicts != 0 || !slices.Equal(merged, ours) {
+		t.Errorf("expected identical changes to merge cleanly, got %d conflicts %q", conflicts, merged)
+	}
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+)
+
+type Operation int
+
+const (
+	Equal Operation = iota
+	Delete
+	Insert
+)
+
+// One line of an edit script - indexes are 0 based and only the side(s) the operation touches are meaningful
+type Edit struct {
+	Operation Operation
+	OldLine   int
+	NewLine   int
+}
+
+// Split file content into lines that keep their "\n" so a missing newline at the end of the file
+// shows up as a difference in the last line
+func SplitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Git treats content with a NUL byte in the first 8000 bytes as binary
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) != -1
+}
+
+// Replace every line with a number so the algorithms compare ints instead of strings
+func internLines(oldLines, newLines []string) ([]int, []int) {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		lineIDs := make([]int, len(lines))
+		for i, line := range lines {
+			id, exists := ids[line]
+			if !exists {
+				id = len(ids)
+				ids[line] = id
+			}
+			lineIDs[i] = id
+		}
+		return lineIDs
+	}
+	return intern(oldLines), intern(newLines)
+}
+
+// Which lines on each side are deleted or inserted - the algorithms only mark lines
+// and the edit script is read off the marks afterwards
+type changeMarks struct {
+	old      []bool
+	new      []bool
+	oldLines []string
+	newLines []string
+	// Git only turns the heuristic off for word diffs
+	noIndentHeuristic bool
+}
+
+func newChangeMarks(oldLines, newLines []string) *changeMarks {
+	return &changeMarks{
+		old:      make([]bool, len(oldLines)),
+		new:      make([]bool, len(newLines)),
+		oldLines: oldLines,
+		newLines: newLines,
+	}
+}
+
+// Walk both sides together - deletions come before insertions within a change
+func (changes *changeMarks) edits() []Edit {
+	changes.compact()
+	var edits []Edit
+	oldLine, newLine := 0, 0
+	for oldLine < len(changes.old) || newLine < len(changes.new) {
+		switch {
+		case oldLine < len(changes.old) && changes.old[oldLine]:
+			edits = append(edits, Edit{Operation: Delete, OldLine: oldLine, NewLine: newLine})
+			oldLine++
+		case newLine < len(changes.new) && changes.new[newLine]:
+			edits = append(edits, Edit{Operation: Insert, OldLine: oldLine, NewLine: newLine})
+			newLine++
+		default:
+			edits = append(edits, Edit{Operation: Equal, OldLine: oldLine, NewLine: newLine})
+			oldLine++
+			newLine++
+		}
+	}
+	return edits
+}
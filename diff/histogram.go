@@ -0,0 +1,185 @@
+package diff
+
+// Histogram diff: a faster take on patience that also works when no line is unique. Each range is
+// split around the longest common run built from the old side's rarest lines and the halves on
+// either side of it are diffed the same way
+// Ref https://github.com/git/git/blob/master/xdiff/xhistogram.c
+func Histogram(oldLines, newLines []string) []Edit {
+	oldIDs, newIDs := internLines(oldLines, newLines)
+	histogram := &histogramDiff{old: oldIDs, new: newIDs, changes: newChangeMarks(oldLines, newLines)}
+
+	histogram.diff(0, len(oldIDs), 0, len(newIDs))
+	return histogram.changes.edits()
+}
+
+// Lines occurring more often than this on the old side are never used to split a range, and
+// a range where they're all that's in common is left to Myers
+const maxChainLength = 64
+
+type histogramDiff struct {
+	old     []int
+	new     []int
+	changes *changeMarks
+}
+
+// Every occurrence of one distinct line in the old range
+type histogramRecord struct {
+	first int
+	count int
+}
+
+// What one pass over a range builds: the records of the old side plus the best split found so far
+type histogramIndex struct {
+	records map[int]*histogramRecord
+	// Record and next occurrence for each old line, relative to the start of the range
+	lineRecords []*histogramRecord
+	next        []int
+	// Occurrence count of the rarest line in the best split, starting above anything usable
+	lowestCount int
+	hasCommon   bool
+
+	oldStart, oldEnd, newStart, newEnd int
+	// The best split so far as inclusive line ranges
+	found                 bool
+	splitOld, splitOldEnd int
+	splitNew, splitNewEnd int
+}
+
+func (histogram *histogramDiff) diff(oldStart, oldEnd, newStart, newEnd int) {
+	if oldStart == oldEnd || newStart == newEnd {
+		histogram.changes.markRanges(oldStart, oldEnd, newStart, newEnd)
+		return
+	}
+
+	index, ok := histogram.findSplit(oldStart, oldEnd, newStart, newEnd)
+	switch {
+	case !ok:
+		histogram.changes.fallBackToMyers(oldStart, oldEnd, newStart, newEnd)
+	case !index.found:
+		histogram.changes.markRanges(oldStart, oldEnd, newStart, newEnd)
+	default:
+		histogram.diff(oldStart, index.splitOld, newStart, index.splitNew)
+		histogram.diff(index.splitOldEnd+1, oldEnd, index.splitNewEnd+1, newEnd)
+	}
+}
+
+// Index the old range and try every new line as the start of a split
+// Not ok means the range should go to Myers instead
+func (histogram *histogramDiff) findSplit(oldStart, oldEnd, newStart, newEnd int) (*histogramIndex, bool) {
+	index := &histogramIndex{
+		records:     make(map[int]*histogramRecord),
+		lineRecords: make([]*histogramRecord, oldEnd-oldStart),
+		next:        make([]int, oldEnd-oldStart),
+		lowestCount: maxChainLength + 1,
+		oldStart:    oldStart,
+		oldEnd:      oldEnd,
+		newStart:    newStart,
+		newEnd:      newEnd,
+	}
+	if !histogram.scanOld(index) {
+		return nil, false
+	}
+	for newLine := newStart; newLine < newEnd; {
+		newLine = histogram.trySplit(index, newLine)
+	}
+	if index.hasCommon && index.lowestCount > maxChainLength {
+		return nil, false
+	}
+	return index, true
+}
+
+// Walk the old range backwards so each record's occurrences end up chained in order
+// xdiff keeps the records in a hash table and gives up once a bucket holds too many distinct lines,
+// so the same buckets are counted here to give up in the same places
+func (histogram *histogramDiff) scanOld(index *histogramIndex) bool {
+	bits := hashBits(index.oldEnd - index.oldStart)
+	bucketSizes := make(map[int]int)
+	for line := index.oldEnd - 1; line >= index.oldStart; line-- {
+		id := histogram.old[line]
+		record, exists := index.records[id]
+		if exists {
+			index.next[line-index.oldStart] = record.first
+			record.first = line
+			record.count++
+		} else {
+			bucket := hashID(id, bits)
+			if bucketSizes[bucket] == maxChainLength {
+				return false
+			}
+			bucketSizes[bucket]++
+			record = &histogramRecord{first: line, count: 1}
+			index.records[id] = record
+			index.next[line-index.oldStart] = -1
+		}
+		index.lineRecords[line-index.oldStart] = record
+	}
+	return true
+}
+
+// Stretch every occurrence of the new line on the old side into a common run and keep the run if it's
+// longer than the best so far or made of rarer lines. Returns the next new line worth trying
+func (histogram *histogramDiff) trySplit(index *histogramIndex, newLine int) int {
+	nextNew := newLine + 1
+	record := index.records[histogram.new[newLine]]
+	if record == nil {
+		return nextNew
+	}
+	index.hasCommon = true
+	if record.count > index.lowestCount {
+		return nextNew
+	}
+
+	oldLine := record.first
+	for {
+		nextOld := index.next[oldLine-index.oldStart]
+		oldStart, oldEnd := oldLine, oldLine
+		newStart, newEnd := newLine, newLine
+		count := record.count
+		for index.oldStart < oldStart && index.newStart < newStart && histogram.old[oldStart-1] == histogram.new[newStart-1] {
+			oldStart--
+			newStart--
+			if count > 1 {
+				count = min(count, index.lineRecords[oldStart-index.oldStart].count)
+			}
+		}
+		for oldEnd < index.oldEnd-1 && newEnd < index.newEnd-1 && histogram.old[oldEnd+1] == histogram.new[newEnd+1] {
+			oldEnd++
+			newEnd++
+			if count > 1 {
+				count = min(count, index.lineRecords[oldEnd-index.oldStart].count)
+			}
+		}
+
+		nextNew = max(nextNew, newEnd+1)
+		if index.splitOldEnd-index.splitOld < oldEnd-oldStart || count < index.lowestCount {
+			index.found = true
+			index.splitOld, index.splitOldEnd = oldStart, oldEnd
+			index.splitNew, index.splitNewEnd = newStart, newEnd
+			index.lowestCount = count
+		}
+
+		// Skip occurrences already inside this run
+		for nextOld != -1 && nextOld <= oldEnd {
+			nextOld = index.next[nextOld-index.oldStart]
+		}
+		if nextOld == -1 {
+			return nextNew
+		}
+		oldLine = nextOld
+	}
+}
+
+// xdiff's table size for n entries: the smallest power of two holding them, at least 2
+func hashBits(n int) int {
+	bits := 0
+	for size := 1; size < n && bits < 32; size <<= 1 {
+		bits++
+	}
+	return max(bits, 1)
+}
+
+// XDL_HASHLONG - line ids are small so this is mostly the id masked to the table size
+func hashID(id, bits int) int {
+	value := uint64(id)
+	return int((value + value>>bits) & (1<<bits - 1))
+}
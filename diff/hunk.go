@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A block of changes with the unchanged lines around it
+// Starts are 0 based line indexes into the old and new files
+type Hunk struct {
+	OldStart int
+	OldCount int
+	NewStart int
+	NewCount int
+	// The closest line above the hunk that looks like the start of a function
+	Function string
+	Lines    []Line
+}
+
+type Line struct {
+	Operation Operation
+	// Includes the trailing newline unless it's the last line of a file without one
+	Text string
+}
+
+// Group an edit script into hunks with up to context unchanged lines on each side
+// Changes separated by no more than twice the context share a hunk
+func BuildHunks(oldLines, newLines []string, edits []Edit, context int) []*Hunk {
+	var hunks []*Hunk
+	for start := 0; start < len(edits); {
+		if edits[start].Operation == Equal {
+			start++
+			continue
+		}
+
+		// Extend over following changes until the gap to the next one is too wide
+		end := start
+		for {
+			for end < len(edits) && edits[end].Operation != Equal {
+				end++
+			}
+			gap := end
+			for gap < len(edits) && edits[gap].Operation == Equal {
+				gap++
+			}
+			if gap == len(edits) || gap-end > 2*context {
+				break
+			}
+			end = gap
+		}
+
+		hunkStart := max(start-context, 0)
+		hunkEnd := min(end+context, len(edits))
+		hunk := &Hunk{
+			OldStart: edits[hunkStart].OldLine,
+			NewStart: edits[hunkStart].NewLine,
+			Function: functionContext(oldLines, edits[hunkStart].OldLine),
+		}
+		for _, edit := range edits[hunkStart:hunkEnd] {
+			switch edit.Operation {
+			case Equal:
+				hunk.OldCount++
+				hunk.NewCount++
+				hunk.Lines = append(hunk.Lines, Line{Operation: Equal, Text: oldLines[edit.OldLine]})
+			case Delete:
+				hunk.OldCount++
+				hunk.Lines = append(hunk.Lines, Line{Operation: Delete, Text: oldLines[edit.OldLine]})
+			case Insert:
+				hunk.NewCount++
+				hunk.Lines = append(hunk.Lines, Line{Operation: Insert, Text: newLines[edit.NewLine]})
+			}
+		}
+		hunks = append(hunks, hunk)
+		start = hunkEnd
+	}
+	return hunks
+}
+
+// Git's default function line: the nearest line above that starts with a letter, _ or $,
+// cut to 80 bytes with trailing whitespace dropped
+func functionContext(oldLines []string, before int) string {
+	for i := before - 1; i >= 0; i-- {
+		line := oldLines[i]
+		if line == "" {
+			continue
+		}
+		first := line[0]
+		if first == '_' || first == '$' || ('a' <= first && first <= 'z') || ('A' <= first && first <= 'Z') {
+			return strings.TrimRight(line[:min(len(line), 80)], " \t\n\r\f\v")
+		}
+	}
+	return ""
+}
+
+// "@@ -start,count +start,count @@ function" - counts of 1 are left out and an empty side
+// is numbered by the line before it
+func (hunk *Hunk) Header() string {
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(hunk.OldStart, hunk.OldCount), hunkRange(hunk.NewStart, hunk.NewCount))
+	if hunk.Function != "" {
+		header += " " + hunk.Function
+	}
+	return header
+}
+
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// The line as it appears in a patch with its -, + or space prefix
+func (line Line) String() string {
+	prefix := map[Operation]string{Equal: " ", Delete: "-", Insert: "+"}[line.Operation]
+	if strings.HasSuffix(line.Text, "\n") {
+		return prefix + line.Text
+	}
+	return prefix + line.Text + "\n\\ No newline at end of file\n"
+}
+
+// The hunks rendered as the body of a unified diff
+func FormatHunks(hunks []*Hunk) string {
+	var builder strings.Builder
+	for _, hunk := range hunks {
+		builder.WriteString(hunk.Header() + "\n")
+		for _, line := range hunk.Lines {
+			builder.WriteString(line.String())
+		}
+	}
+	return builder.String()
+}
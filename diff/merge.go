@@ -0,0 +1,141 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+)
+
+// A run of changed lines from an edit script: base lines [baseStart, baseEnd) became [start, end) on one side
+type mergeChange struct {
+	baseStart int
+	baseEnd   int
+	start     int
+	end       int
+}
+
+func mergeChanges(edits []Edit) []mergeChange {
+	var changes []mergeChange
+	for i := 0; i < len(edits); {
+		if edits[i].Operation == Equal {
+			i++
+			continue
+		}
+		change := mergeChange{baseStart: edits[i].OldLine, baseEnd: edits[i].OldLine, start: edits[i].NewLine, end: edits[i].NewLine}
+		for ; i < len(edits) && edits[i].Operation != Equal; i++ {
+			if edits[i].Operation == Delete {
+				change.baseEnd++
+			} else {
+				change.end++
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Merge the changes both sides made to base line by line. Changes that overlap or touch are conflicts
+// unless both sides made the same change, and conflicts are narrowed down to the lines that actually
+// differ between the sides like git's default merge does
+// Returns the merged lines with conflict markers labelled by the names given and how many conflicts there were
+// Ref https://github.com/git/git/blob/master/xdiff/xmerge.c
+func Merge(base, ours, theirs []string, oursLabel, theirsLabel string) ([]string, int) {
+	ourChanges := mergeChanges(Myers(base, ours))
+	theirChanges := mergeChanges(Myers(base, theirs))
+
+	var merged []string
+	conflicts := 0
+	// Lines outside the changes line up with base once the changes before them are accounted for
+	ourShift, theirShift := 0, 0
+	position := 0
+	for len(ourChanges) > 0 || len(theirChanges) > 0 {
+		switch {
+		case len(theirChanges) == 0 || (len(ourChanges) > 0 && ourChanges[0].baseEnd < theirChanges[0].baseStart):
+			change := ourChanges[0]
+			ourChanges = ourChanges[1:]
+			merged = append(merged, base[position:change.baseStart]...)
+			merged = append(merged, ours[change.start:change.end]...)
+			ourShift += (change.end - change.start) - (change.baseEnd - change.baseStart)
+			position = change.baseEnd
+			continue
+		case len(ourChanges) == 0 || theirChanges[0].baseEnd < ourChanges[0].baseStart:
+			change := theirChanges[0]
+			theirChanges = theirChanges[1:]
+			merged = append(merged, base[position:change.baseStart]...)
+			merged = append(merged, theirs[change.start:change.end]...)
+			theirShift += (change.end - change.start) - (change.baseEnd - change.baseStart)
+			position = change.baseEnd
+			continue
+		}
+
+		// Both sides changed this part - take in every change that overlaps it
+		start := min(ourChanges[0].baseStart, theirChanges[0].baseStart)
+		end := start
+		ourStart, theirStart := start+ourShift, start+theirShift
+		for {
+			switch {
+			case len(ourChanges) > 0 && ourChanges[0].baseStart <= end:
+				change := ourChanges[0]
+				ourChanges = ourChanges[1:]
+				end = max(end, change.baseEnd)
+				ourShift += (change.end - change.start) - (change.baseEnd - change.baseStart)
+				continue
+			case len(theirChanges) > 0 && theirChanges[0].baseStart <= end:
+				change := theirChanges[0]
+				theirChanges = theirChanges[1:]
+				end = max(end, change.baseEnd)
+				theirShift += (change.end - change.start) - (change.baseEnd - change.baseStart)
+				continue
+			}
+			break
+		}
+
+		merged = append(merged, base[position:start]...)
+		ourLines, theirLines := ours[ourStart:end+ourShift], theirs[theirStart:end+theirShift]
+		var count int
+		merged, count = appendConflict(merged, ourLines, theirLines, oursLabel, theirsLabel)
+		conflicts += count
+		position = end
+	}
+	merged = append(merged, base[position:]...)
+	return merged, conflicts
+}
+
+// Lines both sides agree on go in as they are and only what's left between them is marked as a conflict
+func appendConflict(merged, ours, theirs []string, oursLabel, theirsLabel string) ([]string, int) {
+	if slices.Equal(ours, theirs) {
+		return append(merged, ours...), 0
+	}
+	// Nothing to narrow down when one side is empty
+	if len(ours) == 0 || len(theirs) == 0 {
+		return appendConflictMarkers(merged, ours, theirs, oursLabel, theirsLabel), 1
+	}
+
+	conflicts := 0
+	position := 0
+	for _, change := range mergeChanges(Myers(ours, theirs)) {
+		merged = append(merged, ours[position:change.baseStart]...)
+		merged = appendConflictMarkers(merged, ours[change.baseStart:change.baseEnd], theirs[change.start:change.end], oursLabel, theirsLabel)
+		conflicts++
+		position = change.baseEnd
+	}
+	return append(merged, ours[position:]...), conflicts
+}
+
+func appendConflictMarkers(merged, ours, theirs []string, oursLabel, theirsLabel string) []string {
+	merged = append(merged, "<<<<<<< "+oursLabel+"\n")
+	merged = appendCompleteLines(merged, ours)
+	merged = append(merged, "=======\n")
+	merged = appendCompleteLines(merged, theirs)
+	return append(merged, ">>>>>>> "+theirsLabel+"\n")
+}
+
+// A side ending without a newline gets one so the marker after it stays on its own line
+func appendCompleteLines(merged, lines []string) []string {
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		merged = append(merged, line)
+	}
+	return merged
+}
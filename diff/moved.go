@@ -0,0 +1,224 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How lines that were moved rather than changed are picked out with --color-moved
+type MovedMode int
+
+const (
+	NoMoved MovedMode = iota
+	// Every removed line that was added somewhere else and the other way around
+	PlainMoved
+	// Runs of at least 20 alphanumeric characters moved together
+	BlocksMoved
+	// Blocks with neighbouring blocks told apart
+	ZebraMoved
+	// Zebra with the middle of each block dimmed so only its edges stand out
+	DimmedZebraMoved
+)
+
+// Accepts the values of --color-moved and diff.colorMoved
+func ParseMovedMode(name string) (MovedMode, error) {
+	switch strings.ToLower(name) {
+	case "no", "false", "off", "0":
+		return NoMoved, nil
+	case "plain":
+		return PlainMoved, nil
+	case "blocks":
+		return BlocksMoved, nil
+	case "zebra", "default", "true", "on", "yes", "1":
+		return ZebraMoved, nil
+	case "dimmed-zebra", "dimmed_zebra":
+		return DimmedZebraMoved, nil
+	}
+	return NoMoved, fmt.Errorf("color moved setting must be one of 'no', 'default', 'blocks', 'zebra', 'dimmed-zebra', 'plain'")
+}
+
+type MoveMark uint8
+
+const (
+	Moved MoveMark = 1 << iota
+	// Set on every other block of moved lines
+	MovedAlternative
+	// Inside a block rather than at either edge of it
+	MovedUninteresting
+)
+
+// Moved blocks with fewer alphanumeric characters than this are too common to count as moves
+const movedMinAlphanumeric = 20
+
+// One removed or added line with the next one in the same run and the previous line like it
+type movedEntry struct {
+	line      int
+	nextLine  *movedEntry
+	nextMatch *movedEntry
+}
+
+// Mark which lines of a whole diff were moved - lines holds every line of the output in order with
+// anything that isn't removed or added as Equal, so headers and context end a block
+// Ref https://github.com/git/git/blob/master/diff.c (mark_color_as_moved)
+func MarkMoved(lines []Line, mode MovedMode) []MoveMark {
+	marks := make([]MoveMark, len(lines))
+	if mode == NoMoved {
+		return marks
+	}
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		keys[i] = strings.TrimSuffix(line.Text, "\n")
+	}
+
+	added := make(map[string]*movedEntry)
+	deleted := make(map[string]*movedEntry)
+	var previous *movedEntry
+	for i, line := range lines {
+		if line.Operation == Equal {
+			previous = nil
+			continue
+		}
+		entry := &movedEntry{line: i}
+		if previous != nil && lines[previous.line].Operation == line.Operation {
+			previous.nextLine = entry
+		}
+		previous = entry
+		if line.Operation == Insert {
+			entry.nextMatch = added[keys[i]]
+			added[keys[i]] = entry
+		} else {
+			entry.nextMatch = deleted[keys[i]]
+			deleted[keys[i]] = entry
+		}
+	}
+
+	// Blocks without enough real content lose their marks
+	adjustLastBlock := func(end, length int) bool {
+		alphanumeric := 0
+		for i := end - length; i < end; i++ {
+			for _, c := range []byte(lines[i].Text) {
+				if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+					alphanumeric++
+				}
+			}
+			if alphanumeric >= movedMinAlphanumeric {
+				return true
+			}
+		}
+		for i := end - length; i < end; i++ {
+			marks[i] &^= Moved | MovedAlternative
+		}
+		return false
+	}
+
+	// Where the lines of the current block could have come from
+	var candidates []*movedEntry
+	flipped := false
+	blockLength := 0
+	blockOperation := Equal
+	for n := 0; n < len(lines); n++ {
+		operation := lines[n].Operation
+		var match *movedEntry
+		switch operation {
+		case Insert:
+			match = deleted[keys[n]]
+		case Delete:
+			match = added[keys[n]]
+		default:
+			flipped = false
+		}
+
+		if len(candidates) > 0 && (match == nil || operation != blockOperation) {
+			// Start over from the second line of a block that didn't hold up in case a block starts there
+			if !adjustLastBlock(n, blockLength) && blockLength > 1 {
+				match = nil
+				n -= blockLength
+			}
+			candidates = nil
+			blockLength = 0
+			flipped = false
+		}
+		if match == nil {
+			blockOperation = Equal
+			continue
+		}
+		if mode == PlainMoved {
+			marks[n] |= Moved
+			continue
+		}
+
+		// Keep the candidates whose next line is this one
+		var following []*movedEntry
+		for _, candidate := range candidates {
+			if next := candidate.nextLine; next != nil && keys[next.line] == keys[n] {
+				following = append(following, next)
+			}
+		}
+		candidates = following
+
+		if len(candidates) == 0 {
+			contiguous := adjustLastBlock(n, blockLength)
+			if !contiguous && blockLength > 1 {
+				n -= blockLength
+			} else {
+				for ; match != nil; match = match.nextMatch {
+					candidates = append(candidates, match)
+				}
+			}
+
+			// A block right after another one of the same kind gets the other color
+			flipped = contiguous && len(candidates) > 0 && blockOperation == operation && !flipped
+			if len(candidates) > 0 {
+				blockOperation = operation
+			} else {
+				blockOperation = Equal
+			}
+			blockLength = 0
+		}
+
+		if len(candidates) > 0 {
+			blockLength++
+			marks[n] |= Moved
+			if flipped && mode != BlocksMoved {
+				marks[n] |= MovedAlternative
+			}
+		}
+	}
+	if mode != PlainMoved {
+		adjustLastBlock(len(lines), blockLength)
+	}
+
+	if mode == DimmedZebraMoved {
+		dimMovedLines(lines, marks)
+	}
+	return marks
+}
+
+// Only the first and last lines of a block and the lines where blocks meet keep their full color
+func dimMovedLines(lines []Line, marks []MoveMark) {
+	zebra := Moved | MovedAlternative
+	for n, line := range lines {
+		if line.Operation == Equal || marks[n]&Moved == 0 {
+			continue
+		}
+		previous, next := -1, -1
+		if n > 0 && lines[n-1].Operation != Equal {
+			previous = n - 1
+		}
+		if n+1 < len(lines) && lines[n+1].Operation != Equal {
+			next = n + 1
+		}
+
+		if previous != -1 && marks[previous]&zebra == marks[n]&zebra && next != -1 && marks[next]&zebra == marks[n]&zebra {
+			marks[n] |= MovedUninteresting
+			continue
+		}
+		if previous != -1 && marks[previous]&Moved != 0 && marks[previous]&MovedAlternative != marks[n]&MovedAlternative {
+			continue
+		}
+		if next != -1 && marks[next]&Moved != 0 && marks[next]&MovedAlternative != marks[n]&MovedAlternative {
+			continue
+		}
+		marks[n] |= MovedUninteresting
+	}
+}
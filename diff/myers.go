@@ -0,0 +1,395 @@
+package diff
+
+// Myers' O(ND) algorithm in linear space: find the middle of the shortest edit script by searching
+// from both corners at once, then solve the halves on either side of it recursively
+// This follows git's xdiff closely, down to its heuristics, so the output matches git's
+// Ref http://www.xmailserver.org/diff2.pdf
+// Ref https://github.com/git/git/blob/master/xdiff/xdiffi.c
+func Myers(oldLines, newLines []string) []Edit {
+	return myers(oldLines, newLines, false)
+}
+
+const (
+	// A snake this long counts as a good sign the search is on a useful path
+	snakeCount = 20
+	// The searches only give up on being minimal once they've cost this much
+	heuristicMinCost = 256
+	maxCostMin       = 256
+	heuristicK       = 4
+	// How many multiple matches are tolerated in a run of discarded lines
+	discardRun = 4
+	// How far either side of a line to look when deciding whether to discard it
+	discardWindow = 100
+	maxEqualLimit = 1024
+)
+
+type myersDiff struct {
+	// Line ids of the lines left after discarding and where each sits in the full file
+	old      []int
+	new      []int
+	oldIndex []int
+	newIndex []int
+	changes  *changeMarks
+	// Furthest reaching point per diagonal for the forward and backward searches
+	forward  []int
+	backward []int
+	// Diagonal k lives at index k + offset
+	offset  int
+	maxCost int
+}
+
+// The split point found by a search and whether each half still has to be solved minimally
+type myersSplit struct {
+	old         int
+	new         int
+	minimalLow  bool
+	minimalHigh bool
+}
+
+func myers(oldLines, newLines []string, minimal bool) []Edit {
+	changes := newChangeMarks(oldLines, newLines)
+	changes.markMyers(minimal)
+	return changes.edits()
+}
+
+func (changes *changeMarks) markMyers(minimal bool) {
+	oldIDs, newIDs := internLines(changes.oldLines, changes.newLines)
+	myers := prepareMyers(oldIDs, newIDs, changes, minimal)
+	myers.compare(0, len(myers.old), 0, len(myers.new), minimal)
+}
+
+// Patience and histogram hand ranges they can't split any further to Myers, which diffs them as
+// if they were files of their own
+func (changes *changeMarks) fallBackToMyers(oldStart, oldEnd, newStart, newEnd int) {
+	ranges := newChangeMarks(changes.oldLines[oldStart:oldEnd], changes.newLines[newStart:newEnd])
+	ranges.markMyers(false)
+	copy(changes.old[oldStart:oldEnd], ranges.old)
+	copy(changes.new[newStart:newEnd], ranges.new)
+}
+
+// Mark everything in both ranges as changed
+func (changes *changeMarks) markRanges(oldStart, oldEnd, newStart, newEnd int) {
+	for i := oldStart; i < oldEnd; i++ {
+		changes.old[i] = true
+	}
+	for i := newStart; i < newEnd; i++ {
+		changes.new[i] = true
+	}
+}
+
+// Shrink the problem before searching: lines shared at both ends are never changed and lines
+// without a match on the other side are always changed
+func prepareMyers(oldIDs, newIDs []int, changes *changeMarks, minimal bool) *myersDiff {
+	prefix := 0
+	for prefix < len(oldIDs) && prefix < len(newIDs) && oldIDs[prefix] == newIDs[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldIDs)-prefix && suffix < len(newIDs)-prefix && oldIDs[len(oldIDs)-1-suffix] == newIDs[len(newIDs)-1-suffix] {
+		suffix++
+	}
+
+	oldCounts := make(map[int]int)
+	for _, id := range oldIDs {
+		oldCounts[id]++
+	}
+	newCounts := make(map[int]int)
+	for _, id := range newIDs {
+		newCounts[id]++
+	}
+
+	myers := &myersDiff{changes: changes}
+	myers.old, myers.oldIndex = discardLines(oldIDs, prefix, len(oldIDs)-suffix, newCounts, changes.old, minimal)
+	myers.new, myers.newIndex = discardLines(newIDs, prefix, len(newIDs)-suffix, oldCounts, changes.new, minimal)
+
+	diagonals := len(myers.old) + len(myers.new) + 3
+	myers.forward = make([]int, diagonals)
+	myers.backward = make([]int, diagonals)
+	myers.offset = len(myers.new) + 1
+	myers.maxCost = max(bogoSqrt(diagonals), maxCostMin)
+	return myers
+}
+
+// Mark lines in [start, end) that can't be part of the common subsequence and return the rest
+// Lines matching many lines on the other side are dropped too when they sit in a run of discarded lines
+func discardLines(ids []int, start, end int, otherCounts map[int]int, changed []bool, minimal bool) ([]int, []int) {
+	matchLimit := min(bogoSqrt(len(ids)), maxEqualLimit)
+	// 0 no match, 1 kept, 2 too many matches
+	discard := make([]byte, len(ids))
+	for i := start; i < end; i++ {
+		switch matches := otherCounts[ids[i]]; {
+		case matches == 0:
+			discard[i] = 0
+		case matches >= matchLimit && !minimal:
+			discard[i] = 2
+		default:
+			discard[i] = 1
+		}
+	}
+
+	var kept, keptIndex []int
+	for i := start; i < end; i++ {
+		if discard[i] == 1 || (discard[i] == 2 && !surroundedByDiscards(discard, i, start, end-1)) {
+			kept = append(kept, ids[i])
+			keptIndex = append(keptIndex, i)
+		} else {
+			changed[i] = true
+		}
+	}
+	return kept, keptIndex
+}
+
+// A line with many matches is only discarded in the middle of a run made mostly of lines without one
+func surroundedByDiscards(discard []byte, line, start, end int) bool {
+	start = max(start, line-discardWindow)
+	end = min(end, line+discardWindow)
+
+	before, multipleBefore := 0, 1
+	for i := line - 1; i >= start; i-- {
+		if discard[i] == 0 {
+			before++
+		} else if discard[i] == 2 {
+			multipleBefore++
+		} else {
+			break
+		}
+	}
+	if before == 0 {
+		return false
+	}
+	after, multipleAfter := 0, 1
+	for i := line + 1; i <= end; i++ {
+		if discard[i] == 0 {
+			after++
+		} else if discard[i] == 2 {
+			multipleAfter++
+		} else {
+			break
+		}
+	}
+	if after == 0 {
+		return false
+	}
+	multiple := multipleBefore + multipleAfter
+	return multiple*discardRun < multiple+before+after
+}
+
+// Cheap square root approximation xdiff sizes its limits with
+func bogoSqrt(n int) int {
+	root := 1
+	for ; n > 0; n >>= 2 {
+		root <<= 1
+	}
+	return root
+}
+
+func (myers *myersDiff) compare(oldStart, oldEnd, newStart, newEnd int, minimal bool) {
+	for oldStart < oldEnd && newStart < newEnd && myers.old[oldStart] == myers.new[newStart] {
+		oldStart++
+		newStart++
+	}
+	for oldStart < oldEnd && newStart < newEnd && myers.old[oldEnd-1] == myers.new[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	switch {
+	case oldStart == oldEnd:
+		for i := newStart; i < newEnd; i++ {
+			myers.changes.new[myers.newIndex[i]] = true
+		}
+	case newStart == newEnd:
+		for i := oldStart; i < oldEnd; i++ {
+			myers.changes.old[myers.oldIndex[i]] = true
+		}
+	default:
+		split := myers.split(oldStart, oldEnd, newStart, newEnd, minimal)
+		myers.compare(oldStart, split.old, newStart, split.new, split.minimalLow)
+		myers.compare(split.old, oldEnd, split.new, newEnd, split.minimalHigh)
+	}
+}
+
+// Diagonals are numbered old - new in absolute line numbers - the forward search starts on the
+// diagonal through the top left corner and the backward one on the diagonal through the bottom right
+func (myers *myersDiff) split(oldStart, oldEnd, newStart, newEnd int, minimal bool) myersSplit {
+	forward, backward, offset := myers.forward, myers.backward, myers.offset
+	minDiagonal, maxDiagonal := oldStart-newEnd, oldEnd-newStart
+	forwardMiddle, backwardMiddle := oldStart-newStart, oldEnd-newEnd
+	odd := (forwardMiddle-backwardMiddle)&1 != 0
+	forwardMin, forwardMax := forwardMiddle, forwardMiddle
+	backwardMin, backwardMax := backwardMiddle, backwardMiddle
+	forward[offset+forwardMiddle] = oldStart
+	backward[offset+backwardMiddle] = oldEnd
+
+	for cost := 1; ; cost++ {
+		gotSnake := false
+
+		if forwardMin > minDiagonal {
+			forwardMin--
+			forward[offset+forwardMin-1] = -1
+		} else {
+			forwardMin++
+		}
+		if forwardMax < maxDiagonal {
+			forwardMax++
+			forward[offset+forwardMax+1] = -1
+		} else {
+			forwardMax--
+		}
+		for diagonal := forwardMax; diagonal >= forwardMin; diagonal -= 2 {
+			var oldLine int
+			if forward[offset+diagonal-1] >= forward[offset+diagonal+1] {
+				oldLine = forward[offset+diagonal-1] + 1
+			} else {
+				oldLine = forward[offset+diagonal+1]
+			}
+			previous := oldLine
+			newLine := oldLine - diagonal
+			for oldLine < oldEnd && newLine < newEnd && myers.old[oldLine] == myers.new[newLine] {
+				oldLine++
+				newLine++
+			}
+			if oldLine-previous > snakeCount {
+				gotSnake = true
+			}
+			forward[offset+diagonal] = oldLine
+			if odd && backwardMin <= diagonal && diagonal <= backwardMax && backward[offset+diagonal] <= oldLine {
+				return myersSplit{old: oldLine, new: newLine, minimalLow: true, minimalHigh: true}
+			}
+		}
+
+		if backwardMin > minDiagonal {
+			backwardMin--
+			backward[offset+backwardMin-1] = maxInt
+		} else {
+			backwardMin++
+		}
+		if backwardMax < maxDiagonal {
+			backwardMax++
+			backward[offset+backwardMax+1] = maxInt
+		} else {
+			backwardMax--
+		}
+		for diagonal := backwardMax; diagonal >= backwardMin; diagonal -= 2 {
+			var oldLine int
+			if backward[offset+diagonal-1] < backward[offset+diagonal+1] {
+				oldLine = backward[offset+diagonal-1]
+			} else {
+				oldLine = backward[offset+diagonal+1] - 1
+			}
+			previous := oldLine
+			newLine := oldLine - diagonal
+			for oldLine > oldStart && newLine > newStart && myers.old[oldLine-1] == myers.new[newLine-1] {
+				oldLine--
+				newLine--
+			}
+			if previous-oldLine > snakeCount {
+				gotSnake = true
+			}
+			backward[offset+diagonal] = oldLine
+			if !odd && forwardMin <= diagonal && diagonal <= forwardMax && oldLine <= forward[offset+diagonal] {
+				return myersSplit{old: oldLine, new: newLine, minimalLow: true, minimalHigh: true}
+			}
+		}
+
+		if minimal {
+			continue
+		}
+		// Past a certain cost settle for a long snake that made good progress even if it isn't optimal
+		if gotSnake && cost > heuristicMinCost {
+			if split, found := myers.forwardSnakeSplit(oldStart, oldEnd, newStart, newEnd, forwardMin, forwardMax, forwardMiddle, cost); found {
+				return split
+			}
+			if split, found := myers.backwardSnakeSplit(oldStart, oldEnd, newStart, newEnd, backwardMin, backwardMax, backwardMiddle, cost); found {
+				return split
+			}
+		}
+		// Enough is enough - take whichever search got furthest
+		if cost >= myers.maxCost {
+			return myers.furthestSplit(oldStart, oldEnd, newStart, newEnd, forwardMin, forwardMax, backwardMin, backwardMax)
+		}
+	}
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+func (myers *myersDiff) forwardSnakeSplit(oldStart, oldEnd, newStart, newEnd, forwardMin, forwardMax, forwardMiddle, cost int) (myersSplit, bool) {
+	best := 0
+	split := myersSplit{minimalLow: true}
+	for diagonal := forwardMax; diagonal >= forwardMin; diagonal -= 2 {
+		distance := diagonal - forwardMiddle
+		if distance < 0 {
+			distance = -distance
+		}
+		oldLine := myers.forward[myers.offset+diagonal]
+		newLine := oldLine - diagonal
+		value := (oldLine - oldStart) + (newLine - newStart) - distance
+		if value > heuristicK*cost && value > best &&
+			oldStart+snakeCount <= oldLine && oldLine < oldEnd &&
+			newStart+snakeCount <= newLine && newLine < newEnd {
+			for k := 1; myers.old[oldLine-k] == myers.new[newLine-k]; k++ {
+				if k == snakeCount {
+					best = value
+					split.old, split.new = oldLine, newLine
+					break
+				}
+			}
+		}
+	}
+	return split, best > 0
+}
+
+func (myers *myersDiff) backwardSnakeSplit(oldStart, oldEnd, newStart, newEnd, backwardMin, backwardMax, backwardMiddle, cost int) (myersSplit, bool) {
+	best := 0
+	split := myersSplit{minimalHigh: true}
+	for diagonal := backwardMax; diagonal >= backwardMin; diagonal -= 2 {
+		distance := diagonal - backwardMiddle
+		if distance < 0 {
+			distance = -distance
+		}
+		oldLine := myers.backward[myers.offset+diagonal]
+		newLine := oldLine - diagonal
+		value := (oldEnd - oldLine) + (newEnd - newLine) - distance
+		if value > heuristicK*cost && value > best &&
+			oldStart < oldLine && oldLine <= oldEnd-snakeCount &&
+			newStart < newLine && newLine <= newEnd-snakeCount {
+			for k := 0; myers.old[oldLine+k] == myers.new[newLine+k]; k++ {
+				if k == snakeCount-1 {
+					best = value
+					split.old, split.new = oldLine, newLine
+					break
+				}
+			}
+		}
+	}
+	return split, best > 0
+}
+
+func (myers *myersDiff) furthestSplit(oldStart, oldEnd, newStart, newEnd, forwardMin, forwardMax, backwardMin, backwardMax int) myersSplit {
+	forwardBest, forwardOld := -1, -1
+	for diagonal := forwardMax; diagonal >= forwardMin; diagonal -= 2 {
+		oldLine := min(myers.forward[myers.offset+diagonal], oldEnd)
+		newLine := oldLine - diagonal
+		if newEnd < newLine {
+			oldLine, newLine = newEnd+diagonal, newEnd
+		}
+		if forwardBest < oldLine+newLine {
+			forwardBest, forwardOld = oldLine+newLine, oldLine
+		}
+	}
+	backwardBest, backwardOld := maxInt, maxInt
+	for diagonal := backwardMax; diagonal >= backwardMin; diagonal -= 2 {
+		oldLine := max(oldStart, myers.backward[myers.offset+diagonal])
+		newLine := oldLine - diagonal
+		if newLine < newStart {
+			oldLine, newLine = newStart+diagonal, newStart
+		}
+		if oldLine+newLine < backwardBest {
+			backwardBest, backwardOld = oldLine+newLine, oldLine
+		}
+	}
+	if (oldEnd+newEnd)-backwardBest < forwardBest-(oldStart+newStart) {
+		return myersSplit{old: forwardOld, new: forwardBest - forwardOld, minimalLow: true}
+	}
+	return myersSplit{old: backwardOld, new: backwardBest - backwardOld, minimalHigh: true}
+}
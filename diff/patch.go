@@ -0,0 +1,504 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The changes a patch makes to one file, read from a unified diff with or without git's extended headers
+// Names have their a/ and b/ prefixes removed and are empty on the side where the file doesn't exist
+type FilePatch struct {
+	OldName string
+	NewName string
+	// Zero unless the patch says
+	OldMode  uint32
+	NewMode  uint32
+	IsNew    bool
+	IsDelete bool
+	IsRename bool
+	IsCopy   bool
+	// Possibly abbreviated blob hashes from the index line
+	OldHash string
+	NewHash string
+	Hunks   []*PatchHunk
+	// Set for "Binary files differ" patches, which have no data and can't be applied
+	IsBinary bool
+	// Data of a "GIT binary patch", the reverse hunk is optional
+	Binary        *BinaryHunk
+	ReverseBinary *BinaryHunk
+}
+
+type PatchHunk struct {
+	// As in the hunk header, so starts are 1 based unless the side is empty
+	OldStart int
+	OldCount int
+	NewStart int
+	NewCount int
+	// Whatever follows the ranges in the header, usually the enclosing function
+	Section string
+	// Lines keep their newline unless the patch marks them as the end of a file without one
+	Lines []Line
+	// Unchanged lines before the first change and after the last one
+	Leading  int
+	Trailing int
+}
+
+// Split a patch into the files it changes - anything before, between or after them, like the commit
+// message of an email, is skipped. A file's patch starts with "diff --git" or with ---/+++ lines
+// followed by a hunk
+// Ref https://github.com/git/git/blob/master/apply.c (parse_chunk)
+func ParsePatch(data []byte) ([]*FilePatch, error) {
+	lines := SplitLines(data)
+	var patches []*FilePatch
+	for i := 0; i < len(lines); {
+		var patch *FilePatch
+		var err error
+		start := i
+		switch {
+		case strings.HasPrefix(lines[i], "diff --git "):
+			patch, i, err = parseGitHeader(lines, i)
+		case strings.HasPrefix(lines[i], "--- ") && i+2 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") && strings.HasPrefix(lines[i+2], "@@ -"):
+			patch, i = parseTraditionalHeader(lines, i)
+		default:
+			i++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		i, err = parsePatchBody(lines, i, patch)
+		if err != nil {
+			return nil, err
+		}
+		// A git header with nothing after it is still a patch (a mode change or an empty new file)
+		// but ---/+++ lines alone aren't
+		if patch.OldName == "" && patch.NewName == "" {
+			return nil, fmt.Errorf("git diff header lacks filename information (line %d)", start+1)
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+func parseGitHeader(lines []string, i int) (*FilePatch, int, error) {
+	patch := &FilePatch{}
+	name := gitHeaderName(strings.TrimSuffix(strings.TrimPrefix(lines[i], "diff --git "), "\n"))
+	patch.OldName, patch.NewName = name, name
+	for i++; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\n")
+		header, value, _ := strings.Cut(line, " ")
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			if name := patchName(strings.TrimPrefix(line, "--- "), false); name != "" || patch.IsNew {
+				patch.OldName = name
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if name := patchName(strings.TrimPrefix(line, "+++ "), false); name != "" || patch.IsDelete {
+				patch.NewName = name
+			}
+		case strings.HasPrefix(line, "old mode "):
+			patch.OldMode = parseMode(strings.TrimPrefix(line, "old mode "))
+		case strings.HasPrefix(line, "new mode "):
+			patch.NewMode = parseMode(strings.TrimPrefix(line, "new mode "))
+		case strings.HasPrefix(line, "deleted file mode "):
+			patch.IsDelete = true
+			patch.OldMode = parseMode(strings.TrimPrefix(line, "deleted file mode "))
+			patch.NewName = ""
+		case strings.HasPrefix(line, "new file mode "):
+			patch.IsNew = true
+			patch.NewMode = parseMode(strings.TrimPrefix(line, "new file mode "))
+			patch.OldName = ""
+		case strings.HasPrefix(line, "rename from "):
+			patch.IsRename = true
+			patch.OldName = unquoteName(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename old "):
+			patch.IsRename = true
+			patch.OldName = unquoteName(strings.TrimPrefix(line, "rename old "))
+		case strings.HasPrefix(line, "rename to "):
+			patch.IsRename = true
+			patch.NewName = unquoteName(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "rename new "):
+			patch.IsRename = true
+			patch.NewName = unquoteName(strings.TrimPrefix(line, "rename new "))
+		case strings.HasPrefix(line, "copy from "):
+			patch.IsCopy = true
+			patch.OldName = unquoteName(strings.TrimPrefix(line, "copy from "))
+		case strings.HasPrefix(line, "copy to "):
+			patch.IsCopy = true
+			patch.NewName = unquoteName(strings.TrimPrefix(line, "copy to "))
+		case header == "index":
+			hashes, mode, _ := strings.Cut(value, " ")
+			patch.OldHash, patch.NewHash, _ = strings.Cut(hashes, "..")
+			if mode != "" {
+				patch.OldMode = parseMode(mode)
+				patch.NewMode = patch.OldMode
+			}
+		case strings.HasPrefix(line, "similarity index "), strings.HasPrefix(line, "dissimilarity index "):
+		default:
+			return patch, i, nil
+		}
+	}
+	return patch, i, nil
+}
+
+// Without "a/x b/x" style names of the same file the name comes from the other headers instead
+func gitHeaderName(names string) string {
+	if strings.HasPrefix(names, "\"") {
+		end := closingQuote(names)
+		if end == -1 {
+			return ""
+		}
+		first := stripComponent(unquoteName(names[:end+1]))
+		if second := stripComponent(unquoteName(strings.TrimPrefix(names[end+1:], " "))); first == second {
+			return first
+		}
+		return ""
+	}
+	for i := 0; i < len(names); i++ {
+		if names[i] != ' ' {
+			continue
+		}
+		first, second := stripComponent(names[:i]), stripComponent(unquoteName(names[i+1:]))
+		if first != "" && first == second {
+			return first
+		}
+	}
+	return ""
+}
+
+func closingQuote(quoted string) int {
+	for i := 1; i < len(quoted); i++ {
+		switch quoted[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Names with unusual characters are written as C strings
+func unquoteName(name string) string {
+	if strings.HasPrefix(name, "\"") {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			return unquoted
+		}
+	}
+	return name
+}
+
+// Drop the a/ or b/ in front of a name like patch -p1
+func stripComponent(name string) string {
+	_, rest, found := strings.Cut(name, "/")
+	if !found {
+		return ""
+	}
+	return rest
+}
+
+// A name from a ---/+++ line - traditional diffs put a timestamp after a tab
+func patchName(value string, traditional bool) string {
+	if traditional {
+		value, _, _ = strings.Cut(value, "\t")
+	} else {
+		value = strings.TrimSuffix(value, "\t")
+	}
+	if value == "/dev/null" {
+		return ""
+	}
+	return stripComponent(unquoteName(value))
+}
+
+func parseMode(value string) uint32 {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(mode)
+}
+
+func parseTraditionalHeader(lines []string, i int) (*FilePatch, int) {
+	oldName := patchName(strings.TrimSuffix(strings.TrimPrefix(lines[i], "--- "), "\n"), true)
+	newName := patchName(strings.TrimSuffix(strings.TrimPrefix(lines[i+1], "+++ "), "\n"), true)
+	return &FilePatch{OldName: oldName, NewName: newName, IsNew: oldName == "", IsDelete: newName == ""}, i + 2
+}
+
+// Hunks or binary data after the headers - stops at the first line that can't belong to them
+func parsePatchBody(lines []string, i int, patch *FilePatch) (int, error) {
+	for i < len(lines) {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "@@ -"):
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return i, err
+			}
+			patch.Hunks = append(patch.Hunks, hunk)
+			i = next
+		case strings.HasPrefix(line, "Binary files ") && len(patch.Hunks) == 0:
+			patch.IsBinary = true
+			return i + 1, nil
+		case line == "GIT binary patch\n" && len(patch.Hunks) == 0:
+			return parseBinaryPatch(lines, i+1, patch)
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+// Reads lines until both counts from the header are used up
+// Ref https://github.com/git/git/blob/master/apply.c (parse_fragment)
+func parseHunk(lines []string, i int) (*PatchHunk, int, error) {
+	hunk := &PatchHunk{}
+	header := lines[i]
+	if end := strings.Index(header[4:], "@@"); end != -1 {
+		hunk.Section = strings.TrimSuffix(header[4+end+2:], "\n")
+	}
+	_, err := fmt.Sscanf(hunkRangeText(header, '-'), "%d,%d", &hunk.OldStart, &hunk.OldCount)
+	if err != nil {
+		hunk.OldCount = 1
+		_, err = fmt.Sscanf(hunkRangeText(header, '-'), "%d", &hunk.OldStart)
+	}
+	if err == nil {
+		_, err = fmt.Sscanf(hunkRangeText(header, '+'), "%d,%d", &hunk.NewStart, &hunk.NewCount)
+		if err != nil {
+			hunk.NewCount = 1
+			_, err = fmt.Sscanf(hunkRangeText(header, '+'), "%d", &hunk.NewStart)
+		}
+	}
+	if err != nil {
+		return nil, i, fmt.Errorf("corrupt patch at line %d", i+1)
+	}
+
+	oldLeft, newLeft := hunk.OldCount, hunk.NewCount
+	changed := false
+	for i++; i < len(lines) && (oldLeft > 0 || newLeft > 0); i++ {
+		line := lines[i]
+		operation := Equal
+		switch line[0] {
+		// Some mailers drop the space of empty context lines
+		case ' ', '\n':
+			oldLeft--
+			newLeft--
+		case '-':
+			operation = Delete
+			oldLeft--
+		case '+':
+			operation = Insert
+			newLeft--
+		case '\\':
+			markIncomplete(hunk)
+			continue
+		default:
+			return nil, i, fmt.Errorf("corrupt patch at line %d", i+1)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return nil, i, fmt.Errorf("corrupt patch at line %d", i+1)
+		}
+		text := "\n"
+		if line != "\n" {
+			text = line[1:]
+		}
+		hunk.Lines = append(hunk.Lines, Line{Operation: operation, Text: text})
+		if operation == Equal {
+			if changed {
+				hunk.Trailing++
+			} else {
+				hunk.Leading++
+			}
+		} else {
+			changed = true
+			hunk.Trailing = 0
+		}
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, i, fmt.Errorf("corrupt patch at line %d", i+1)
+	}
+	// The marker for the last line comes after the counts run out
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		markIncomplete(hunk)
+		i++
+	}
+	if !changed {
+		return nil, i, fmt.Errorf("corrupt patch at line %d", i)
+	}
+	return hunk, i, nil
+}
+
+// The hunk as it appears in a patch
+func (hunk *PatchHunk) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "@@ -%s +%s @@%s\n", patchHunkRange(hunk.OldStart, hunk.OldCount), patchHunkRange(hunk.NewStart, hunk.NewCount), hunk.Section)
+	for _, line := range hunk.Lines {
+		builder.WriteString(line.String())
+	}
+	return builder.String()
+}
+
+// Patch hunks number from 1 except on an empty side so they're a line ahead of diff hunks
+func patchHunkRange(start, count int) string {
+	if count == 0 {
+		return hunkRange(start, 0)
+	}
+	return hunkRange(start-1, count)
+}
+
+// The "start,count" after the - or + of a hunk header
+func hunkRangeText(header string, sign byte) string {
+	for _, field := range strings.Fields(header) {
+		if field[0] == sign {
+			return field[1:]
+		}
+	}
+	return ""
+}
+
+// "\ No newline at end of file" belongs to the line before it
+func markIncomplete(hunk *PatchHunk) {
+	if len(hunk.Lines) > 0 {
+		last := &hunk.Lines[len(hunk.Lines)-1]
+		last.Text = strings.TrimSuffix(last.Text, "\n")
+	}
+}
+
+// Swap the sides so applying the patch undoes it
+func (patch *FilePatch) Reverse() {
+	patch.OldName, patch.NewName = patch.NewName, patch.OldName
+	patch.OldMode, patch.NewMode = patch.NewMode, patch.OldMode
+	patch.OldHash, patch.NewHash = patch.NewHash, patch.OldHash
+	patch.IsNew, patch.IsDelete = patch.IsDelete, patch.IsNew
+	patch.Binary, patch.ReverseBinary = patch.ReverseBinary, patch.Binary
+	for _, hunk := range patch.Hunks {
+		hunk.OldStart, hunk.NewStart = hunk.NewStart, hunk.OldStart
+		hunk.OldCount, hunk.NewCount = hunk.NewCount, hunk.OldCount
+		for i, line := range hunk.Lines {
+			switch line.Operation {
+			case Delete:
+				hunk.Lines[i].Operation = Insert
+			case Insert:
+				hunk.Lines[i].Operation = Delete
+			}
+		}
+	}
+}
+
+// Lines added and removed by the hunks
+func (patch *FilePatch) LineCounts() (added, deleted int) {
+	for _, hunk := range patch.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Operation {
+			case Insert:
+				added++
+			case Delete:
+				deleted++
+			}
+		}
+	}
+	return added, deleted
+}
+
+// Where a hunk went: the 1 based line it was applied at, or zero when its context wasn't found
+type HunkResult struct {
+	Line   int
+	Offset int
+}
+
+func (result HunkResult) Applied() bool {
+	return result.Line != 0
+}
+
+// Apply hunks to the lines of a file, skipping any whose lines aren't there
+// The context has to match exactly but can be found away from where the header says, searching
+// outwards from there. Hunks starting at the top of the file or ending without context have to
+// match at the start or end of the file
+// Ref https://github.com/git/git/blob/master/apply.c (apply_one_fragment)
+func ApplyHunks(lines []string, hunks []*PatchHunk) ([]string, []HunkResult) {
+	image := append([]string(nil), lines...)
+	results := make([]HunkResult, len(hunks))
+	for i, hunk := range hunks {
+		var preimage, postimage []string
+		for _, line := range hunk.Lines {
+			if line.Operation != Insert {
+				preimage = append(preimage, line.Text)
+			}
+			if line.Operation != Delete {
+				postimage = append(postimage, line.Text)
+			}
+		}
+
+		matchBeginning := hunk.OldStart <= 1
+		matchEnd := hunk.Trailing == 0
+		// Earlier hunks have already moved things so the new side's numbers are the better guess
+		guess := max(hunk.NewStart-1, 0)
+		position := findPreimage(image, preimage, guess, matchBeginning, matchEnd)
+		if position == -1 {
+			continue
+		}
+		image = append(image[:position], append(postimage, image[position+len(preimage):]...)...)
+		results[i] = HunkResult{Line: position + 1, Offset: position - guess}
+	}
+	return image, results
+}
+
+// Try the guess first and then lines after and before it in turn
+// Ref https://github.com/git/git/blob/master/apply.c (find_pos)
+func findPreimage(image, preimage []string, guess int, matchBeginning, matchEnd bool) int {
+	if len(preimage) > len(image) {
+		return -1
+	}
+	switch {
+	case matchBeginning:
+		guess = 0
+	case matchEnd:
+		guess = len(image) - len(preimage)
+	}
+	guess = min(guess, len(image))
+
+	backwards, forwards := guess, guess
+	current := guess
+	for i := 0; ; i++ {
+		if matchesAt(image, preimage, current, matchBeginning, matchEnd) {
+			return current
+		}
+		for {
+			if backwards == 0 && forwards == len(image) {
+				return -1
+			}
+			if i&1 == 1 {
+				if backwards == 0 {
+					i++
+					continue
+				}
+				backwards--
+				current = backwards
+			} else {
+				if forwards == len(image) {
+					i++
+					continue
+				}
+				forwards++
+				current = forwards
+			}
+			break
+		}
+	}
+}
+
+func matchesAt(image, preimage []string, position int, matchBeginning, matchEnd bool) bool {
+	if position+len(preimage) > len(image) {
+		return false
+	}
+	if (matchBeginning && position != 0) || (matchEnd && position+len(preimage) != len(image)) {
+		return false
+	}
+	for i, line := range preimage {
+		if image[position+i] != line {
+			return false
+		}
+	}
+	return true
+}
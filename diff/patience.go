@@ -0,0 +1,153 @@
+package diff
+
+import "sort"
+
+// Patience diff: pair up lines that appear exactly once on each side, keep the longest run of those
+// pairs that's in the same order on both sides and diff the gaps between them the same way
+// Ranges without any unique common line fall back to Myers
+// Ref https://bramcohen.livejournal.com/73318.html
+// Ref https://github.com/git/git/blob/master/xdiff/xpatience.c
+func Patience(oldLines, newLines []string) []Edit {
+	oldIDs, newIDs := internLines(oldLines, newLines)
+	patience := &patienceDiff{old: oldIDs, new: newIDs, changes: newChangeMarks(oldLines, newLines)}
+	patience.diff(0, len(oldIDs), 0, len(newIDs))
+	return patience.changes.edits()
+}
+
+const (
+	// A line of the old range that isn't on the new side
+	patienceUnmatched = -1
+	// A line that shows up more than once on either side
+	patienceNotUnique = -2
+)
+
+type patienceDiff struct {
+	old     []int
+	new     []int
+	changes *changeMarks
+}
+
+type patienceLine struct {
+	oldLine int
+	// Where the line is on the new side, or one of the markers above
+	newLine int
+	// Links for the common sequence being built
+	previous *patienceLine
+	next     *patienceLine
+}
+
+func (patience *patienceDiff) diff(oldStart, oldEnd, newStart, newEnd int) {
+	if oldStart == oldEnd || newStart == newEnd {
+		patience.changes.markRanges(oldStart, oldEnd, newStart, newEnd)
+		return
+	}
+
+	lines, hasMatches := patience.uniqueLines(oldStart, oldEnd, newStart, newEnd)
+	if !hasMatches {
+		patience.changes.markRanges(oldStart, oldEnd, newStart, newEnd)
+		return
+	}
+	first := longestCommonSequence(lines)
+	if first == nil {
+		patience.changes.fallBackToMyers(oldStart, oldEnd, newStart, newEnd)
+		return
+	}
+	patience.walkCommonSequence(first, oldStart, oldEnd, newStart, newEnd)
+}
+
+// Every distinct line of the old range in order of first appearance, along with whether any line
+// of the new range shows up on the old side at all
+func (patience *patienceDiff) uniqueLines(oldStart, oldEnd, newStart, newEnd int) ([]*patienceLine, bool) {
+	byID := make(map[int]*patienceLine)
+	var lines []*patienceLine
+	for i := oldStart; i < oldEnd; i++ {
+		if line, exists := byID[patience.old[i]]; exists {
+			line.newLine = patienceNotUnique
+			continue
+		}
+		line := &patienceLine{oldLine: i, newLine: patienceUnmatched}
+		byID[patience.old[i]] = line
+		lines = append(lines, line)
+	}
+
+	hasMatches := false
+	for i := newStart; i < newEnd; i++ {
+		line, exists := byID[patience.new[i]]
+		if !exists {
+			continue
+		}
+		hasMatches = true
+		if line.newLine == patienceUnmatched {
+			line.newLine = i
+		} else {
+			line.newLine = patienceNotUnique
+		}
+	}
+	return lines, hasMatches
+}
+
+// Patience sorting over the unique pairs - they're already in old side order so this is the longest
+// increasing run of new side positions. Returns the start of the chain linked through next
+func longestCommonSequence(lines []*patienceLine) *patienceLine {
+	// The pair ending the best sequence of each length, keeping the one with the smallest new line
+	var sequence []*patienceLine
+	for _, line := range lines {
+		if line.newLine < 0 {
+			continue
+		}
+		length := sort.Search(len(sequence), func(i int) bool {
+			return sequence[i].newLine > line.newLine
+		})
+		if length > 0 {
+			line.previous = sequence[length-1]
+		}
+		if length == len(sequence) {
+			sequence = append(sequence, line)
+		} else {
+			sequence[length] = line
+		}
+	}
+	if len(sequence) == 0 {
+		return nil
+	}
+
+	line := sequence[len(sequence)-1]
+	for line.previous != nil {
+		line.previous.next = line
+		line = line.previous
+	}
+	return line
+}
+
+// Diff the gaps between the pairs of the common sequence, first stretching each pair over the
+// identical lines next to it
+func (patience *patienceDiff) walkCommonSequence(first *patienceLine, oldStart, oldEnd, newStart, newEnd int) {
+	for {
+		nextOld, nextNew := oldEnd, newEnd
+		if first != nil {
+			nextOld, nextNew = first.oldLine, first.newLine
+			for nextOld > oldStart && nextNew > newStart && patience.old[nextOld-1] == patience.new[nextNew-1] {
+				nextOld--
+				nextNew--
+			}
+		}
+		for oldStart < nextOld && newStart < nextNew && patience.old[oldStart] == patience.new[newStart] {
+			oldStart++
+			newStart++
+		}
+
+		if nextOld > oldStart || nextNew > newStart {
+			patience.diff(oldStart, nextOld, newStart, nextNew)
+		}
+		if first == nil {
+			return
+		}
+
+		// Pairs that follow each other on both sides have no gap to diff
+		for first.next != nil && first.next.oldLine == first.oldLine+1 && first.next.newLine == first.newLine+1 {
+			first = first.next
+		}
+		oldStart, newStart = first.oldLine+1, first.newLine+1
+		first = first.next
+	}
+}
@@ -0,0 +1,141 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A run of text in a word diff - equal text always comes from the new side
+type WordSegment struct {
+	Operation Operation
+	Text      string
+}
+
+// A word as byte offsets into the text it came from
+type word struct {
+	start int
+	end   int
+}
+
+// Diff the removed and added text of a block of changed lines word by word
+// Words are runs of non-whitespace unless a pattern says otherwise, and everything between the
+// changed words is carried over from the new text like git does, whitespace included
+// Ref https://github.com/git/git/blob/master/diff.c (diff_words_show)
+func WordDiff(oldText, newText string, pattern *regexp.Regexp) []WordSegment {
+	// Only removals are shown as one block, newlines and all
+	if newText == "" {
+		if oldText == "" {
+			return nil
+		}
+		return []WordSegment{{Operation: Delete, Text: oldText}}
+	}
+
+	oldWords := splitWords(oldText, pattern)
+	newWords := splitWords(newText, pattern)
+	oldLines := wordLines(oldText, oldWords)
+	newLines := wordLines(newText, newWords)
+	// Git diffs the words with plain Myers, without the indent heuristic its line diffs use
+	changes := newChangeMarks(oldLines, newLines)
+	changes.noIndentHeuristic = true
+	changes.markMyers(false)
+	hunks := BuildHunks(oldLines, newLines, changes.edits(), 0)
+
+	var segments []WordSegment
+	current := 0
+	for _, hunk := range hunks {
+		oldStart, oldEnd := wordRange(oldWords, hunk.OldStart, hunk.OldCount)
+		newStart, newEnd := wordRange(newWords, hunk.NewStart, hunk.NewCount)
+		if current != newStart {
+			segments = append(segments, WordSegment{Operation: Equal, Text: newText[current:newStart]})
+		}
+		if oldStart != oldEnd {
+			segments = append(segments, WordSegment{Operation: Delete, Text: oldText[oldStart:oldEnd]})
+		}
+		if newStart != newEnd {
+			segments = append(segments, WordSegment{Operation: Insert, Text: newText[newStart:newEnd]})
+		}
+		current = newEnd
+	}
+	if current != len(newText) {
+		segments = append(segments, WordSegment{Operation: Equal, Text: newText[current:]})
+	}
+	return segments
+}
+
+// The text covered by count words from start, or the point just after the word before start when
+// nothing was changed on that side
+func wordRange(words []word, start, count int) (int, int) {
+	if count > 0 {
+		return words[start].start, words[start+count-1].end
+	}
+	if start == 0 {
+		return 0, 0
+	}
+	return words[start-1].end, words[start-1].end
+}
+
+// Every word gets a line of its own so the line diff can compare them
+func wordLines(text string, words []word) []string {
+	lines := make([]string, len(words))
+	for i, word := range words {
+		lines[i] = text[word.start:word.end] + "\n"
+	}
+	return lines
+}
+
+func splitWords(text string, pattern *regexp.Regexp) []word {
+	var words []word
+	for start := 0; start < len(text); {
+		word, ok := nextWord(text, start, pattern)
+		if !ok {
+			break
+		}
+		words = append(words, word)
+		start = word.end
+	}
+	return words
+}
+
+// A pattern match is cut at the first newline and empty matches are skipped a byte at a time
+func nextWord(text string, start int, pattern *regexp.Regexp) (word, bool) {
+	if pattern != nil {
+		for start < len(text) {
+			match := pattern.FindStringIndex(text[start:])
+			if match == nil {
+				return word{}, false
+			}
+			end := start + match[1]
+			if newline := strings.IndexByte(text[start+match[0]:end], '\n'); newline != -1 {
+				end = start + match[0] + newline
+			}
+			start += match[0]
+			if start != end {
+				return word{start: start, end: end}, true
+			}
+			start++
+		}
+		return word{}, false
+	}
+
+	for start < len(text) && isSpace(text[start]) {
+		start++
+	}
+	if start >= len(text) {
+		return word{}, false
+	}
+	end := start + 1
+	for end < len(text) && !isSpace(text[end]) {
+		end++
+	}
+	return word{start: start, end: end}, true
+}
+
+// C's isspace, which is what git splits words on
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+// Word patterns are POSIX extended regular expressions where . and negated classes don't match newlines
+func CompileWordPattern(expression string) (*regexp.Regexp, error) {
+	return regexp.CompilePOSIX(expression)
+}
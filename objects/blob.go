@@ -38,3 +38,17 @@ func (blob *Blob) Serialize() []byte {
 	data := append([]byte(header), blob.Data...)
 	return data
 }
+
+func ReadBlob(repository *common.Repository, hash common.Hash) (*Blob, error) {
+	objectType, content, err := ReadObject(repository, hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "blob" {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, objectType)
+	}
+	return &Blob{
+		Hash: hash,
+		Data: content,
+	}, nil
+}
@@ -16,7 +16,10 @@ type Commit struct {
 	Parents   []common.Hash
 	Author    string
 	Timestamp time.Time
-	Message   string
+	// Left empty the author is the committer too
+	Committer     string
+	CommitterTime time.Time
+	Message       string
 }
 
 // Ref https://stackoverflow.com/questions/22968856/what-is-the-file-format-of-a-git-commit-object-data-structure
@@ -28,16 +31,23 @@ func (commit *Commit) Serialize() []byte {
 			content += fmt.Sprintf("parent %v\n", parent)
 		}
 	}
-	utcOffset := fmt.Sprintf("%d %s", commit.Timestamp.Unix(), commit.Timestamp.Format("-0700"))
-	// Committer will be the author for simplicity
-	content += fmt.Sprintf("author %s %s\n", commit.Author, utcOffset)
-	content += fmt.Sprintf("committer %s %s\n\n", commit.Author, utcOffset)
+	committer, committerTime := commit.Author, commit.Timestamp
+	if commit.Committer != "" {
+		committer, committerTime = commit.Committer, commit.CommitterTime
+	}
+	content += fmt.Sprintf("author %s %s\n", commit.Author, identityTime(commit.Timestamp))
+	content += fmt.Sprintf("committer %s %s\n\n", committer, identityTime(committerTime))
 	content += commit.Message
 
 	header := fmt.Sprintf("commit %d\x00", len(content))
 	return append([]byte(header), []byte(content)...)
 }
 
+// "<unix time> <zone>" after an identity
+func identityTime(timestamp time.Time) string {
+	return fmt.Sprintf("%d %s", timestamp.Unix(), timestamp.Format("-0700"))
+}
+
 func ParseCommit(rawCommitData []byte) (*Commit, error) {
 	// Find the null byte that separates header from content
 	nullIndex := bytes.IndexByte(rawCommitData, byte('\x00'))
@@ -70,25 +80,15 @@ func ParseCommit(rawCommitData []byte) (*Commit, error) {
 		i++
 	}
 
-	// Parse author and timestamp
-	var author string
-	var timestamp time.Time
+	// Parse author and committer with their timestamps
+	var author, committer string
+	var timestamp, committerTime time.Time
 	if i < len(lines) && strings.HasPrefix(lines[i], "author ") {
-		authorLine := strings.TrimPrefix(lines[i], "author ")
-		parts := strings.Fields(authorLine)
-		if len(parts) >= 3 {
-			timestampStr := parts[len(parts)-2]
-			author = strings.Join(parts[:len(parts)-2], " ")
-			ts, err := strconv.ParseInt(timestampStr, 10, 64)
-			if err == nil {
-				timestamp = time.Unix(ts, 0)
-			}
-		}
+		author, timestamp = parseIdentityLine(strings.TrimPrefix(lines[i], "author "))
 		i++
 	}
-
-	// Skip committer line
 	if i < len(lines) && strings.HasPrefix(lines[i], "committer ") {
+		committer, committerTime = parseIdentityLine(strings.TrimPrefix(lines[i], "committer "))
 		i++
 	}
 
@@ -104,10 +104,93 @@ func ParseCommit(rawCommitData []byte) (*Commit, error) {
 	}
 
 	return &Commit{
-		Tree:      &Tree{Hash: treeHash},
-		Parents:   parents,
-		Author:    author,
-		Timestamp: timestamp,
-		Message:   message,
+		Tree:          &Tree{Hash: treeHash},
+		Parents:       parents,
+		Author:        author,
+		Timestamp:     timestamp,
+		Committer:     committer,
+		CommitterTime: committerTime,
+		Message:       message,
 	}, nil
 }
+
+// "Name <email> <unix time> <zone>" - the time keeps the zone it was recorded in
+func parseIdentityLine(line string) (string, time.Time) {
+	parts := strings.Fields(line)
+	if len(parts) < 3 {
+		return "", time.Time{}
+	}
+	identity := strings.Join(parts[:len(parts)-2], " ")
+	seconds, err := strconv.ParseInt(parts[len(parts)-2], 10, 64)
+	if err != nil {
+		return identity, time.Time{}
+	}
+	timestamp := time.Unix(seconds, 0)
+	zone, err := time.Parse("-0700", parts[len(parts)-1])
+	if err == nil {
+		timestamp = timestamp.In(zone.Location())
+	}
+	return identity, timestamp
+}
+
+func ReadCommit(repository *common.Repository, hash common.Hash) (*Commit, error) {
+	rawCommitData, err := repository.ReadObject(hash.String())
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(rawCommitData, []byte("commit ")) {
+		return nil, fmt.Errorf("object %s is not a commit", hash)
+	}
+	return ParseCommit(rawCommitData)
+}
+
+// Walk the history of descendant looking for ancestor - a commit counts as its own ancestor
+func IsAncestor(repository *common.Repository, ancestor, descendant common.Hash) (bool, error) {
+	visited := make(map[common.Hash]bool)
+	queue := []common.Hash{descendant}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == ancestor {
+			return true, nil
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		commit, err := ReadCommit(repository, current)
+		if err != nil {
+			return false, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return false, nil
+}
+
+// First line of the commit message
+func (commit *Commit) Subject() string {
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+	return subject
+}
+
+// Every commit reachable from any of the tips including the tips themselves
+func ReachableCommits(repository *common.Repository, tips []common.Hash) (map[common.Hash]bool, error) {
+	reachable := make(map[common.Hash]bool)
+	queue := append([]common.Hash{}, tips...)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.Empty() || reachable[current] {
+			continue
+		}
+		reachable[current] = true
+
+		commit, err := ReadCommit(repository, current)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return reachable, nil
+}
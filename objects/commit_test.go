@@ -0,0 +1,44 @@
+package objects
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func TestCommitKeepsCommitterAndZones(t *testing.T) {
+	authored := time.Date(2024, 2, 3, 4, 5, 6, 0, time.FixedZone("", -8*60*60))
+	committed := time.Date(2024, 2, 4, 10, 0, 0, 0, time.FixedZone("", 5*60*60+30*60))
+	commit := &Commit{
+		Tree:          &Tree{Hash: common.Hash{1}},
+		Author:        "Author <author@example.com>",
+		Timestamp:     authored,
+		Committer:     "Committer <committer@example.com>",
+		CommitterTime: committed,
+		Message:       "subject\n",
+	}
+	parsed, err := ParseCommit(commit.Serialize())
+	if err != nil {
+		t.Fatalf("expected no error parsing commit, got %v", err)
+	}
+	if parsed.Author != commit.Author || parsed.Committer != commit.Committer {
+		t.Errorf("expected the author and committer back, got %q and %q", parsed.Author, parsed.Committer)
+	}
+	if got := parsed.Timestamp.Format(time.RFC1123Z); got != authored.Format(time.RFC1123Z) {
+		t.Errorf("expected the author date in its own zone, got %s", got)
+	}
+	if got := parsed.CommitterTime.Format(time.RFC1123Z); got != committed.Format(time.RFC1123Z) {
+		t.Errorf("expected the committer date in its own zone, got %s", got)
+	}
+
+	// Without a committer the author is written for both
+	commit.Committer = ""
+	parsed, err = ParseCommit(commit.Serialize())
+	if err != nil {
+		t.Fatalf("expected no error parsing commit, got %v", err)
+	}
+	if parsed.Committer != commit.Author || !parsed.CommitterTime.Equal(authored) {
+		t.Errorf("expected the author as committer, got %q at %v", parsed.Committer, parsed.CommitterTime)
+	}
+}
@@ -0,0 +1,28 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// Read an object from the DB and split off its header
+// Returns the object type and the content following the null byte
+func ReadObject(repository *common.Repository, hash common.Hash) (string, []byte, error) {
+	rawObjectData, err := repository.ReadObject(hash.String())
+	if err != nil {
+		return "", nil, err
+	}
+	nullIndex := bytes.IndexByte(rawObjectData, byte('\x00'))
+	if nullIndex == -1 {
+		return "", nil, fmt.Errorf("invalid object format: no null byte found")
+	}
+	header := string(rawObjectData[:nullIndex])
+	parts := strings.Split(header, " ")
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("invalid object header format expected <type> <data length> got: %s", header)
+	}
+	return parts[0], rawObjectData[nullIndex+1:], nil
+}
@@ -0,0 +1,356 @@
+package objects
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// Abbreviated hashes have to be at least this long to be looked up
+const minimumShortHashLength = 4
+
+// Short hashes printed for humans start at this length and grow until they're unique
+const DefaultShortHashLength = 7
+
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// Resolve a revision expression to an object the way git rev-parse does
+// Supports HEAD, ref names, (short) hashes, <rev>~<n>, <rev>^<n>, <rev>^{<type>}, <ref>@{<n>},
+// :<path> for the index and <rev>:<path> for trees
+// Ref https://git-scm.com/docs/gitrevisions
+func ResolveRevision(repository *common.Repository, revision string) (common.Hash, error) {
+	if revision == "" {
+		return common.Hash{}, fmt.Errorf("empty revision")
+	}
+
+	// Everything after the first colon is a path inside the tree (or the index when there's no revision)
+	if colonIndex := strings.Index(revision, ":"); colonIndex != -1 {
+		if colonIndex == 0 {
+			return resolveIndexPath(repository, revision[1:])
+		}
+		treeHash, err := ResolveRevision(repository, revision[:colonIndex])
+		if err != nil {
+			return common.Hash{}, err
+		}
+		treeHash, err = PeelRevision(repository, treeHash, "tree")
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return resolveTreePath(repository, treeHash, revision[colonIndex+1:], revision[:colonIndex])
+	}
+
+	// The name runs up to the first ~ or ^ and the rest are suffixes applied left to right
+	nameEnd := strings.IndexAny(revision, "~^")
+	if nameEnd == -1 {
+		nameEnd = len(revision)
+	}
+	hash, err := resolveRevisionName(repository, revision[:nameEnd], revision)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return applyRevisionSuffixes(repository, hash, revision[nameEnd:], revision)
+}
+
+// Resolve a revision and peel it to the commit it names, ie a tag or branch name
+func ResolveCommitish(repository *common.Repository, revision string) (common.Hash, error) {
+	hash, err := ResolveRevision(repository, revision)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return PeelRevision(repository, hash, "commit")
+}
+
+func unknownRevision(revision string) error {
+	return fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree", revision)
+}
+
+func resolveRevisionName(repository *common.Repository, name, revision string) (common.Hash, error) {
+	// <ref>@{<n>} is the value the ref had n updates ago - a bare @{<n>} means the current branch
+	if atIndex := strings.Index(name, "@{"); atIndex != -1 && strings.HasSuffix(name, "}") {
+		position, err := strconv.Atoi(name[atIndex+2 : len(name)-1])
+		if err != nil || position < 0 {
+			return common.Hash{}, unknownRevision(revision)
+		}
+		refName, err := reflogRefName(repository, name[:atIndex])
+		if err != nil {
+			return common.Hash{}, err
+		}
+		entries, err := repository.ReadReflog(refName)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if position >= len(entries) {
+			return common.Hash{}, fmt.Errorf("log for '%s' only has %d entries", name[:atIndex], len(entries))
+		}
+		return entries[len(entries)-1-position].NewHash, nil
+	}
+
+	if len(name) == 40 && hexPattern.MatchString(name) {
+		return common.ParseHash(name)
+	}
+	ref, err := findRevisionRef(repository, name)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if ref != nil {
+		return ref.Hash, nil
+	}
+
+	if len(name) >= minimumShortHashLength && hexPattern.MatchString(name) {
+		hashes, err := repository.FindObjects(name)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if len(hashes) > 1 {
+			return common.Hash{}, fmt.Errorf("short object ID %s is ambiguous", name)
+		}
+		if len(hashes) == 1 {
+			return hashes[0], nil
+		}
+	}
+	return common.Hash{}, unknownRevision(revision)
+}
+
+// Find the ref a short name refers to using git's lookup order
+// Returns nil if no ref matches
+func findRevisionRef(repository *common.Repository, name string) (*common.Ref, error) {
+	if name == "HEAD" || name == "@" {
+		hash, err := repository.ResolveHead()
+		if err != nil || hash.Empty() {
+			return nil, err
+		}
+		return &common.Ref{Name: "HEAD", Hash: hash}, nil
+	}
+
+	// Ref https://github.com/git/git/blob/master/refs.c (ref_rev_parse_rules)
+	candidates := []string{"refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"}
+	// The name as is only for full ref names and top level refs like ORIG_HEAD and FETCH_HEAD
+	if strings.HasPrefix(name, "refs/") || isPseudoRefName(name) {
+		candidates = append([]string{name}, candidates...)
+	}
+	for _, candidate := range candidates {
+		ref, err := repository.ReadRef(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if !ref.Hash.Empty() {
+			return ref, nil
+		}
+	}
+	return nil, nil
+}
+
+func isPseudoRefName(name string) bool {
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// The log a reflog selector reads - the current branch's when the name is empty
+func reflogRefName(repository *common.Repository, name string) (string, error) {
+	if name == "" {
+		branch, err := repository.GetBranch()
+		if err != nil {
+			return "", err
+		}
+		if branch == "" {
+			return "HEAD", nil
+		}
+		return "refs/heads/" + branch, nil
+	}
+	ref, err := findRevisionRef(repository, name)
+	if err != nil {
+		return "", err
+	}
+	if ref == nil {
+		return "", unknownRevision(name)
+	}
+	return ref.Name, nil
+}
+
+func applyRevisionSuffixes(repository *common.Repository, hash common.Hash, suffixes, revision string) (common.Hash, error) {
+	for len(suffixes) > 0 {
+		operator := suffixes[0]
+		suffixes = suffixes[1:]
+
+		// ^{<type>} peels to the given object type, ^{} just peels tags
+		if operator == '^' && strings.HasPrefix(suffixes, "{") {
+			closingIndex := strings.Index(suffixes, "}")
+			if closingIndex == -1 {
+				return common.Hash{}, unknownRevision(revision)
+			}
+			var err error
+			hash, err = PeelRevision(repository, hash, suffixes[1:closingIndex])
+			if err != nil {
+				return common.Hash{}, err
+			}
+			suffixes = suffixes[closingIndex+1:]
+			continue
+		}
+
+		// Both ~ and ^ default to 1 when no number follows
+		digits := 0
+		for digits < len(suffixes) && suffixes[digits] >= '0' && suffixes[digits] <= '9' {
+			digits++
+		}
+		number := 1
+		if digits > 0 {
+			var err error
+			number, err = strconv.Atoi(suffixes[:digits])
+			if err != nil {
+				return common.Hash{}, unknownRevision(revision)
+			}
+		}
+		suffixes = suffixes[digits:]
+
+		commitHash, err := PeelRevision(repository, hash, "commit")
+		if err != nil {
+			return common.Hash{}, err
+		}
+		hash = commitHash
+		if operator == '^' {
+			// ^0 is the commit itself, ^<n> is its nth parent
+			if number == 0 {
+				continue
+			}
+			commit, err := ReadCommit(repository, hash)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			if number > len(commit.Parents) {
+				return common.Hash{}, unknownRevision(revision)
+			}
+			hash = commit.Parents[number-1]
+			continue
+		}
+
+		// ~<n> follows first parents n generations back
+		for range number {
+			commit, err := ReadCommit(repository, hash)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			if len(commit.Parents) == 0 {
+				return common.Hash{}, unknownRevision(revision)
+			}
+			hash = commit.Parents[0]
+		}
+	}
+	return hash, nil
+}
+
+// Dereference tags (and commits when asking for a tree) until the object has the given type
+// An empty type only peels tags
+func PeelRevision(repository *common.Repository, hash common.Hash, objectType string) (common.Hash, error) {
+	for {
+		currentType, _, err := ReadObject(repository, hash)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if currentType == objectType || (objectType == "" && currentType != "tag") {
+			return hash, nil
+		}
+
+		switch {
+		case currentType == "tag":
+			hash, err = PeelTag(repository, hash)
+			if err != nil {
+				return common.Hash{}, err
+			}
+		case currentType == "commit" && objectType == "tree":
+			commit, err := ReadCommit(repository, hash)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			hash = commit.Tree.Hash
+		default:
+			return common.Hash{}, fmt.Errorf("object %s is a %s, not a %s", hash, currentType, objectType)
+		}
+	}
+}
+
+// Walk a path down from a tree - an empty path is the tree itself
+func resolveTreePath(repository *common.Repository, treeHash common.Hash, entryPath, revision string) (common.Hash, error) {
+	entryPath = strings.Trim(entryPath, "/")
+	if entryPath == "" {
+		return treeHash, nil
+	}
+
+	hash := treeHash
+	isTree := true
+	for _, component := range strings.Split(entryPath, "/") {
+		if !isTree {
+			return common.Hash{}, fmt.Errorf("path '%s' does not exist in '%s'", entryPath, revision)
+		}
+		tree, err := ReadTree(repository, hash)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		found := false
+		for _, entry := range tree.Entries {
+			if entry.Name == component {
+				hash = entry.Hash
+				isTree = entry.FileMode == 040000
+				found = true
+				break
+			}
+		}
+		if !found {
+			return common.Hash{}, fmt.Errorf("path '%s' does not exist in '%s'", entryPath, revision)
+		}
+	}
+	return hash, nil
+}
+
+var indexStagePattern = regexp.MustCompile(`^([0-3]):(.*)$`)
+
+// :<path> is the stage 0 entry in the index, :<n>:<path> picks a conflict stage
+func resolveIndexPath(repository *common.Repository, entryPath string) (common.Hash, error) {
+	stage := 0
+	if match := indexStagePattern.FindStringSubmatch(entryPath); match != nil {
+		stage, _ = strconv.Atoi(match[1])
+		entryPath = match[2]
+	}
+	entryPath = strings.Trim(entryPath, "/")
+
+	index, err := common.GetIndex(repository)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	inIndex := false
+	for _, entry := range index.Entries {
+		if entry.EntryPath != entryPath {
+			continue
+		}
+		if entry.Stage() == stage {
+			return entry.Hash, nil
+		}
+		inIndex = true
+	}
+	if inIndex {
+		return common.Hash{}, fmt.Errorf("path '%s' is in the index, but not at stage %d", entryPath, stage)
+	}
+	return common.Hash{}, fmt.Errorf("path '%s' does not exist in the index", entryPath)
+}
+
+// The shortest abbreviation of at least length characters that no other object shares
+func ShortHash(repository *common.Repository, hash common.Hash, length int) (string, error) {
+	hashString := hash.String()
+	length = max(length, minimumShortHashLength)
+	for ; length < len(hashString); length++ {
+		hashes, err := repository.FindObjects(hashString[:length])
+		if err != nil {
+			return "", err
+		}
+		if len(hashes) <= 1 {
+			break
+		}
+	}
+	return hashString[:length], nil
+}
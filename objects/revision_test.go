@@ -0,0 +1,109 @@
+package objects
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func writeTestObject(t *testing.T, repository *common.Repository, serializedData []byte) common.Hash {
+	t.Helper()
+	hash, err := common.HashObject(serializedData)
+	if err != nil {
+		t.Fatalf("expected no error hashing object, got %v", err)
+	}
+	if err := repository.WriteObject(hash.String(), serializedData); err != nil {
+		t.Fatalf("expected no error writing object, got %v", err)
+	}
+	return hash
+}
+
+// Two commits on main each with a single file, the second one adding docs/guide.md
+func createTestHistory(t *testing.T) (*common.Repository, []common.Hash) {
+	t.Helper()
+	repository := &common.Repository{
+		WorkTree:     t.TempDir(),
+		GitDirectory: t.TempDir(),
+	}
+	if err := os.MkdirAll(filepath.Join(repository.GitDirectory, "refs", "heads"), 0755); err != nil {
+		t.Fatalf("expected no error creating refs directory, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repository.GitDirectory, "HEAD"), []byte("ref: refs/heads/main\n"), 0644); err != nil {
+		t.Fatalf("expected no error writing HEAD, got %v", err)
+	}
+
+	readme := writeTestObject(t, repository, (&Blob{Data: []byte("readme\n")}).Serialize())
+	guide := writeTestObject(t, repository, (&Blob{Data: []byte("guide\n")}).Serialize())
+	docs := writeTestObject(t, repository, (&Tree{Entries: []*TreeEntry{{Name: "guide.md", FileMode: 0100644, Hash: guide}}}).Serialize())
+	firstTree := writeTestObject(t, repository, (&Tree{Entries: []*TreeEntry{{Name: "README.md", FileMode: 0100644, Hash: readme}}}).Serialize())
+	secondTree := writeTestObject(t, repository, (&Tree{Entries: []*TreeEntry{
+		{Name: "README.md", FileMode: 0100644, Hash: readme},
+		{Name: "docs", FileMode: 040000, Hash: docs},
+	}}).Serialize())
+
+	var commits []common.Hash
+	for _, tree := range []common.Hash{firstTree, secondTree} {
+		commit := &Commit{
+			Tree:      &Tree{Hash: tree},
+			Parents:   commits,
+			Author:    "test <test@example.com>",
+			Timestamp: time.Unix(1700000000, 0),
+			Message:   "commit\n",
+		}
+		commits = []common.Hash{writeTestObject(t, repository, commit.Serialize())}
+		if err := repository.WriteRef(&common.Ref{Name: "main", Hash: commits[0]}, "main"); err != nil {
+			t.Fatalf("expected no error writing ref, got %v", err)
+		}
+	}
+	commit, err := ReadCommit(repository, commits[0])
+	if err != nil {
+		t.Fatalf("expected no error reading commit, got %v", err)
+	}
+	return repository, []common.Hash{commit.Parents[0], commits[0], secondTree, guide}
+}
+
+func TestResolveRevision(t *testing.T) {
+	repository, hashes := createTestHistory(t)
+	first, second, secondTree, guide := hashes[0], hashes[1], hashes[2], hashes[3]
+
+	if err := os.WriteFile(filepath.Join(repository.GitDirectory, "ORIG_HEAD"), []byte(first.String()+"\n"), 0644); err != nil {
+		t.Fatalf("expected no error writing ORIG_HEAD, got %v", err)
+	}
+
+	tests := map[string]common.Hash{
+		"HEAD":               second,
+		"main":               second,
+		"refs/heads/main":    second,
+		"heads/main":         second,
+		"ORIG_HEAD":          first,
+		second.String():      second,
+		second.String()[:7]:  second,
+		"HEAD~1":             first,
+		"main^":              first,
+		"HEAD^0":             second,
+		"HEAD^{tree}":        secondTree,
+		"HEAD:":              secondTree,
+		"HEAD:docs/guide.md": guide,
+		"main~1^{commit}":    first,
+		first.String()[:10]:  first,
+	}
+	for revision, expected := range tests {
+		hash, err := ResolveRevision(repository, revision)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", revision, err)
+			continue
+		}
+		if hash != expected {
+			t.Errorf("%s: expected %s, got %s", revision, expected, hash)
+		}
+	}
+
+	for _, revision := range []string{"missing", "orig_head", "HEAD~2", "HEAD^2", "HEAD:missing", "HEAD:README.md/x", "HEAD~1:docs"} {
+		if _, err := ResolveRevision(repository, revision); err == nil {
+			t.Errorf("%s: expected an error", revision)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package objects
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// Annotated tag object: the tagged object plus who tagged it and why
+type Tag struct {
+	Object  common.Hash
+	Type    string
+	Name    string
+	Tagger  string
+	Message string
+}
+
+func ReadTag(repository *common.Repository, hash common.Hash) (*Tag, error) {
+	objectType, content, err := ReadObject(repository, hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "tag" {
+		return nil, fmt.Errorf("object %s is a %s, not a tag", hash, objectType)
+	}
+
+	header, message, _ := strings.Cut(string(content), "\n\n")
+	tag := &Tag{Message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			tag.Object, err = common.ParseHash(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag object %s: %v", hash, err)
+			}
+		case "type":
+			tag.Type = value
+		case "tag":
+			tag.Name = value
+		case "tagger":
+			tag.Tagger = value
+		}
+	}
+	if tag.Object.Empty() {
+		return nil, fmt.Errorf("invalid tag object %s: missing object line", hash)
+	}
+	return tag, nil
+}
+
+// First line of the tag message
+func (tag *Tag) Subject() string {
+	subject, _, _ := strings.Cut(tag.Message, "\n")
+	return subject
+}
+
+// Follow annotated tags down to the object they point at
+// Returns an empty hash if the object isn't a tag
+func PeelTag(repository *common.Repository, hash common.Hash) (common.Hash, error) {
+	peeled := common.Hash{}
+	for {
+		objectType, _, err := ReadObject(repository, hash)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if objectType != "tag" {
+			return peeled, nil
+		}
+		tag, err := ReadTag(repository, hash)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		hash = tag.Object
+		peeled = hash
+	}
+}
@@ -27,6 +27,14 @@ func BuildTreeFromIndex(index *common.Index) (*Tree, map[string]*Tree, error) {
 	trees := make(map[string]*Tree)
 
 	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			return nil, nil, fmt.Errorf("error building trees: %s is unmerged", entry.EntryPath)
+		}
+		// Paths that are only intended to be added don't have content to record yet
+		if entry.IntentToAdd() {
+			continue
+		}
+
 		// Build empty trees to represent each directory and subdirectory within each index entry filepath
 		// Each entry filepath represents its relative location in the working tree
 		directoryPath := entry.EntryPath
@@ -104,8 +112,12 @@ func BuildTreeFromIndex(index *common.Index) (*Tree, map[string]*Tree, error) {
 		})
 	}
 
-	// Calculate hash for the root tree
-	rootTree := trees[""]
+	// Calculate hash for the root tree - the index may hold nothing but intent to add entries
+	rootTree, exists := trees[""]
+	if !exists {
+		rootTree = &Tree{Entries: []*TreeEntry{}}
+		trees[""] = rootTree
+	}
 	serializedRootTreeData := rootTree.Serialize()
 	rootHash, err := common.HashObject(serializedRootTreeData)
 	if err != nil {
@@ -207,3 +219,48 @@ func ParseTree(rawTreeData []byte) (*Tree, error) {
 	}
 	return tree, nil
 }
+
+func ReadTree(repository *common.Repository, hash common.Hash) (*Tree, error) {
+	rawTreeData, err := repository.ReadObject(hash.String())
+	if err != nil {
+		return nil, err
+	}
+	tree, err := ParseTree(rawTreeData)
+	if err != nil {
+		return nil, err
+	}
+	tree.Hash = hash
+	return tree, nil
+}
+
+// Flatten a tree and all of its subtrees into index entries with paths relative to the root tree
+// Entries carry no stat data since nothing has been written to the working tree
+func IndexEntriesFromTree(repository *common.Repository, tree *Tree, prefix string) ([]*common.IndexEntry, error) {
+	var entries []*common.IndexEntry
+	for _, treeEntry := range tree.Entries {
+		entryPath := treeEntry.Name
+		if prefix != "" {
+			entryPath = prefix + "/" + treeEntry.Name
+		}
+
+		if treeEntry.FileMode == 040000 {
+			subtree, err := ReadTree(repository, treeEntry.Hash)
+			if err != nil {
+				return nil, err
+			}
+			subtreeEntries, err := IndexEntriesFromTree(repository, subtree, entryPath)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, subtreeEntries...)
+			continue
+		}
+
+		entries = append(entries, &common.IndexEntry{
+			Hash:      treeEntry.Hash,
+			FileMode:  treeEntry.FileMode,
+			EntryPath: entryPath,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,132 @@
+package objects
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+// One path that differs between two trees - a zero mode means the path is missing on that side
+type TreeChange struct {
+	Path    string
+	OldMode uint32
+	NewMode uint32
+	OldHash common.Hash
+	NewHash common.Hash
+}
+
+// A letter for the kind of change like diff-tree prints it: A, D, M or T for a change of type
+func (change *TreeChange) Status() byte {
+	switch {
+	case change.OldMode == 0:
+		return 'A'
+	case change.NewMode == 0:
+		return 'D'
+	case change.OldMode&0170000 != change.NewMode&0170000:
+		return 'T'
+	}
+	return 'M'
+}
+
+// Compare two trees entry by entry, an empty hash standing for the empty tree
+// Subtrees are only read when their hashes differ - without recursive a changed subtree is reported
+// as a change of its own instead of the files inside it
+func DiffTrees(repository *common.Repository, oldTree, newTree common.Hash, recursive bool) ([]*TreeChange, error) {
+	var changes []*TreeChange
+	err := diffTrees(repository, oldTree, newTree, "", recursive, &changes)
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func diffTrees(repository *common.Repository, oldTree, newTree common.Hash, prefix string, recursive bool, changes *[]*TreeChange) error {
+	oldEntries, err := sortedTreeEntries(repository, oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := sortedTreeEntries(repository, newTree)
+	if err != nil {
+		return err
+	}
+
+	// Both lists are in tree order so walk them together like a merge
+	for len(oldEntries) > 0 || len(newEntries) > 0 {
+		var oldEntry, newEntry *TreeEntry
+		switch {
+		case len(newEntries) == 0:
+			oldEntry = oldEntries[0]
+		case len(oldEntries) == 0:
+			newEntry = newEntries[0]
+		default:
+			comparison := strings.Compare(treeOrderName(oldEntries[0]), treeOrderName(newEntries[0]))
+			if comparison <= 0 {
+				oldEntry = oldEntries[0]
+			}
+			if comparison >= 0 {
+				newEntry = newEntries[0]
+			}
+		}
+		if oldEntry != nil {
+			oldEntries = oldEntries[1:]
+		}
+		if newEntry != nil {
+			newEntries = newEntries[1:]
+		}
+
+		err = diffTreeEntries(repository, oldEntry, newEntry, prefix, recursive, changes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Either entry may be nil but when both are set they share a name and are both trees or both not
+func diffTreeEntries(repository *common.Repository, oldEntry, newEntry *TreeEntry, prefix string, recursive bool, changes *[]*TreeChange) error {
+	change := &TreeChange{}
+	if oldEntry != nil {
+		change.Path = prefix + oldEntry.Name
+		change.OldMode = oldEntry.FileMode
+		change.OldHash = oldEntry.Hash
+	}
+	if newEntry != nil {
+		change.Path = prefix + newEntry.Name
+		change.NewMode = newEntry.FileMode
+		change.NewHash = newEntry.Hash
+	}
+	if change.OldMode == change.NewMode && change.OldHash == change.NewHash {
+		return nil
+	}
+
+	isTree := (oldEntry != nil && oldEntry.FileMode == 040000) || (newEntry != nil && newEntry.FileMode == 040000)
+	if isTree && recursive {
+		return diffTrees(repository, change.OldHash, change.NewHash, change.Path+"/", recursive, changes)
+	}
+	*changes = append(*changes, change)
+	return nil
+}
+
+func sortedTreeEntries(repository *common.Repository, hash common.Hash) ([]*TreeEntry, error) {
+	if hash.Empty() {
+		return nil, nil
+	}
+	tree, err := ReadTree(repository, hash)
+	if err != nil {
+		return nil, err
+	}
+	entries := slices.Clone(tree.Entries)
+	slices.SortFunc(entries, func(a, b *TreeEntry) int {
+		return strings.Compare(treeOrderName(a), treeOrderName(b))
+	})
+	return entries, nil
+}
+
+// Git orders tree entries as if directory names ended in a slash
+func treeOrderName(entry *TreeEntry) string {
+	if entry.FileMode == 040000 {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
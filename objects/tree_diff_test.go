@@ -0,0 +1,70 @@
+package objects
+
+import (
+	"testing"
+
+	"github.com/CLBRITTON2/go-git-good/common"
+)
+
+func TestDiffTrees(t *testing.T) {
+	repository, _ := createTestHistory(t)
+	blob := func(content string) common.Hash {
+		return writeTestObject(t, repository, (&Blob{Data: []byte(content)}).Serialize())
+	}
+	tree := func(entries ...*TreeEntry) common.Hash {
+		return writeTestObject(t, repository, (&Tree{Entries: entries}).Serialize())
+	}
+
+	unchanged := tree(&TreeEntry{Name: "keep", FileMode: 0100644, Hash: blob("keep\n")})
+	oldTree := tree(
+		&TreeEntry{Name: "a", FileMode: 0100644, Hash: blob("a\n")},
+		&TreeEntry{Name: "lib", FileMode: 040000, Hash: tree(&TreeEntry{Name: "x", FileMode: 0100644, Hash: blob("x\n")})},
+		&TreeEntry{Name: "same", FileMode: 040000, Hash: unchanged},
+		&TreeEntry{Name: "script", FileMode: 0100644, Hash: blob("run\n")},
+	)
+	newTree := tree(
+		&TreeEntry{Name: "b", FileMode: 0100644, Hash: blob("b\n")},
+		&TreeEntry{Name: "lib", FileMode: 040000, Hash: tree(&TreeEntry{Name: "x", FileMode: 0100644, Hash: blob("x2\n")})},
+		// Sorts before lib/ in tree order
+		&TreeEntry{Name: "lib.go", FileMode: 0100644, Hash: blob("go\n")},
+		&TreeEntry{Name: "same", FileMode: 040000, Hash: unchanged},
+		&TreeEntry{Name: "script", FileMode: 0120000, Hash: blob("target")},
+	)
+
+	changes, err := DiffTrees(repository, oldTree, newTree, true)
+	if err != nil {
+		t.Fatalf("expected no error diffing trees, got %v", err)
+	}
+	expected := []string{"D a", "A b", "A lib.go", "M lib/x", "T script"}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d", len(expected), len(changes))
+	}
+	for i, change := range changes {
+		if got := string(change.Status()) + " " + change.Path; got != expected[i] {
+			t.Errorf("expected change %q, got %q", expected[i], got)
+		}
+	}
+
+	// Without recursing the changed subtree is reported by itself
+	changes, err = DiffTrees(repository, oldTree, newTree, false)
+	if err != nil {
+		t.Fatalf("expected no error diffing trees, got %v", err)
+	}
+	if len(changes) != 5 || changes[3].Path != "lib" || changes[3].NewMode != 040000 {
+		t.Errorf("expected lib to be reported as a changed tree, got %+v", changes)
+	}
+
+	// An empty hash is the empty tree so everything is added
+	changes, err = DiffTrees(repository, common.Hash{}, oldTree, true)
+	if err != nil {
+		t.Fatalf("expected no error diffing against the empty tree, got %v", err)
+	}
+	if len(changes) != 4 {
+		t.Errorf("expected 4 added files, got %d", len(changes))
+	}
+	for _, change := range changes {
+		if change.Status() != 'A' {
+			t.Errorf("expected %s to be added, got %c", change.Path, change.Status())
+		}
+	}
+}